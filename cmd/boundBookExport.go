@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// boundBookColumns is the header row of bound book CSV exports.
+var boundBookColumns = []string{
+	"id", "occurred_at", "kind", "source", "manufacturer", "model", "firearm_type", "serial_number",
+	"party_name", "party_address", "party_licence", "purchase_order_id", "sale_id", "return_id",
+	"corrects_id", "corrected_by_id", "note", "user_id", "created_at",
+}
+
+// boundBookTemplate renders a date range of the bound book as a page meant for printing. Entries
+// that have been corrected are struck through, and stay in the report next to their correction.
+var boundBookTemplate = template.Must(template.New("bound-book").Funcs(template.FuncMap{
+	"timestamp": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Acquisition and disposition record {{.From}} to {{.To}}</title>
+<style>
+  @page { size: A4 landscape; margin: 12mm; }
+  body { font-family: sans-serif; font-size: 9pt; color: #000; }
+  h1 { font-size: 14pt; margin: 0 0 4pt; }
+  p { margin: 0 0 8pt; }
+  table { width: 100%; border-collapse: collapse; }
+  thead { display: table-header-group; }
+  tr { page-break-inside: avoid; }
+  th, td { border: 1px solid #444; padding: 2pt 4pt; text-align: left; vertical-align: top; }
+  th { background: #eee; }
+  tr.corrected td { text-decoration: line-through; color: #555; }
+  td.ref { white-space: nowrap; }
+</style>
+</head>
+<body>
+<h1>Acquisition and disposition record</h1>
+<p>{{.From}} to {{.To}} &middot; {{len .Entries}} entries &middot; generated {{timestamp .GeneratedAt}} UTC</p>
+<table>
+<thead>
+<tr>
+  <th>Entry</th><th>Date (UTC)</th><th>Kind</th><th>Manufacturer</th><th>Model</th><th>Type</th>
+  <th>Serial number</th><th>Received from / disposed to</th><th>Address</th><th>Licence</th>
+  <th>Reference</th><th>Note</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Entries}}
+<tr{{if .CorrectedByID}} class="corrected"{{end}}>
+  <td>{{.ID}}</td>
+  <td>{{timestamp .OccurredAt}}</td>
+  <td>{{.Kind}}</td>
+  <td>{{.Manufacturer}}</td>
+  <td>{{.Model}}</td>
+  <td>{{.FirearmType}}</td>
+  <td>{{.SerialNumber}}</td>
+  <td>{{.PartyName}}</td>
+  <td>{{.PartyAddress}}</td>
+  <td>{{.PartyLicence}}</td>
+  <td class="ref">
+    {{- .Source}}
+    {{- with .PurchaseOrderID}}<br>PO {{.}}{{end}}
+    {{- with .SaleID}}<br>sale {{.}}{{end}}
+    {{- with .ReturnID}}<br>return {{.}}{{end}}
+    {{- with .CorrectsID}}<br>corrects {{.}}{{end}}
+    {{- with .CorrectedByID}}<br>corrected by {{.}}{{end -}}
+  </td>
+  <td>{{.Note}}</td>
+</tr>
+{{- else}}
+<tr><td colspan="12">No entries.</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// boundBookFormat picks the format of a bound book export: the format parameter if present,
+// otherwise HTML if the Accept header asks for it, and CSV by default.
+func (app *application) boundBookFormat(r *http.Request, v *validator.Validator) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		v.Check(validator.In(format, "csv", "html"), "format", "must be csv or html")
+		return format
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		if strings.TrimSpace(mediaType) == "text/html" {
+			return "html"
+		}
+	}
+
+	return "csv"
+}
+
+// exportBoundBookHandler exports the bound book for a from/to range of dates, the last 30 days
+// by default, as a CSV attachment or as an HTML page to print. The listing filters apply as
+// well, so the record of a single firearm can be printed on its own.
+func (app *application) exportBoundBookHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	today := models.Date{Time: time.Now().UTC().Truncate(24 * time.Hour)}
+	to := app.readDate(qs, "to", today, v)
+
+	bookFilters := app.readBoundBookFilters(qs, models.Date{Time: to.AddDate(0, 0, 1-defaultReportDays)}, to, v)
+	format := app.boundBookFormat(r, v)
+
+	if models.ValidateBoundBookFilters(v, bookFilters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	entries, err := app.models.BoundBook.GetRange(r.Context(), bookFilters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("bound-book-%s-%s", bookFilters.From, bookFilters.To)
+
+	if format == "html" {
+		data := struct {
+			From        models.Date
+			To          models.Date
+			GeneratedAt time.Time
+			Entries     []*models.BoundBookEntry
+		}{bookFilters.From, bookFilters.To, time.Now(), entries}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.html"`, filename))
+		w.WriteHeader(http.StatusOK)
+
+		err = boundBookTemplate.Execute(w, data)
+		if err != nil {
+			app.logError(r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+	w.WriteHeader(http.StatusOK)
+
+	records := make([][]string, 0, len(entries)+1)
+	records = append(records, boundBookColumns)
+
+	for _, entry := range entries {
+		records = append(records, boundBookRecord(entry))
+	}
+
+	err = csv.NewWriter(w).WriteAll(records)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// boundBookRecord returns a bound book entry as a CSV record with the boundBookColumns. The
+// text that was typed in is escaped with csvText.
+func boundBookRecord(entry *models.BoundBookEntry) []string {
+	return []string{
+		strconv.FormatInt(entry.ID, 10),
+		entry.OccurredAt.UTC().Format(time.RFC3339),
+		entry.Kind,
+		entry.Source,
+		csvText(entry.Manufacturer),
+		csvText(entry.Model),
+		csvText(entry.FirearmType),
+		csvText(entry.SerialNumber),
+		csvText(entry.PartyName),
+		csvText(entry.PartyAddress),
+		csvText(entry.PartyLicence),
+		formatOptionalID(entry.PurchaseOrderID),
+		formatOptionalID(entry.SaleID),
+		formatOptionalID(entry.ReturnID),
+		formatOptionalID(entry.CorrectsID),
+		formatOptionalID(entry.CorrectedByID),
+		csvText(entry.Note),
+		formatOptionalID(entry.UserID),
+		entry.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// formatOptionalID formats an optional id for CSV, as an empty field when it isn't set.
+func formatOptionalID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// readBoundBookFilters reads the filters of the bound book from the query string. from and to
+// default to the provided dates.
+func (app *application) readBoundBookFilters(qs url.Values, from, to models.Date, v *validator.Validator) models.BoundBookFilters {
+	return models.BoundBookFilters{
+		UnitID:       int64(app.readInt(qs, "unit_id", 0, v)),
+		SerialNumber: app.readStrings(qs, "serial_number", ""),
+		Kind:         app.readStrings(qs, "kind", ""),
+		From:         app.readDate(qs, "from", from, v),
+		To:           app.readDate(qs, "to", to, v),
+	}
+}
+
+// listBoundBookHandler returns a page of the bound book, in the order the entries were written
+// by default, filtered by unit_id, serial_number, kind and a from/to range of dates.
+func (app *application) listBoundBookHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	bookFilters := app.readBoundBookFilters(qs, models.Date{}, models.Date{}, v)
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "id"),
+		Order:        app.readStrings(qs, "order", "asc"),
+		SortSafelist: []string{"id", "occurred_at", "serial_number"},
+	}
+
+	models.ValidateBoundBookFilters(v, bookFilters)
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	entries, totalRecords, err := app.models.BoundBook.GetAll(r.Context(), bookFilters, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, entries, totalRecords, filters)
+}
+
+// showBoundBookEntryHandler returns a specific bound book entry.
+func (app *application) showBoundBookEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	entry, err := app.models.BoundBook.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"entry": entry}, nil)
+}
+
+// correctBoundBookEntryHandler appends a correction of a bound book entry. The fields that are
+// provided replace those of the original entry, the others are carried over, and the note says
+// what was wrong. The original entry is left as it is.
+func (app *application) correctBoundBookEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	original, err := app.models.BoundBook.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Manufacturer *string    `json:"manufacturer"`
+		Model        *string    `json:"model"`
+		FirearmType  *string    `json:"firearm_type"`
+		SerialNumber *string    `json:"serial_number"`
+		PartyName    *string    `json:"party_name"`
+		PartyAddress *string    `json:"party_address"`
+		PartyLicence *string    `json:"party_licence"`
+		OccurredAt   *time.Time `json:"occurred_at"`
+		Note         string     `json:"note"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	correction := *original
+	correction.CorrectsID = &original.ID
+	correction.CorrectedByID = nil
+	correction.Note = strings.TrimSpace(input.Note)
+
+	if input.Manufacturer != nil {
+		correction.Manufacturer = strings.TrimSpace(*input.Manufacturer)
+	}
+	if input.Model != nil {
+		correction.Model = strings.TrimSpace(*input.Model)
+	}
+	if input.FirearmType != nil {
+		correction.FirearmType = strings.TrimSpace(*input.FirearmType)
+	}
+	if input.SerialNumber != nil {
+		correction.SerialNumber = models.NormalizeSerial(*input.SerialNumber)
+	}
+	if input.PartyName != nil {
+		correction.PartyName = strings.TrimSpace(*input.PartyName)
+	}
+	if input.PartyAddress != nil {
+		correction.PartyAddress = strings.TrimSpace(*input.PartyAddress)
+	}
+	if input.PartyLicence != nil {
+		correction.PartyLicence = strings.TrimSpace(*input.PartyLicence)
+	}
+	if input.OccurredAt != nil {
+		correction.OccurredAt = *input.OccurredAt
+	}
+
+	v := validator.New()
+
+	if original.CorrectedByID != nil {
+		v.AddError("entry", fmt.Sprintf("has already been corrected by entry %d", *original.CorrectedByID))
+	}
+
+	v.Check(correction.Manufacturer != original.Manufacturer || correction.Model != original.Model ||
+		correction.FirearmType != original.FirearmType || correction.SerialNumber != original.SerialNumber ||
+		correction.PartyName != original.PartyName || correction.PartyAddress != original.PartyAddress ||
+		correction.PartyLicence != original.PartyLicence || !correction.OccurredAt.Equal(original.OccurredAt),
+		"entry", "the correction must change at least one field")
+
+	if models.ValidateBoundBookCorrection(v, &correction); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	correction.UserID = &user.ID
+
+	err = app.models.BoundBook.Correct(r.Context(), &correction)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrAlreadyCorrected):
+			v.AddError("entry", "has already been corrected")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/bound-book/%d", correction.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"entry": correction}, headers)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// customerView returns the customer as it may be shown to the current user: in full if they
+// hold the customers:read permission, and without any personally identifiable fields otherwise.
+func customerView(customer *models.Customer, canReadPII bool) interface{} {
+	if canReadPII {
+		return customer
+	}
+
+	return customer.Redacted()
+}
+
+func (app *application) createCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name          string      `json:"name"`
+		Email         string      `json:"email"`
+		Phone         string      `json:"phone"`
+		Address       string      `json:"address"`
+		DateOfBirth   models.Date `json:"date_of_birth"`
+		LicenceNumber string      `json:"licence_number"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	customer := &models.Customer{
+		Name:          input.Name,
+		Email:         input.Email,
+		Phone:         input.Phone,
+		Address:       input.Address,
+		DateOfBirth:   input.DateOfBirth,
+		LicenceNumber: input.LicenceNumber,
+	}
+
+	v := validator.New()
+
+	if models.ValidateCustomer(v, customer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Customers.Insert(r.Context(), customer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	canReadPII, err := app.hasPermission(r, "customers:read")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/customers/%d", customer.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"customer": customerView(customer, canReadPII)}, headers)
+}
+
+func (app *application) showCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	customer, err := app.models.Customers.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	canReadPII, err := app.hasPermission(r, "customers:read")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"customer": customerView(customer, canReadPII)}, nil)
+}
+
+func (app *application) listCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	name := app.readStrings(qs, "name", "")
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 10, v),
+		Sort:         app.readStrings(qs, "sort", "name"),
+		Order:        app.readStrings(qs, "order", "asc"),
+		SortSafelist: []string{"id", "name", "created_at"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	customers, totalRecords, err := app.models.Customers.GetAll(r.Context(), name, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	canReadPII, err := app.hasPermission(r, "customers:read")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	views := make([]interface{}, len(customers))
+	for i, customer := range customers {
+		views[i] = customerView(customer, canReadPII)
+	}
+
+	app.writePaginatedJSON(w, views, totalRecords, filters)
+}
+
+func (app *application) updateCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	customer, err := app.models.Customers.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name          *string      `json:"name"`
+		Email         *string      `json:"email"`
+		Phone         *string      `json:"phone"`
+		Address       *string      `json:"address"`
+		DateOfBirth   *models.Date `json:"date_of_birth"`
+		LicenceNumber *string      `json:"licence_number"`
+		Version       *int         `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != customer.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.Name != nil {
+		customer.Name = *input.Name
+	}
+	if input.Email != nil {
+		customer.Email = *input.Email
+	}
+	if input.Phone != nil {
+		customer.Phone = *input.Phone
+	}
+	if input.Address != nil {
+		customer.Address = *input.Address
+	}
+	if input.DateOfBirth != nil {
+		customer.DateOfBirth = *input.DateOfBirth
+	}
+	if input.LicenceNumber != nil {
+		customer.LicenceNumber = *input.LicenceNumber
+	}
+
+	v := validator.New()
+
+	if models.ValidateCustomer(v, customer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Customers.Update(r.Context(), customer)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	canReadPII, err := app.hasPermission(r, "customers:read")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"customer": customerView(customer, canReadPII)}, nil)
+}
+
+func (app *application) deleteCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Customers.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrRecordInUse):
+			app.failedValidationResponse(w, r, map[string]string{
+				"id": "customer has sales and can't be deleted",
+			})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listCustomerSalesHandler returns a page of the purchase history of a customer.
+func (app *application) listCustomerSalesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 10, v),
+		Sort:         app.readStrings(qs, "sort", "created_at"),
+		Order:        app.readStrings(qs, "order", "desc"),
+		SortSafelist: []string{"id", "created_at", "total"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Customers.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	sales, totalRecords, err := app.models.Sales.GetAllForCustomer(r.Context(), id, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, sales, totalRecords, filters)
+}
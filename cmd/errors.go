@@ -1,112 +1,112 @@
-package main
-
-import (
-	"fmt"
-	"net/http"
-)
-
-// logError method is a generic helper for logging an error message in *application, as well
-// as the requested method and request URL.
-func (app *application) logError(r *http.Request, err error) {
-	app.logger.PrintError(err, map[string]string{
-		"request_method": r.Method,
-		"request_url":    r.URL.String(),
-	})
-}
-
-// errorResponse method is a generic helper for sending JSON-formatted error messages to the
-// client with a given status code. Note that we're using an interface{} type for the message
-// parameter, rather than just a string type, as this gives us more flexibility over the values
-// that we can include in the response.
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
-	env := envelope{"error": message}
-
-	// Write the response using the writeJSON() helper. If this happens to return an error
-	// then log it, and fall back to sending the client an empty response with a 500 Internal
-	// Server Error status code
-	err := app.writeJSON(w, status, env, nil)
-	if err != nil {
-		app.logError(r, err)
-		w.WriteHeader(500)
-	}
-}
-
-// serverErrorResponse method is used when our application encounters an unexpected problem
-// at runtime. it logs the detailed error message, then uses the errorResponse() helper to send a
-// 500 Internal Server Error status code and JSON response (containing the generic error message)
-// to the client
-func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logError(r, err)
-
-	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, 500, message)
-}
-
-// notFoundResponse method is used to send a 404 Not Found status code and JSON response to the
-// client.
-func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	message := "the requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
-}
-
-// methodNotAllowedResponse method is used to send a 405 Method Not Allowed status code and
-// JSON response to the client.
-func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("the %s method is not supported this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
-}
-
-// badRequestResponse sends JSON-formatted error message with 400 Bad Request status code.
-func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
-}
-
-// failedValidationResponse sends JSON-formatted error message to client with UnprocessableEntity
-// 422 status code when Validation fails.
-// Note that the errors parameter here has the type map[string]string,
-// which is exact the same as the errors map contained in our Validator type.
-func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
-}
-
-// editConflictResponse sends a JSON-formatted error message to the client with a 409 Conflict
-// status code.
-func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
-	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
-}
-
-// invalidCredentialsResponse sends a JSON-formatted error with a 401 Unauthorized status code
-// to the client.
-func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
-	message := "invalid authentication credentials"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
-}
-
-// invalidAuthenticationTokenResponse sends a JSON-formatted error with a 401 Unauthorized status
-// code and "WWW-Authenticate: Bearer" header to the client.
-func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("WWWW-Authenticate", "Bearer")
-
-	message := "invalid or missing authentication token"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
-}
-
-// authenticationRequiredResponse sends a JSON-formatted error with a 401 Unauthorized status code
-// to the client.
-func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
-	message := "you must be authenticated to access this resource"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
-}
-
-// inactiveAccountResponse sends a JSON-formatted error with a 403 Forbidden status code to the
-// client.
-func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
-	message := "your user account must be activated to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
-}
-
-func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
-	message := "your user account doesn't have the necessary permissions to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
-}
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// logError method is a generic helper for logging an error message in *application, as well
+// as the requested method and request URL.
+func (app *application) logError(r *http.Request, err error) {
+	app.logger.PrintError(err, map[string]string{
+		"request_method": r.Method,
+		"request_url":    r.URL.String(),
+	})
+}
+
+// errorResponse method is a generic helper for sending JSON-formatted error messages to the
+// client with a given status code. Note that we're using an interface{} type for the message
+// parameter, rather than just a string type, as this gives us more flexibility over the values
+// that we can include in the response.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
+	env := envelope{"error": message}
+
+	// Write the response using the writeJSON() helper. If this happens to return an error
+	// then log it, and fall back to sending the client an empty response with a 500 Internal
+	// Server Error status code
+	err := app.writeJSON(w, status, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
+// serverErrorResponse method is used when our application encounters an unexpected problem
+// at runtime. it logs the detailed error message, then uses the errorResponse() helper to send a
+// 500 Internal Server Error status code and JSON response (containing the generic error message)
+// to the client
+func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+
+	message := "the server encountered a problem and could not process your request"
+	app.errorResponse(w, r, 500, message)
+}
+
+// notFoundResponse method is used to send a 404 Not Found status code and JSON response to the
+// client.
+func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the requested resource could not be found"
+	app.errorResponse(w, r, http.StatusNotFound, message)
+}
+
+// methodNotAllowedResponse method is used to send a 405 Method Not Allowed status code and
+// JSON response to the client.
+func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the %s method is not supported this resource", r.Method)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+}
+
+// badRequestResponse sends JSON-formatted error message with 400 Bad Request status code.
+func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+}
+
+// failedValidationResponse sends JSON-formatted error message to client with UnprocessableEntity
+// 422 status code when Validation fails.
+// Note that the errors parameter here has the type map[string]string,
+// which is exact the same as the errors map contained in our Validator type.
+func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+}
+
+// editConflictResponse sends a JSON-formatted error message to the client with a 409 Conflict
+// status code.
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+// invalidCredentialsResponse sends a JSON-formatted error with a 401 Unauthorized status code
+// to the client.
+func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid authentication credentials"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// invalidAuthenticationTokenResponse sends a JSON-formatted error with a 401 Unauthorized status
+// code and "WWW-Authenticate: Bearer" header to the client.
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWWW-Authenticate", "Bearer")
+
+	message := "invalid or missing authentication token"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// authenticationRequiredResponse sends a JSON-formatted error with a 401 Unauthorized status code
+// to the client.
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must be authenticated to access this resource"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+// inactiveAccountResponse sends a JSON-formatted error with a 403 Forbidden status code to the
+// client.
+func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account must be activated to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
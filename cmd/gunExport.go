@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// exportFlushEvery is the number of rows written between flushes of the response, so that
+// clients see the export arrive in chunks instead of all at once.
+const exportFlushEvery = 100
+
+// exportColumns is the header row of CSV exports.
+var exportColumns = []string{
+	"id", "sku", "name", "price", "damage", "manufacturer", "category", "specs",
+	"quantity_on_hand", "created_at", "updated_at", "version",
+}
+
+// exportFormat picks the format of an export: the format parameter if present, otherwise
+// text/csv if the Accept header asks for it, and newline-delimited JSON by default.
+func (app *application) exportFormat(r *http.Request, v *validator.Validator) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		v.Check(validator.In(format, "csv", "ndjson"), "format", "must be csv or ndjson")
+		return format
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+
+		switch strings.TrimSpace(mediaType) {
+		case "text/csv":
+			return "csv"
+		case "application/x-ndjson", "application/ndjson":
+			return "ndjson"
+		}
+	}
+
+	return "ndjson"
+}
+
+// exportGunsHandler streams every gun matching the listGuns filters as CSV or newline-delimited
+// JSON. Rows are written as they are read from the database, so the export never holds the
+// whole catalog in memory. Once the first row is out the status can't change any more, so an
+// error halfway through can only be logged and the response cut short.
+func (app *application) exportGunsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	gunFilters, filters, err := app.readGunFilters(r, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	format := app.exportFormat(r, v)
+
+	if models.ValidateGunFilters(v, gunFilters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	started := false
+	count := 0
+
+	var csvWriter *csv.Writer
+	var jsonEncoder *json.Encoder
+
+	// start writes the headers, and for CSV the header row, just before the first row.
+	start := func() error {
+		started = true
+
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="guns.csv"`)
+			w.WriteHeader(http.StatusOK)
+
+			csvWriter = csv.NewWriter(w)
+			return csvWriter.Write(exportColumns)
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="guns.ndjson"`)
+		w.WriteHeader(http.StatusOK)
+
+		jsonEncoder = json.NewEncoder(w)
+		return nil
+	}
+
+	err = app.models.Guns.Export(r.Context(), gunFilters, filters, func(gun *models.Gun) error {
+		if !started {
+			err := start()
+			if err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if format == "csv" {
+			err = writeGunCSV(csvWriter, gun)
+		} else {
+			err = jsonEncoder.Encode(gun)
+		}
+		if err != nil {
+			return err
+		}
+
+		count++
+		if count%exportFlushEvery == 0 {
+			if csvWriter != nil {
+				csvWriter.Flush()
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		if !started {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.logError(r, err)
+		return
+	}
+
+	// An export without any rows still gets its headers, and for CSV its header row.
+	if !started {
+		err = start()
+		if err != nil {
+			app.logError(r, err)
+			return
+		}
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			app.logError(r, err)
+		}
+	}
+}
+
+// writeGunCSV writes a gun as a CSV record with the exportColumns. Text fields are escaped with
+// csvText, which the import undoes.
+func writeGunCSV(cw *csv.Writer, gun *models.Gun) error {
+	specs, err := json.Marshal(gun.Specs)
+	if err != nil {
+		return err
+	}
+
+	sku := ""
+	if gun.SKU != nil {
+		sku = *gun.SKU
+	}
+
+	return cw.Write([]string{
+		strconv.FormatInt(gun.ID, 10),
+		csvText(sku),
+		csvText(gun.Name),
+		gun.Price.String(),
+		strconv.Itoa(gun.Damage),
+		csvText(gun.Manufacturer),
+		csvText(gun.Category),
+		string(specs),
+		strconv.Itoa(gun.QuantityOnHand),
+		gun.CreatedAt.Format(time.RFC3339),
+		gun.UpdatedAt.Format(time.RFC3339),
+		strconv.Itoa(gun.Version),
+	})
+}
@@ -0,0 +1,595 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+func (app *application) listGuns(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	gunFilters, filters, err := app.readGunFilters(r, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if models.ValidateGunFilters(v, gunFilters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The presence of the cursor parameter switches the listing to keyset pagination. An empty
+	// cursor requests the first page.
+	if r.URL.Query().Has("cursor") {
+		app.listGunsByCursor(w, r, gunFilters, filters)
+		return
+	}
+
+	guns, totalRecords, err := app.models.Guns.GetAll(r.Context(), gunFilters, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.setCurrentPrices(r.Context(), guns...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, guns, totalRecords, filters)
+}
+
+// listGunsByCursor writes a page of guns using keyset pagination. The cursor is only valid
+// for the sort and order it was issued with, since the sort key it holds is meaningless for any
+// other ordering. The response holds the pageSize and data of a paginated response, and a
+// next_cursor on every page but the last.
+func (app *application) listGunsByCursor(w http.ResponseWriter, r *http.Request, gunFilters models.GunFilters, filters models.Filters) {
+	v := validator.New()
+
+	v.Check(validator.In(filters.Sort, models.GunKeysetSorts...), "sort", "is not supported with the cursor parameter")
+
+	var after *models.Cursor
+
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		cursor, err := models.DecodeCursor(s)
+		if err != nil {
+			v.AddError("cursor", "must be a cursor returned by a previous request")
+		} else if cursor.Sort != filters.Sort || cursor.Order != strings.ToLower(filters.Order) {
+			v.AddError("cursor", "does not match the sort and order parameters")
+		}
+
+		after = cursor
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	guns, next, err := app.models.Guns.GetAllAfter(r.Context(), gunFilters, filters, after)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.setCurrentPrices(r.Context(), guns...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{"pageSize": filters.PageSize, "data": guns}
+
+	if next != nil {
+		response["next_cursor"] = next.Encode()
+	}
+
+	app.writeJSON(w, http.StatusOK, response, nil)
+}
+
+// gunQueryParams are the query string parameters of listGuns that aren't spec filters.
+var gunQueryParams = map[string]bool{
+	"name":            true,
+	"min_price":       true,
+	"max_price":       true,
+	"min_damage":      true,
+	"max_damage":      true,
+	"manufacturer_id": true,
+	"category_id":     true,
+	"page":            true,
+	"pageSize":        true,
+	"sort":            true,
+	"order":           true,
+	"cursor":          true,
+	"format":          true,
+}
+
+// readGunFilters reads the catalog filters and the pagination parameters from the query string.
+// Any problems are recorded in the provided Validator instance, including invalid pagination and
+// sort values. Any extraSorts are accepted as sort values on top of the ones every listing has.
+// The returned error is only set when the spec keys of the categories couldn't be loaded.
+func (app *application) readGunFilters(r *http.Request, v *validator.Validator, extraSorts ...string) (models.GunFilters, models.Filters, error) {
+	var gunFilters models.GunFilters
+
+	qs := r.URL.Query()
+
+	gunFilters.Name = app.readStrings(qs, "name", "")
+
+	// The range bounds are optional, so we only read them when they are present in the query
+	// string and leave them nil otherwise.
+	if qs.Has("min_price") {
+		minPrice := app.readMoney(qs, "min_price", models.Money{}, v)
+		gunFilters.MinPrice = &minPrice
+	}
+	if qs.Has("max_price") {
+		maxPrice := app.readMoney(qs, "max_price", models.Money{}, v)
+		gunFilters.MaxPrice = &maxPrice
+	}
+	if qs.Has("min_damage") {
+		minDamage := app.readInt(qs, "min_damage", 0, v)
+		gunFilters.MinDamage = &minDamage
+	}
+	if qs.Has("max_damage") {
+		maxDamage := app.readInt(qs, "max_damage", 0, v)
+		gunFilters.MaxDamage = &maxDamage
+	}
+	if qs.Has("manufacturer_id") {
+		manufacturerID := int64(app.readInt(qs, "manufacturer_id", 0, v))
+		gunFilters.ManufacturerID = &manufacturerID
+	}
+	if qs.Has("category_id") {
+		categoryID := int64(app.readInt(qs, "category_id", 0, v))
+		gunFilters.CategoryID = &categoryID
+	}
+
+	// Any other parameter filters on a spec, either by value (caliber=9mm) or by range with a
+	// _gt, _gte, _lt or _lte suffix (capacity_gte=15). The spec has to be in the spec schema of
+	// some category, so that a mistyped parameter is reported instead of matching nothing.
+	// The parameters are read in a stable order so that the same query string always builds
+	// the same query.
+	params := make([]string, 0, len(qs))
+	for param := range qs {
+		if !gunQueryParams[param] {
+			params = append(params, param)
+		}
+	}
+	sort.Strings(params)
+
+	var specKeys map[string]bool
+	if len(params) > 0 {
+		var err error
+		specKeys, err = app.models.Categories.GetSpecKeys(r.Context())
+		if err != nil {
+			return gunFilters, models.Filters{}, err
+		}
+	}
+
+	for _, param := range params {
+		spec := models.ParseSpecFilter(param, qs.Get(param))
+		models.ValidateSpecFilter(v, param, spec, specKeys)
+		gunFilters.Specs = append(gunFilters.Specs, spec)
+	}
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 10, v),
+		Sort:         app.readStrings(qs, "sort", "id"),
+		Order:        app.readStrings(qs, "order", "asc"),
+		SortSafelist: append([]string{"id", "name", "price", "damage", "manufacturer", "category"}, extraSorts...),
+	}
+
+	models.ValidateFilters(v, filters)
+
+	return gunFilters, filters, nil
+}
+
+func (app *application) createGun(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		SKU            *string      `json:"sku"`
+		Name           string       `json:"name"`
+		Price          models.Money `json:"price"`
+		Damage         int          `json:"damage"`
+		ManufacturerID *int64       `json:"manufacturer_id"`
+		CategoryID     *int64       `json:"category_id"`
+		Specs          models.Specs `json:"specs"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	gun := &models.Gun{
+		SKU:            input.SKU,
+		Name:           input.Name,
+		Price:          input.Price,
+		Damage:         input.Damage,
+		ManufacturerID: input.ManufacturerID,
+		CategoryID:     input.CategoryID,
+		Specs:          input.Specs,
+	}
+
+	if gun.Specs == nil {
+		gun.Specs = models.Specs{}
+	}
+
+	v := validator.New()
+
+	models.ValidateGun(v, gun)
+
+	err = app.validateGunSpecs(r, v, gun)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Guns.Insert(r.Context(), gun, &user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateSKU):
+			v.AddError("sku", "a gun with this SKU already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrManufacturerNotFound):
+			v.AddError("manufacturer_id", "manufacturer does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrCategoryNotFound):
+			v.AddError("category_id", "category does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{"gun": gun}, nil)
+}
+
+func (app *application) getGunByID(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	gun, err := app.models.Guns.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.setCurrentPrices(r.Context(), gun)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"gun": gun}, nil)
+}
+
+func (app *application) updateGun(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Fetch the existing gun record from the database, sending a 404 Not Found response to the
+	// client if we couldn't find a matching record.
+	gun, err := app.models.Guns.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Use pointers for the input fields so that we can tell a missing field apart from its zero
+	// value, and only update the fields that were provided. The optional version field lets a
+	// client make sure it is updating the same version of the gun that it last read. A
+	// manufacturer_id or category_id of 0 clears the gun's manufacturer or category, and specs
+	// replaces all of the gun's specs.
+	var input struct {
+		SKU            *string       `json:"sku"`
+		Name           *string       `json:"name"`
+		Price          *models.Money `json:"price"`
+		Damage         *int          `json:"damage"`
+		ManufacturerID *int64        `json:"manufacturer_id"`
+		CategoryID     *int64        `json:"category_id"`
+		Specs          models.Specs  `json:"specs"`
+		Version        *int          `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != gun.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.SKU != nil {
+		gun.SKU = input.SKU
+		if *input.SKU == "" {
+			gun.SKU = nil
+		}
+	}
+	if input.Name != nil {
+		gun.Name = *input.Name
+	}
+	if input.Price != nil && !input.Price.Equal(gun.Price) {
+		gun.Price = *input.Price
+	}
+	if input.Damage != nil {
+		gun.Damage = *input.Damage
+	}
+	if input.ManufacturerID != nil {
+		gun.ManufacturerID = input.ManufacturerID
+		if *input.ManufacturerID == 0 {
+			gun.ManufacturerID = nil
+		}
+	}
+	if input.CategoryID != nil {
+		gun.CategoryID = input.CategoryID
+		if *input.CategoryID == 0 {
+			gun.CategoryID = nil
+		}
+	}
+	if input.Specs != nil {
+		gun.Specs = input.Specs
+	}
+
+	v := validator.New()
+
+	models.ValidateGun(v, gun)
+
+	err = app.validateGunSpecs(r, v, gun)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Intercept any ErrEditConflict error and call the editConflictResponse helper.
+	err = app.models.Guns.Update(r.Context(), gun, app.contextUserID(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, models.ErrDuplicateSKU):
+			v.AddError("sku", "a gun with this SKU already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrManufacturerNotFound):
+			v.AddError("manufacturer_id", "manufacturer does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrCategoryNotFound):
+			v.AddError("category_id", "category does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"gun": gun}, nil)
+}
+
+// validateGunSpecs checks the specs of the gun against the spec schema of its category,
+// recording any problems in v. A missing category is recorded as a category_id error.
+func (app *application) validateGunSpecs(r *http.Request, v *validator.Validator, gun *models.Gun) error {
+	var schema models.SpecSchema
+
+	if gun.CategoryID != nil && *gun.CategoryID > 0 {
+		category, err := app.models.Categories.Get(r.Context(), *gun.CategoryID)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrRecordNotFound):
+				v.AddError("category_id", "category does not exist")
+				return nil
+			default:
+				return err
+			}
+		}
+
+		schema = category.SpecSchema
+	}
+
+	models.ValidateSpecs(v, gun.Specs, schema)
+
+	return nil
+}
+
+func (app *application) deleteGun(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Guns.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listTrashedGuns lists the guns in the trash. It takes the same filters as listGuns, and can
+// also sort by deleted_at.
+func (app *application) listTrashedGuns(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	gunFilters, filters, err := app.readGunFilters(r, v, "deleted_at")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	gunFilters.Deleted = true
+
+	if models.ValidateGunFilters(v, gunFilters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	guns, totalRecords, err := app.models.Guns.GetAll(r.Context(), gunFilters, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, guns, totalRecords, filters)
+}
+
+// restoreGun takes a gun out of the trash.
+func (app *application) restoreGun(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	gun, err := app.models.Guns.Restore(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"gun": gun}, nil)
+}
+
+// purgeGun permanently removes a gun from the trash, along with its image files.
+func (app *application) purgeGun(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// The image records go with the gun, but their files have to be removed from storage once
+	// the gun is gone.
+	images, err := app.models.Images.GetAllForGun(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Guns.Purge(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrRecordInUse):
+			app.failedValidationResponse(w, r, map[string]string{
+				"id": "gun has stock, sales or unit history and can't be purged",
+			})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteImageFiles(images...)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// searchGuns runs a relevance ranked full-text and fuzzy search over the catalog. With
+// mode=autocomplete it returns the top gun name suggestions for the q prefix instead.
+func (app *application) searchGuns(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	q := app.readStrings(qs, "q", "")
+	mode := app.readStrings(qs, "mode", "results")
+
+	models.ValidateSearchQuery(v, q)
+	v.Check(validator.In(mode, "results", "autocomplete"), "mode", "must be results or autocomplete")
+
+	if mode == "autocomplete" {
+		limit := app.readInt(qs, "limit", 10, v)
+		v.Check(limit > 0, "limit", "must be greater than zero")
+		v.Check(limit <= 25, "limit", "must be a maximum of 25")
+
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		suggestions, err := app.models.Guns.Suggest(r.Context(), q, limit)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+		return
+	}
+
+	// Search results are always ordered by relevance, so relevance is the only sort value.
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 10, v),
+		Sort:         "relevance",
+		Order:        "desc",
+		SortSafelist: []string{"relevance"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	guns, totalRecords, err := app.models.Guns.Search(r.Context(), q, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.setCurrentPrices(r.Context(), guns...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, guns, totalRecords, filters)
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+const (
+	// maxImportBytes is the largest CSV file accepted by the import endpoint.
+	maxImportBytes = 10 << 20
+
+	// maxImportRows is the largest number of rows in a single import, which all have to fit in
+	// one transaction.
+	maxImportRows = 10_000
+)
+
+// importColumns are the columns that a catalog CSV file can have, in any order. The header row
+// must include name and price; the other columns are optional.
+var importColumns = []string{"sku", "name", "price", "damage", "manufacturer", "category"}
+
+// importResult reports what an import did, or with dry_run what it would do.
+type importResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Rows    []importResultRow `json:"rows"`
+}
+
+type importResultRow struct {
+	Line   int     `json:"line"`
+	Action string  `json:"action"`
+	GunID  int64   `json:"gun_id,omitempty"`
+	SKU    *string `json:"sku"`
+	Name   string  `json:"name"`
+}
+
+// readGunCSV parses a catalog CSV file into import rows, running each row through the same
+// validation as createGun. Problems with a row are recorded in v under "rows[LINE].field", where
+// LINE is the line number in the file. Problems with the file as a whole, such as malformed CSV
+// or a bad header, are returned as an error.
+func readGunCSV(r io.Reader, v *validator.Validator) ([]*models.GunImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil, errors.New("body must not be empty")
+		default:
+			return nil, fmt.Errorf("body contains badly-formed CSV: %w", err)
+		}
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !validator.In(name, importColumns...) {
+			return nil, fmt.Errorf("header contains unknown column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("header contains column %q more than once", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range []string{"name", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("header must contain a %s column", name)
+		}
+	}
+
+	var rows []*models.GunImportRow
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("body contains badly-formed CSV: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("body must not contain more than %d rows", maxImportRows)
+		}
+
+		rows = append(rows, readImportRow(record, columns, line, v))
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("body must contain at least one row")
+	}
+
+	return rows, nil
+}
+
+// readImportRow converts a CSV record into an import row and validates it.
+func readImportRow(record []string, columns map[string]int, line int, v *validator.Validator) *models.GunImportRow {
+	row := &models.GunImportRow{Line: line}
+	prefix := fmt.Sprintf("rows[%d].", line)
+
+	// field returns the trimmed value of a column, and whether the file has the column at all.
+	// Values escaped by the export are read back as they were.
+	field := func(name string) (string, bool) {
+		i, ok := columns[name]
+		if !ok {
+			return "", false
+		}
+		return strings.TrimSpace(csvUnescapeText(record[i])), true
+	}
+
+	if sku, ok := field("sku"); ok && sku != "" {
+		row.Gun.SKU = &sku
+	}
+
+	row.Gun.Name, _ = field("name")
+
+	price, _ := field("price")
+	p, err := models.ParseMoney(price)
+	if err != nil {
+		v.AddError(prefix+"price", "must be an amount with at most two decimal places")
+	}
+	row.Gun.Price = p
+
+	if damage, ok := field("damage"); ok && damage != "" {
+		d, err := strconv.Atoi(damage)
+		if err != nil {
+			v.AddError(prefix+"damage", "must be an integer value")
+		}
+		row.Damage = &d
+		row.Gun.Damage = d
+	}
+
+	if manufacturer, ok := field("manufacturer"); ok {
+		row.Manufacturer = &manufacturer
+	}
+	if category, ok := field("category"); ok {
+		row.Category = &category
+	}
+
+	rowValidator := validator.New()
+	models.ValidateGun(rowValidator, &row.Gun)
+
+	for key, message := range rowValidator.Errors {
+		v.AddError(prefix+key, message)
+	}
+
+	return row
+}
+
+// importGuns applies the rows and summarizes the outcome. If some rows can't be applied, their
+// problems are recorded in v and nothing is written.
+func (app *application) importGuns(ctx context.Context, rows []*models.GunImportRow, userID *int64, dryRun bool, v *validator.Validator) (*importResult, error) {
+	err := app.models.Guns.Import(ctx, rows, userID, dryRun)
+	if err != nil {
+		var importErr *models.ImportError
+
+		switch {
+		case errors.As(err, &importErr):
+			for _, rowErr := range importErr.Rows {
+				prefix := fmt.Sprintf("rows[%d].", rowErr.Index)
+
+				switch {
+				case errors.Is(rowErr, models.ErrManufacturerNotFound):
+					v.AddError(prefix+"manufacturer", "manufacturer does not exist")
+				case errors.Is(rowErr, models.ErrCategoryNotFound):
+					v.AddError(prefix+"category", "category does not exist")
+				case errors.Is(rowErr, models.ErrAmbiguousName):
+					v.AddError(prefix+"name", "matches more than one gun, use a sku instead")
+				case errors.Is(rowErr, models.ErrDuplicateSKU):
+					v.AddError(prefix+"sku", "a gun with this SKU already exists")
+				case errors.Is(rowErr, models.ErrInvalidSpecs):
+					v.AddError(prefix+"category", "the gun's specs don't match the category's spec schema")
+				}
+			}
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	result := &importResult{DryRun: dryRun, Rows: []importResultRow{}}
+
+	for _, row := range rows {
+		resultRow := importResultRow{
+			Line:   row.Line,
+			Action: row.Action,
+			SKU:    row.Gun.SKU,
+			Name:   row.Gun.Name,
+		}
+
+		// The ids handed out during a dry run are rolled back with everything else.
+		if !dryRun {
+			resultRow.GunID = row.Gun.ID
+		}
+
+		if row.Action == models.ImportCreate {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+
+		result.Rows = append(result.Rows, resultRow)
+	}
+
+	return result, nil
+}
+
+// importGunsHandler imports a CSV file of guns sent as the request body. With dry_run=true the
+// rows are validated and matched against the catalog, but nothing is written.
+func (app *application) importGunsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	dryRun := app.readStrings(qs, "dry_run", "false")
+	v.Check(validator.In(dryRun, "true", "false"), "dry_run", "must be true or false")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	rows, err := readGunCSV(r.Body, v)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			err = fmt.Errorf("body must not be larger than %d bytes", maxImportBytes)
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	result, err := app.importGuns(r.Context(), rows, app.contextUserID(r), dryRun == "true", v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"import": result}, nil)
+}
+
+// runImportCommand implements the import command, which imports a CSV file of guns from the
+// command line:
+//
+//	gun import [-dry-run] FILE
+//
+// FILE may be - to read from standard input. The result, or the validation errors in the same
+// shape as the import endpoint, is written to standard output as JSON. It returns the exit
+// status of the command.
+func (app *application) runImportCommand(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "validate the file without importing anything")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: import [-dry-run] FILE")
+		return 2
+	}
+
+	in := os.Stdin
+	if name := flags.Arg(0); name != "-" {
+		in, err = os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer in.Close()
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+
+	v := validator.New()
+
+	rows, err := readGunCSV(in, v)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var result *importResult
+
+	if v.Valid() {
+		result, err = app.importGuns(context.Background(), rows, nil, *dryRun, v)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if !v.Valid() {
+		enc.Encode(envelope{"error": v.Errors})
+		return 1
+	}
+
+	enc.Encode(envelope{"import": result})
+	return 0
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "", want: ""},
+		{input: "Glock 17", want: "Glock 17"},
+		{input: "=HYPERLINK(\"http://x\")", want: "'=HYPERLINK(\"http://x\")"},
+		{input: "+1-555-0100", want: "'+1-555-0100"},
+		{input: "-2+3", want: "'-2+3"},
+		{input: "@SUM(A1)", want: "'@SUM(A1)"},
+		{input: "\t=1", want: "'\t=1"},
+		{input: "\r=1", want: "'\r=1"},
+		{input: "a=b", want: "a=b"},
+		{input: "'quoted'", want: "'quoted'"},
+	}
+
+	for _, tt := range tests {
+		if got := csvText(tt.input); got != tt.want {
+			t.Errorf("csvText(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		if got := csvUnescapeText(tt.want); got != tt.input {
+			t.Errorf("csvUnescapeText(%q) = %q, want %q", tt.want, got, tt.input)
+		}
+	}
+}
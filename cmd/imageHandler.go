@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+
+	_ "image/gif"
+
+	"github.com/E4kere/Project/pkg/imaging"
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/storage"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+const (
+	// maxImageBytes is the largest image file that can be uploaded.
+	maxImageBytes = 10 << 20
+
+	// maxImagePixels bounds the decoded size of an image, so that a small, highly compressed
+	// file can't make us allocate gigabytes of memory.
+	maxImagePixels = 40_000_000
+
+	// thumbnailSize is the length of the longest side of a thumbnail in pixels.
+	thumbnailSize = 320
+)
+
+// imageExtensions maps the accepted image content types to their file extensions.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// readImage reads the file from the "image" field of a multipart/form-data request body. Like
+// readJSON, it limits the size of the body and returns plain-english errors for the client.
+func (app *application) readImage(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	// Leave some room for the multipart headers and boundaries on top of the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageBytes+1<<16)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New("body must be multipart/form-data")
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			switch {
+			case errors.Is(err, io.EOF):
+				return nil, errors.New("body must contain an image field")
+			case err.Error() == "http: request body too large":
+				return nil, fmt.Errorf("image must not be larger than %d bytes", maxImageBytes)
+			default:
+				return nil, err
+			}
+		}
+
+		if part.FormName() != "image" {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxImageBytes+1))
+		if err != nil {
+			switch {
+			case err.Error() == "http: request body too large":
+				return nil, fmt.Errorf("image must not be larger than %d bytes", maxImageBytes)
+			default:
+				return nil, err
+			}
+		}
+
+		if len(data) > maxImageBytes {
+			return nil, fmt.Errorf("image must not be larger than %d bytes", maxImageBytes)
+		}
+
+		return data, nil
+	}
+}
+
+// uploadGunImageHandler adds an image to a gun. The content type is sniffed from the file rather
+// than trusted from the request, and a thumbnail is generated before anything is stored.
+func (app *application) uploadGunImageHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	data, err := app.readImage(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+
+	v.Check(len(data) > 0, "image", "must not be empty")
+	v.Check(ok, "image", "must be a JPEG, PNG or GIF image")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > maxImagePixels {
+		v.AddError("image", fmt.Sprintf("must be a valid image of at most %d pixels", maxImagePixels))
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		v.AddError("image", "must be a valid image")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// JPEG thumbnails are much smaller, but only PNG keeps the transparency of PNG and GIF
+	// images.
+	var thumbnail bytes.Buffer
+	thumbnailExt := ".png"
+
+	if contentType == "image/jpeg" {
+		thumbnailExt = ".jpg"
+		err = jpeg.Encode(&thumbnail, imaging.Thumbnail(img, thumbnailSize), &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&thumbnail, imaging.Thumbnail(img, thumbnailSize))
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	name, err := randomName()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	gunImage := &models.GunImage{
+		GunID:        gunID,
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        config.Width,
+		Height:       config.Height,
+		StorageKey:   fmt.Sprintf("guns/%d/%s%s", gunID, name, ext),
+		ThumbnailKey: fmt.Sprintf("guns/%d/%s_thumb%s", gunID, name, thumbnailExt),
+	}
+
+	err = app.storage.Put(r.Context(), gunImage.StorageKey, bytes.NewReader(data))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.storage.Put(r.Context(), gunImage.ThumbnailKey, &thumbnail)
+	if err != nil {
+		app.deleteImageFiles(gunImage)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Images.Insert(r.Context(), gunImage)
+	if err != nil {
+		app.deleteImageFiles(gunImage)
+
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/images/%d", gunImage.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"image": gunImage}, headers)
+}
+
+// randomName returns a random file name, so that image URLs can't be guessed from the gun id.
+func randomName() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// deleteImageFiles removes the files of an image from storage. Failures are only logged, since
+// the image record is gone or was never created by the time this is called.
+func (app *application) deleteImageFiles(images ...*models.GunImage) {
+	for _, img := range images {
+		for _, key := range []string{img.StorageKey, img.ThumbnailKey} {
+			err := app.storage.Delete(context.Background(), key)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"storage_key": key})
+			}
+		}
+	}
+}
+
+// listGunImagesHandler returns the images of a gun in display order.
+func (app *application) listGunImagesHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	images, err := app.models.Images.GetAllForGun(r.Context(), gunID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"images": images}, nil)
+}
+
+// reorderGunImagesHandler sets the display order of the images of a gun. The request lists the
+// ids of all of the gun's images in their new order.
+func (app *application) reorderGunImagesHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ImageIDs []int64 `json:"image_ids"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	err = app.models.Images.Reorder(r.Context(), gunID, input.ImageIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidImageOrder):
+			v.AddError("image_ids", "must list every image of the gun exactly once")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	images, err := app.models.Images.GetAllForGun(r.Context(), gunID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"images": images}, nil)
+}
+
+func (app *application) showImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	gunImage, err := app.models.Images.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"image": gunImage}, nil)
+}
+
+// serveImageHandler writes the image file, or its thumbnail if thumbnail is true.
+func (app *application) serveImageHandler(thumbnail bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r)
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+
+		gunImage, err := app.models.Images.Get(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		key, contentType := gunImage.StorageKey, gunImage.ContentType
+		if thumbnail {
+			key, contentType = gunImage.ThumbnailKey, "image/png"
+			if gunImage.ContentType == "image/jpeg" {
+				contentType = "image/jpeg"
+			}
+		}
+
+		f, err := app.storage.Open(r.Context(), key)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		if !thumbnail {
+			w.Header().Set("Content-Length", strconv.FormatInt(gunImage.Size, 10))
+		}
+
+		_, err = io.Copy(w, f)
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+}
+
+// setPrimaryImageHandler makes an image the primary image of its gun.
+func (app *application) setPrimaryImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	gunImage, err := app.models.Images.SetPrimary(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"image": gunImage}, nil)
+}
+
+func (app *application) deleteImageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	gunImage, err := app.models.Images.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteImageFiles(gunImage)
+
+	w.WriteHeader(http.StatusNoContent)
+}
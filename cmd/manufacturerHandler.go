@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+func (app *application) createManufacturerHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name    string `json:"name"`
+		Country string `json:"country"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	manufacturer := &models.Manufacturer{
+		Name:    strings.TrimSpace(input.Name),
+		Country: strings.TrimSpace(input.Country),
+	}
+
+	v := validator.New()
+
+	if models.ValidateManufacturer(v, manufacturer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Manufacturers.Insert(r.Context(), manufacturer)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateName):
+			v.AddError("name", "a manufacturer with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/manufacturers/%d", manufacturer.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"manufacturer": manufacturer}, headers)
+}
+
+func (app *application) showManufacturerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	manufacturer, err := app.models.Manufacturers.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"manufacturer": manufacturer}, nil)
+}
+
+func (app *application) listManufacturersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "name"),
+		Order:        app.readStrings(qs, "order", "asc"),
+		SortSafelist: []string{"id", "name", "country"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	manufacturers, totalRecords, err := app.models.Manufacturers.GetAll(r.Context(), filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, manufacturers, totalRecords, filters)
+}
+
+func (app *application) updateManufacturerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	manufacturer, err := app.models.Manufacturers.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name    *string `json:"name"`
+		Country *string `json:"country"`
+		Version *int    `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != manufacturer.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.Name != nil {
+		manufacturer.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Country != nil {
+		manufacturer.Country = strings.TrimSpace(*input.Country)
+	}
+
+	v := validator.New()
+
+	if models.ValidateManufacturer(v, manufacturer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Manufacturers.Update(r.Context(), manufacturer)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, models.ErrDuplicateName):
+			v.AddError("name", "a manufacturer with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"manufacturer": manufacturer}, nil)
+}
+
+func (app *application) deleteManufacturerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Manufacturers.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrRecordInUse):
+			app.failedValidationResponse(w, r, map[string]string{
+				"id": "manufacturer still has guns and can't be deleted",
+			})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// listGunPricesHandler returns the price history of a gun, newest first by default. With the at
+// parameter, an RFC 3339 time, only the history up to that time is returned, so that the first
+// entry is the price the gun had at that time.
+func (app *application) listGunPricesHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Guns.Get(r.Context(), gunID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var at *time.Time
+	if s := app.readStrings(qs, "at", ""); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			v.AddError("at", "must be an RFC 3339 time, e.g. 2024-03-15T12:00:00Z")
+		}
+		at = &t
+	}
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "changed_at"),
+		Order:        app.readStrings(qs, "order", "desc"),
+		SortSafelist: []string{"changed_at"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	changes, totalRecords, err := app.models.Prices.GetHistory(r.Context(), gunID, at, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, changes, totalRecords, filters)
+}
+
+// schedulePriceHandler schedules a price change of a gun for a future time. It is applied by the
+// scheduled prices worker, see applyScheduledPrices.
+func (app *application) schedulePriceHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Price       models.Money `json:"price"`
+		EffectiveAt time.Time    `json:"effective_at"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sp := &models.ScheduledPrice{
+		GunID:       gunID,
+		Price:       input.Price,
+		EffectiveAt: input.EffectiveAt,
+		UserID:      app.contextUserID(r),
+	}
+
+	v := validator.New()
+
+	if models.ValidateScheduledPrice(v, sp); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Prices.Schedule(r.Context(), sp)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/guns/%d/prices/scheduled", gunID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"scheduled_price": sp}, headers)
+}
+
+// listScheduledPricesHandler returns the pending scheduled price changes of a gun.
+func (app *application) listScheduledPricesHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	scheduled, err := app.models.Prices.GetScheduled(r.Context(), gunID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"scheduled_prices": scheduled}, nil)
+}
+
+// cancelScheduledPriceHandler cancels a pending scheduled price change.
+func (app *application) cancelScheduledPriceHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	sp, err := app.models.Prices.Cancel(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrScheduledPriceNotPending):
+			app.failedValidationResponse(w, r, map[string]string{
+				"id": "the price change was already applied or cancelled",
+			})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"scheduled_price": sp}, nil)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// setCurrentPrices fills in the current price of the guns, with the promotions running now.
+func (app *application) setCurrentPrices(ctx context.Context, guns ...*models.Gun) error {
+	if len(guns) == 0 {
+		return nil
+	}
+
+	promotions, err := app.models.Promotions.GetActive(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, gun := range guns {
+		price := models.CurrentPrice(gun, promotions)
+		gun.CurrentPrice = &price
+	}
+
+	return nil
+}
+
+// promotionWriteResponse sends the response for the errors of promotion inserts and updates.
+func (app *application) promotionWriteResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	case errors.Is(err, models.ErrPromotionTargetNotFound):
+		app.failedValidationResponse(w, r, map[string]string{
+			"targets": "gun_id, category_id or manufacturer_id does not exist",
+		})
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createPromotionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name           string        `json:"name"`
+		PercentOff     *int          `json:"percent_off"`
+		AmountOff      *models.Money `json:"amount_off"`
+		GunID          *int64        `json:"gun_id"`
+		CategoryID     *int64        `json:"category_id"`
+		ManufacturerID *int64        `json:"manufacturer_id"`
+		MinQuantity    *int          `json:"min_quantity"`
+		Stackable      bool          `json:"stackable"`
+		StartsAt       time.Time     `json:"starts_at"`
+		EndsAt         time.Time     `json:"ends_at"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	promotion := &models.Promotion{
+		Name:           strings.TrimSpace(input.Name),
+		PercentOff:     input.PercentOff,
+		AmountOff:      input.AmountOff,
+		GunID:          input.GunID,
+		CategoryID:     input.CategoryID,
+		ManufacturerID: input.ManufacturerID,
+		MinQuantity:    1,
+		Stackable:      input.Stackable,
+		StartsAt:       input.StartsAt,
+		EndsAt:         input.EndsAt,
+	}
+	if input.MinQuantity != nil {
+		promotion.MinQuantity = *input.MinQuantity
+	}
+
+	v := validator.New()
+
+	if models.ValidatePromotion(v, promotion); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Promotions.Insert(r.Context(), promotion)
+	if err != nil {
+		app.promotionWriteResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/promotions/%d", promotion.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"promotion": promotion}, headers)
+}
+
+func (app *application) showPromotionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	promotion, err := app.models.Promotions.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"promotion": promotion}, nil)
+}
+
+// listPromotionsHandler lists the promotions. With active=true only the promotions running now
+// are listed.
+func (app *application) listPromotionsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	active := app.readStrings(qs, "active", "false")
+	v.Check(validator.In(active, "true", "false"), "active", "must be true or false")
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "starts_at"),
+		Order:        app.readStrings(qs, "order", "desc"),
+		SortSafelist: []string{"id", "name", "starts_at", "ends_at"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var activeAt *time.Time
+	if active == "true" {
+		now := time.Now()
+		activeAt = &now
+	}
+
+	promotions, totalRecords, err := app.models.Promotions.GetAll(r.Context(), activeAt, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, promotions, totalRecords, filters)
+}
+
+// updatePromotionHandler updates a promotion. A target is cleared by setting it to 0, and the
+// discount is switched between a percentage and a fixed amount by providing the other one.
+func (app *application) updatePromotionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	promotion, err := app.models.Promotions.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name           *string       `json:"name"`
+		PercentOff     *int          `json:"percent_off"`
+		AmountOff      *models.Money `json:"amount_off"`
+		GunID          *int64        `json:"gun_id"`
+		CategoryID     *int64        `json:"category_id"`
+		ManufacturerID *int64        `json:"manufacturer_id"`
+		MinQuantity    *int          `json:"min_quantity"`
+		Stackable      *bool         `json:"stackable"`
+		StartsAt       *time.Time    `json:"starts_at"`
+		EndsAt         *time.Time    `json:"ends_at"`
+		Version        *int          `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != promotion.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.Name != nil {
+		promotion.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.PercentOff != nil || input.AmountOff != nil {
+		promotion.PercentOff, promotion.AmountOff = input.PercentOff, input.AmountOff
+	}
+	if input.GunID != nil {
+		promotion.GunID = input.GunID
+		if *input.GunID == 0 {
+			promotion.GunID = nil
+		}
+	}
+	if input.CategoryID != nil {
+		promotion.CategoryID = input.CategoryID
+		if *input.CategoryID == 0 {
+			promotion.CategoryID = nil
+		}
+	}
+	if input.ManufacturerID != nil {
+		promotion.ManufacturerID = input.ManufacturerID
+		if *input.ManufacturerID == 0 {
+			promotion.ManufacturerID = nil
+		}
+	}
+	if input.MinQuantity != nil {
+		promotion.MinQuantity = *input.MinQuantity
+	}
+	if input.Stackable != nil {
+		promotion.Stackable = *input.Stackable
+	}
+	if input.StartsAt != nil {
+		promotion.StartsAt = *input.StartsAt
+	}
+	if input.EndsAt != nil {
+		promotion.EndsAt = *input.EndsAt
+	}
+
+	v := validator.New()
+
+	if models.ValidatePromotion(v, promotion); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Promotions.Update(r.Context(), promotion)
+	if err != nil {
+		app.promotionWriteResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"promotion": promotion}, nil)
+}
+
+func (app *application) deletePromotionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Promotions.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// quoteHandler prices a basket of guns with the promotions running now. A single gun is quoted
+// as a basket of one.
+func (app *application) quoteHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Items []models.QuoteItem `json:"items"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateQuoteItems(v, input.Items); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	quote, err := app.models.Promotions.Quote(r.Context(), input.Items, time.Now())
+	if err != nil {
+		var itemErr *models.ItemError
+
+		switch {
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrRecordNotFound):
+			v.AddError(fmt.Sprintf("items[%d].gun_id", itemErr.Index), "gun not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"quote": quote}, nil)
+}
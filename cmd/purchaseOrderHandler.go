@@ -0,0 +1,343 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// purchaseOrderLineInput is a line of a purchase order as sent by the client.
+type purchaseOrderLineInput struct {
+	GunID           int64        `json:"gun_id"`
+	QuantityOrdered int          `json:"quantity_ordered"`
+	UnitCost        models.Money `json:"unit_cost"`
+}
+
+// purchaseOrderLines converts the lines sent by the client to purchase order lines.
+func purchaseOrderLines(input []purchaseOrderLineInput) []*models.PurchaseOrderLine {
+	lines := make([]*models.PurchaseOrderLine, len(input))
+	for i, line := range input {
+		lines[i] = &models.PurchaseOrderLine{
+			GunID:           line.GunID,
+			QuantityOrdered: line.QuantityOrdered,
+			UnitCost:        line.UnitCost,
+		}
+	}
+	return lines
+}
+
+// purchaseOrderWriteResponse sends the response for the errors of purchase order inserts and
+// updates.
+func (app *application) purchaseOrderWriteResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var itemErr *models.ItemError
+
+	switch {
+	case errors.Is(err, models.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	case errors.Is(err, models.ErrSupplierNotFound):
+		app.failedValidationResponse(w, r, map[string]string{"supplier_id": "supplier not found"})
+	case errors.As(err, &itemErr) && errors.Is(err, models.ErrRecordNotFound):
+		app.failedValidationResponse(w, r, map[string]string{
+			fmt.Sprintf("lines[%d].gun_id", itemErr.Index): "gun not found",
+		})
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createPurchaseOrderHandler creates a draft purchase order.
+func (app *application) createPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		SupplierID int64                    `json:"supplier_id"`
+		Note       string                   `json:"note"`
+		Lines      []purchaseOrderLineInput `json:"lines"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	order := &models.PurchaseOrder{
+		SupplierID: input.SupplierID,
+		Note:       strings.TrimSpace(input.Note),
+		UserID:     app.contextUserID(r),
+		Lines:      purchaseOrderLines(input.Lines),
+	}
+
+	v := validator.New()
+
+	if models.ValidatePurchaseOrder(v, order); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.PurchaseOrders.Insert(r.Context(), order)
+	if err != nil {
+		app.purchaseOrderWriteResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/purchase-orders/%d", order.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"purchase_order": order}, headers)
+}
+
+func (app *application) showPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	order, err := app.models.PurchaseOrders.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"purchase_order": order}, nil)
+}
+
+// listPurchaseOrdersHandler returns a page of purchase orders, newest first by default,
+// optionally filtered by status and supplier_id.
+func (app *application) listPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	orderFilters := models.PurchaseOrderFilters{
+		Status:     app.readStrings(qs, "status", ""),
+		SupplierID: int64(app.readInt(qs, "supplier_id", 0, v)),
+	}
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "created_at"),
+		Order:        app.readStrings(qs, "order", "desc"),
+		SortSafelist: []string{"id", "created_at", "updated_at", "sent_at"},
+	}
+
+	models.ValidatePurchaseOrderFilters(v, orderFilters)
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	orders, totalRecords, err := app.models.PurchaseOrders.GetAll(r.Context(), orderFilters, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, orders, totalRecords, filters)
+}
+
+// updatePurchaseOrderHandler edits a draft purchase order. Lines, when provided, replace all of
+// the lines of the order.
+func (app *application) updatePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	order, err := app.models.PurchaseOrders.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		SupplierID *int64                   `json:"supplier_id"`
+		Note       *string                  `json:"note"`
+		Lines      []purchaseOrderLineInput `json:"lines"`
+		Version    *int                     `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != order.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if order.Status != models.PurchaseOrderDraft {
+		app.failedValidationResponse(w, r, map[string]string{
+			"status": "only draft purchase orders can be edited",
+		})
+		return
+	}
+
+	if input.SupplierID != nil {
+		order.SupplierID = *input.SupplierID
+	}
+	if input.Note != nil {
+		order.Note = strings.TrimSpace(*input.Note)
+	}
+	if input.Lines != nil {
+		order.Lines = purchaseOrderLines(input.Lines)
+	}
+
+	v := validator.New()
+
+	if models.ValidatePurchaseOrder(v, order); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.PurchaseOrders.Update(r.Context(), order)
+	if err != nil {
+		app.purchaseOrderWriteResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"purchase_order": order}, nil)
+}
+
+// updatePurchaseOrderStatusHandler sends a purchase order to its supplier or cancels it.
+func (app *application) updatePurchaseOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidatePurchaseOrderStatus(v, input.Status); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	order, err := app.models.PurchaseOrders.UpdateStatus(r.Context(), id, input.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrInvalidTransition):
+			v.AddError("status", "the purchase order can't move to this status from its current status")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"purchase_order": order}, nil)
+}
+
+// receivePurchaseOrderHandler books a delivery against a purchase order, adding the received
+// guns to stock. Serialized firearms are received by listing the manufacturer and serial number
+// of each unit.
+func (app *application) receivePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Note  string `json:"note"`
+		Lines []struct {
+			LineID   int64 `json:"line_id"`
+			Quantity int   `json:"quantity"`
+			Units    []struct {
+				Manufacturer string `json:"manufacturer"`
+				SerialNumber string `json:"serial_number"`
+			} `json:"units"`
+		} `json:"lines"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	receipt := &models.Receipt{Note: strings.TrimSpace(input.Note)}
+	for _, line := range input.Lines {
+		// Serialized lines receive one item per unit, so the quantity may be left out.
+		if line.Quantity == 0 {
+			line.Quantity = len(line.Units)
+		}
+
+		item := &models.ReceiptLine{LineID: line.LineID, Quantity: line.Quantity}
+		for _, unit := range line.Units {
+			item.Units = append(item.Units, &models.GunUnit{
+				Manufacturer: strings.TrimSpace(unit.Manufacturer),
+				SerialNumber: models.NormalizeSerial(unit.SerialNumber),
+			})
+		}
+
+		receipt.Lines = append(receipt.Lines, item)
+	}
+
+	v := validator.New()
+
+	if models.ValidateReceipt(v, receipt); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	order, units, err := app.models.PurchaseOrders.Receive(r.Context(), id, receipt, app.contextUserID(r))
+	if err != nil {
+		var itemErr *models.ItemError
+
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound) && !errors.As(err, &itemErr):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrPurchaseOrderNotOpen):
+			v.AddError("status", "only sent or partially received purchase orders can be received")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrRecordNotFound):
+			v.AddError(fmt.Sprintf("lines[%d].line_id", itemErr.Index), "line not found on this purchase order")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrOverReceipt):
+			v.AddError(fmt.Sprintf("lines[%d].quantity", itemErr.Index), "exceeds the quantity outstanding")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrUnitRequired):
+			v.AddError(fmt.Sprintf("lines[%d].units", itemErr.Index), "gun is serialized, list the units received")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrDuplicateSerial):
+			v.AddError(fmt.Sprintf("lines[%d].units", itemErr.Index), "a unit with this manufacturer and serial number already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"purchase_order": order, "units": units}, nil)
+}
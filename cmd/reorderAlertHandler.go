@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// listReorderAlertsHandler returns a page of reorder alerts, newest first by default, filtered by
+// status (open unless given) and gun_id.
+func (app *application) listReorderAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	alertFilters := models.ReorderAlertFilters{
+		Status: app.readStrings(qs, "status", models.ReorderAlertOpen),
+		GunID:  int64(app.readInt(qs, "gun_id", 0, v)),
+	}
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "created_at"),
+		Order:        app.readStrings(qs, "order", "desc"),
+		SortSafelist: []string{"id", "created_at", "quantity_available"},
+	}
+
+	models.ValidateReorderAlertFilters(v, alertFilters)
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	alerts, totalRecords, err := app.models.ReorderAlerts.GetAll(r.Context(), alertFilters, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, alerts, totalRecords, filters)
+}
+
+// suggestPurchaseOrderHandler returns a draft purchase order from the supplier_id supplier for
+// the guns with open reorder alerts. Nothing is saved: the order can be adjusted and then created
+// through POST /purchase-orders.
+func (app *application) suggestPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	supplierID := int64(app.readInt(r.URL.Query(), "supplier_id", 0, v))
+	v.Check(supplierID > 0, "supplier_id", "must be provided")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err := app.models.Suppliers.Get(r.Context(), supplierID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("supplier_id", "does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	order, err := app.models.ReorderAlerts.SuggestPurchaseOrder(r.Context(), supplierID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"purchase_order": order}, nil)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/reports"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// defaultReportDays is the number of days, today included, that reports cover when no date range
+// is given.
+const defaultReportDays = 30
+
+// readReportParams reads the parameters shared by the reports from the query string.
+func (app *application) readReportParams(qs url.Values, defaultGroup string, v *validator.Validator) reports.Params {
+	today := models.Date{Time: time.Now().UTC().Truncate(24 * time.Hour)}
+	to := app.readDate(qs, "to", today, v)
+
+	return reports.Params{
+		From:           app.readDate(qs, "from", models.Date{Time: to.AddDate(0, 0, 1-defaultReportDays)}, v),
+		To:             to,
+		GroupBy:        app.readStrings(qs, "group_by", defaultGroup),
+		CategoryID:     int64(app.readInt(qs, "category_id", 0, v)),
+		ManufacturerID: int64(app.readInt(qs, "manufacturer_id", 0, v)),
+		Limit:          app.readInt(qs, "limit", 0, v),
+	}
+}
+
+// reportFormat picks the format of a report: the format parameter if present, otherwise
+// text/csv if the Accept header asks for it, and JSON by default.
+func (app *application) reportFormat(r *http.Request, v *validator.Validator) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		v.Check(validator.In(format, "json", "csv"), "format", "must be json or csv")
+		return format
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		if strings.TrimSpace(mediaType) == "text/csv" {
+			return "csv"
+		}
+	}
+
+	return "json"
+}
+
+// writeReport sends a report as JSON, or as a CSV attachment named after the report and its
+// date range.
+func (app *application) writeReport(w http.ResponseWriter, r *http.Request, format, name string, params reports.Params, report reports.Table) {
+	if format != "csv" {
+		app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s-%s.csv", name, params.From, params.To)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+
+	err := reports.WriteCSV(w, report)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// revenueReportHandler reports revenue, less returns, by day, week, month, category or
+// manufacturer.
+func (app *application) revenueReportHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	params := app.readReportParams(r.URL.Query(), reports.GroupDay, v)
+	format := app.reportFormat(r, v)
+
+	if reports.ValidateParams(v, params, reports.RevenueGroups...); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	report, err := app.reports.Revenue(r.Context(), params)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeReport(w, r, format, "revenue", params, report)
+}
+
+// topSellersReportHandler ranks the guns, categories or manufacturers that sold the most.
+func (app *application) topSellersReportHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	params := app.readReportParams(r.URL.Query(), reports.GroupGun, v)
+	format := app.reportFormat(r, v)
+
+	if reports.ValidateParams(v, params, reports.TopSellerGroups...); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	report, err := app.reports.TopSellers(r.Context(), params)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeReport(w, r, format, "top-sellers", params, report)
+}
+
+// slowMoversReportHandler lists the guns in stock that sold the least.
+func (app *application) slowMoversReportHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	params := app.readReportParams(r.URL.Query(), "", v)
+	format := app.reportFormat(r, v)
+
+	if reports.ValidateParams(v, params); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	report, err := app.reports.SlowMovers(r.Context(), params)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeReport(w, r, format, "slow-movers", params, report)
+}
+
+// stockValuationReportHandler values the stock on hand at cost and at retail.
+func (app *application) stockValuationReportHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	params := app.readReportParams(r.URL.Query(), reports.GroupGun, v)
+	format := app.reportFormat(r, v)
+
+	if reports.ValidateParams(v, params, reports.ValuationGroups...); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	report, err := app.reports.Valuation(r.Context(), params)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeReport(w, r, format, "stock-valuation", params, report)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// defaultReservationPeriod is how long stock is held when no expiry time is given.
+const defaultReservationPeriod = 48 * time.Hour
+
+// createReservationHandler holds stock of a gun, or a specific serialized unit, for a customer.
+func (app *application) createReservationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CustomerID int64      `json:"customer_id"`
+		GunID      int64      `json:"gun_id"`
+		UnitID     *int64     `json:"unit_id"`
+		Quantity   int        `json:"quantity"`
+		Note       string     `json:"note"`
+		ExpiresAt  *time.Time `json:"expires_at"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	reservation := &models.Reservation{
+		CustomerID: input.CustomerID,
+		GunID:      input.GunID,
+		UnitID:     input.UnitID,
+		Quantity:   input.Quantity,
+		Note:       strings.TrimSpace(input.Note),
+		ExpiresAt:  time.Now().Add(defaultReservationPeriod),
+		UserID:     app.contextUserID(r),
+	}
+	if input.ExpiresAt != nil {
+		reservation.ExpiresAt = *input.ExpiresAt
+	}
+
+	// A serialized unit is always a single item, so the quantity may be left out.
+	if reservation.UnitID != nil && reservation.Quantity == 0 {
+		reservation.Quantity = 1
+	}
+
+	v := validator.New()
+
+	if models.ValidateReservation(v, reservation); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reservations.Insert(r.Context(), reservation)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrCustomerNotFound):
+			v.AddError("customer_id", "customer not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrRecordNotFound):
+			v.AddError("gun_id", "gun not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrInsufficientStock):
+			v.AddError("quantity", "exceeds the quantity available")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrUnitNotFound):
+			v.AddError("unit_id", "unit not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrUnitUnavailable):
+			v.AddError("unit_id", "unit is not in stock")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrUnitRequired):
+			v.AddError("unit_id", "gun is serialized, hold it by unit_id")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/reservations/%d", reservation.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"reservation": reservation}, headers)
+}
+
+func (app *application) showReservationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	reservation, err := app.models.Reservations.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"reservation": reservation}, nil)
+}
+
+// listReservationsHandler returns a page of reservations, soonest to expire first by default,
+// optionally filtered by status, customer_id and gun_id.
+func (app *application) listReservationsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	reservationFilters := models.ReservationFilters{
+		Status:     app.readStrings(qs, "status", ""),
+		CustomerID: int64(app.readInt(qs, "customer_id", 0, v)),
+		GunID:      int64(app.readInt(qs, "gun_id", 0, v)),
+	}
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "expires_at"),
+		Order:        app.readStrings(qs, "order", "asc"),
+		SortSafelist: []string{"id", "expires_at", "created_at"},
+	}
+
+	models.ValidateReservationFilters(v, reservationFilters)
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reservations, totalRecords, err := app.models.Reservations.GetAll(r.Context(), reservationFilters, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, reservations, totalRecords, filters)
+}
+
+// reservationNotActiveResponse sends the response for changes to a reservation that no longer
+// holds stock.
+func (app *application) reservationNotActiveResponse(w http.ResponseWriter, r *http.Request) {
+	app.failedValidationResponse(w, r, map[string]string{
+		"id": "the reservation has expired, or was already cancelled or sold",
+	})
+}
+
+// extendReservationHandler moves the expiry time of an active reservation.
+func (app *application) extendReservationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateReservationExpiry(v, input.ExpiresAt); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reservation, err := app.models.Reservations.Extend(r.Context(), id, input.ExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrReservationNotActive):
+			app.reservationNotActiveResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"reservation": reservation}, nil)
+}
+
+// cancelReservationHandler releases an active reservation.
+func (app *application) cancelReservationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	reservation, err := app.models.Reservations.Cancel(r.Context(), id, app.contextUserID(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrReservationNotActive):
+			app.reservationNotActiveResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"reservation": reservation}, nil)
+}
+
+// convertReservationHandler sells the stock held by an active reservation to its customer.
+func (app *application) convertReservationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	reservation, sale, err := app.models.Reservations.Convert(r.Context(), id, app.contextUserID(r))
+	if err != nil {
+		var itemErr *models.ItemError
+
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound) && !errors.As(err, &itemErr):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrReservationNotActive):
+			app.reservationNotActiveResponse(w, r)
+		case errors.As(err, &itemErr):
+			app.failedValidationResponse(w, r, map[string]string{
+				"id": fmt.Sprintf("the reservation can't be sold: %v", itemErr.Err),
+			})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/sales/%d", sale.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"reservation": reservation, "sale": sale}, headers)
+}
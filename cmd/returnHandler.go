@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// createReturnHandler takes goods back from a sale line, puts them back in stock and refunds the
+// customer, in cash or store credit. Goods can be exchanged by listing the exchange_items to sell
+// instead, which are paid for with the value of the return first.
+func (app *application) createReturnHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		SaleItemID    int64  `json:"sale_item_id"`
+		CustomerID    *int64 `json:"customer_id"`
+		Quantity      int    `json:"quantity"`
+		Reason        string `json:"reason"`
+		Condition     string `json:"condition"`
+		RefundMethod  string `json:"refund_method"`
+		Note          string `json:"note"`
+		ExchangeItems []struct {
+			GunID    int64  `json:"gun_id"`
+			UnitID   *int64 `json:"unit_id"`
+			Quantity int    `json:"quantity"`
+		} `json:"exchange_items"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ret := &models.Return{
+		SaleItemID:   input.SaleItemID,
+		CustomerID:   input.CustomerID,
+		Quantity:     input.Quantity,
+		Reason:       strings.TrimSpace(input.Reason),
+		Condition:    input.Condition,
+		RefundMethod: input.RefundMethod,
+		Note:         strings.TrimSpace(input.Note),
+		UserID:       app.contextUserID(r),
+	}
+
+	// Most returns are of a single item, so the quantity may be left out.
+	if ret.Quantity == 0 {
+		ret.Quantity = 1
+	}
+
+	for _, item := range input.ExchangeItems {
+		if item.UnitID != nil && item.Quantity == 0 {
+			item.Quantity = 1
+		}
+
+		ret.ExchangeItems = append(ret.ExchangeItems, &models.SaleItem{
+			GunID:    item.GunID,
+			UnitID:   item.UnitID,
+			Quantity: item.Quantity,
+		})
+	}
+
+	v := validator.New()
+
+	if models.ValidateReturn(v, ret); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Returns.Insert(r.Context(), ret, app.returnWindow)
+	if err != nil {
+		var itemErr *models.ItemError
+
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound) && !errors.As(err, &itemErr):
+			v.AddError("sale_item_id", "sale line not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrSaleNotCompleted):
+			v.AddError("sale_item_id", "the sale hasn't been completed, cancel it instead")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrCustomerRequired):
+			v.AddError("customer_id", "must be provided to exchange for serialized guns")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrReturnWindowExpired):
+			v.AddError("sale_item_id", fmt.Sprintf("the sale is older than the %d day return window", int(app.returnWindow.Hours()/24)))
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrReturnCustomerMismatch):
+			v.AddError("customer_id", "the goods were sold to a different customer")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrReturnQuantityExceeded):
+			v.AddError("quantity", "exceeds the quantity sold that hasn't been returned yet")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrStoreCreditNoCustomer):
+			v.AddError("refund_method", "store credit can only be issued to a customer")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrCustomerNotFound):
+			v.AddError("customer_id", "customer not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrUnitUnavailable) && !errors.As(err, &itemErr):
+			v.AddError("sale_item_id", "the unit of this sale line is no longer sold")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrRecordNotFound):
+			v.AddError(fmt.Sprintf("exchange_items[%d].gun_id", itemErr.Index), "gun not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrInsufficientStock):
+			v.AddError(fmt.Sprintf("exchange_items[%d].quantity", itemErr.Index), "exceeds the quantity available")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrUnitNotFound):
+			v.AddError(fmt.Sprintf("exchange_items[%d].unit_id", itemErr.Index), "unit not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrUnitUnavailable):
+			v.AddError(fmt.Sprintf("exchange_items[%d].unit_id", itemErr.Index), "unit is not in stock")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrUnitRequired):
+			v.AddError(fmt.Sprintf("exchange_items[%d].unit_id", itemErr.Index), "gun is serialized, sell it by unit_id")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/returns/%d", ret.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"return": ret}, headers)
+}
+
+func (app *application) showReturnHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	ret, err := app.models.Returns.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"return": ret}, nil)
+}
+
+// listReturnsHandler returns a page of returns, newest first by default, optionally filtered by
+// sale_id and customer_id.
+func (app *application) listReturnsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	returnFilters := models.ReturnFilters{
+		SaleID:     int64(app.readInt(qs, "sale_id", 0, v)),
+		CustomerID: int64(app.readInt(qs, "customer_id", 0, v)),
+	}
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "created_at"),
+		Order:        app.readStrings(qs, "order", "desc"),
+		SortSafelist: []string{"id", "created_at", "value"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	returns, totalRecords, err := app.models.Returns.GetAll(r.Context(), returnFilters, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, returns, totalRecords, filters)
+}
@@ -1,20 +1,133 @@
-package main
-
-import (
-	"net/http"
-
-	"github.com/gorilla/mux"
-)
-
-func (app *application) routes() http.Handler {
-	r := mux.NewRouter().StrictSlash(true)
-	r.NotFoundHandler = http.HandlerFunc(app.notFoundResponse)
-
-	r.HandleFunc("/guns", app.listGuns).Methods("GET")
-	r.HandleFunc("/guns/{id}", app.getGunByID).Methods("GET")
-	r.HandleFunc("/guns", app.createGun).Methods("POST")
-	r.HandleFunc("/guns/{id}", app.updateGun).Methods("PUT")
-	r.HandleFunc("/guns/{id}", app.deleteGun).Methods("DELETE")
-
-	return r
-}
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (app *application) routes() http.Handler {
+	r := mux.NewRouter().StrictSlash(true)
+	r.NotFoundHandler = http.HandlerFunc(app.notFoundResponse)
+
+	// Convert app.methodNotAllowedResponse helper to a http.Handler and set it as the custom
+	// error handler for 405 Method Not Allowed responses
+	r.MethodNotAllowedHandler = http.HandlerFunc(app.methodNotAllowedResponse)
+
+	r.HandleFunc("/guns", app.listGuns).Methods("GET")
+	r.HandleFunc("/guns/search", app.searchGuns).Methods("GET")
+	r.HandleFunc("/guns/export", app.requirePermissions("guns:read", app.exportGunsHandler)).Methods("GET")
+	r.HandleFunc("/guns/import", app.requirePermissions("guns:write", app.importGunsHandler)).Methods("POST")
+	r.HandleFunc("/guns/{id:[0-9]+}", app.getGunByID).Methods("GET")
+	r.HandleFunc("/guns", app.requirePermissions("guns:write", app.createGun)).Methods("POST")
+	r.HandleFunc("/guns/{id:[0-9]+}", app.requirePermissions("guns:write", app.updateGun)).Methods("PUT")
+	r.HandleFunc("/guns/{id:[0-9]+}", app.requirePermissions("guns:write", app.deleteGun)).Methods("DELETE")
+	r.HandleFunc("/guns/trash", app.requirePermissions("guns:admin", app.listTrashedGuns)).Methods("GET")
+	r.HandleFunc("/guns/{id:[0-9]+}/restore", app.requirePermissions("guns:admin", app.restoreGun)).Methods("PUT")
+	r.HandleFunc("/guns/{id:[0-9]+}/purge", app.requirePermissions("guns:purge", app.purgeGun)).Methods("DELETE")
+
+	r.HandleFunc("/guns/{id:[0-9]+}/stock", app.requirePermissions("guns:read", app.showStockHandler)).Methods("GET")
+	r.HandleFunc("/guns/{id:[0-9]+}/stock/movements", app.requirePermissions("guns:read", app.listStockMovementsHandler)).Methods("GET")
+	r.HandleFunc("/guns/{id:[0-9]+}/stock/movements", app.requirePermissions("guns:write", app.createStockMovementHandler)).Methods("POST")
+	r.HandleFunc("/guns/{id:[0-9]+}/stock/reorder", app.requirePermissions("guns:write", app.updateReorderLevelHandler)).Methods("PUT")
+
+	r.HandleFunc("/guns/{id:[0-9]+}/units", app.requirePermissions("guns:read", app.listGunUnitsHandler)).Methods("GET")
+	r.HandleFunc("/guns/{id:[0-9]+}/units", app.requirePermissions("guns:write", app.receiveUnitHandler)).Methods("POST")
+	r.HandleFunc("/units/history", app.requirePermissions("guns:read", app.unitHistoryHandler)).Methods("GET")
+	r.HandleFunc("/units/{id:[0-9]+}", app.requirePermissions("guns:read", app.showUnitHandler)).Methods("GET")
+	r.HandleFunc("/units/{id:[0-9]+}/status", app.requirePermissions("guns:write", app.updateUnitStatusHandler)).Methods("PUT")
+
+	r.HandleFunc("/guns/{id:[0-9]+}/prices", app.listGunPricesHandler).Methods("GET")
+	r.HandleFunc("/guns/{id:[0-9]+}/prices/scheduled", app.requirePermissions("guns:read", app.listScheduledPricesHandler)).Methods("GET")
+	r.HandleFunc("/guns/{id:[0-9]+}/prices/scheduled", app.requirePermissions("guns:write", app.schedulePriceHandler)).Methods("POST")
+	r.HandleFunc("/scheduled-prices/{id:[0-9]+}", app.requirePermissions("guns:write", app.cancelScheduledPriceHandler)).Methods("DELETE")
+
+	r.HandleFunc("/guns/{id:[0-9]+}/images", app.listGunImagesHandler).Methods("GET")
+	r.HandleFunc("/guns/{id:[0-9]+}/images", app.requirePermissions("guns:write", app.uploadGunImageHandler)).Methods("POST")
+	r.HandleFunc("/guns/{id:[0-9]+}/images/order", app.requirePermissions("guns:write", app.reorderGunImagesHandler)).Methods("PUT")
+	r.HandleFunc("/images/{id:[0-9]+}", app.showImageHandler).Methods("GET")
+	r.HandleFunc("/images/{id:[0-9]+}/file", app.serveImageHandler(false)).Methods("GET")
+	r.HandleFunc("/images/{id:[0-9]+}/thumbnail", app.serveImageHandler(true)).Methods("GET")
+	r.HandleFunc("/images/{id:[0-9]+}/primary", app.requirePermissions("guns:write", app.setPrimaryImageHandler)).Methods("PUT")
+	r.HandleFunc("/images/{id:[0-9]+}", app.requirePermissions("guns:write", app.deleteImageHandler)).Methods("DELETE")
+
+	r.HandleFunc("/manufacturers", app.listManufacturersHandler).Methods("GET")
+	r.HandleFunc("/manufacturers", app.requirePermissions("guns:write", app.createManufacturerHandler)).Methods("POST")
+	r.HandleFunc("/manufacturers/{id:[0-9]+}", app.showManufacturerHandler).Methods("GET")
+	r.HandleFunc("/manufacturers/{id:[0-9]+}", app.requirePermissions("guns:write", app.updateManufacturerHandler)).Methods("PUT")
+	r.HandleFunc("/manufacturers/{id:[0-9]+}", app.requirePermissions("guns:write", app.deleteManufacturerHandler)).Methods("DELETE")
+
+	r.HandleFunc("/categories", app.listCategoriesHandler).Methods("GET")
+	r.HandleFunc("/categories", app.requirePermissions("guns:write", app.createCategoryHandler)).Methods("POST")
+	r.HandleFunc("/categories/{id:[0-9]+}", app.showCategoryHandler).Methods("GET")
+	r.HandleFunc("/categories/{id:[0-9]+}", app.requirePermissions("guns:write", app.updateCategoryHandler)).Methods("PUT")
+	r.HandleFunc("/categories/{id:[0-9]+}", app.requirePermissions("guns:write", app.deleteCategoryHandler)).Methods("DELETE")
+
+	r.HandleFunc("/promotions", app.listPromotionsHandler).Methods("GET")
+	r.HandleFunc("/promotions", app.requirePermissions("guns:write", app.createPromotionHandler)).Methods("POST")
+	r.HandleFunc("/promotions/{id:[0-9]+}", app.showPromotionHandler).Methods("GET")
+	r.HandleFunc("/promotions/{id:[0-9]+}", app.requirePermissions("guns:write", app.updatePromotionHandler)).Methods("PUT")
+	r.HandleFunc("/promotions/{id:[0-9]+}", app.requirePermissions("guns:write", app.deletePromotionHandler)).Methods("DELETE")
+	r.HandleFunc("/quote", app.quoteHandler).Methods("POST")
+
+	r.HandleFunc("/sales", app.requirePermissions("sales:read", app.listSalesHandler)).Methods("GET")
+	r.HandleFunc("/sales", app.requirePermissions("sales:write", app.createSaleHandler)).Methods("POST")
+	r.HandleFunc("/sales/{id:[0-9]+}", app.requirePermissions("sales:read", app.showSaleHandler)).Methods("GET")
+	r.HandleFunc("/sales/{id:[0-9]+}/status", app.requirePermissions("sales:write", app.updateSaleStatusHandler)).Methods("PUT")
+
+	r.HandleFunc("/returns", app.requirePermissions("sales:read", app.listReturnsHandler)).Methods("GET")
+	r.HandleFunc("/returns", app.requirePermissions("sales:write", app.createReturnHandler)).Methods("POST")
+	r.HandleFunc("/returns/{id:[0-9]+}", app.requirePermissions("sales:read", app.showReturnHandler)).Methods("GET")
+
+	r.HandleFunc("/reservations", app.requirePermissions("sales:read", app.listReservationsHandler)).Methods("GET")
+	r.HandleFunc("/reservations", app.requirePermissions("sales:write", app.createReservationHandler)).Methods("POST")
+	r.HandleFunc("/reservations/{id:[0-9]+}", app.requirePermissions("sales:read", app.showReservationHandler)).Methods("GET")
+	r.HandleFunc("/reservations/{id:[0-9]+}", app.requirePermissions("sales:write", app.cancelReservationHandler)).Methods("DELETE")
+	r.HandleFunc("/reservations/{id:[0-9]+}/expiry", app.requirePermissions("sales:write", app.extendReservationHandler)).Methods("PUT")
+	r.HandleFunc("/reservations/{id:[0-9]+}/sale", app.requirePermissions("sales:write", app.convertReservationHandler)).Methods("POST")
+
+	r.HandleFunc("/suppliers", app.requirePermissions("purchasing:read", app.listSuppliersHandler)).Methods("GET")
+	r.HandleFunc("/suppliers", app.requirePermissions("purchasing:write", app.createSupplierHandler)).Methods("POST")
+	r.HandleFunc("/suppliers/{id:[0-9]+}", app.requirePermissions("purchasing:read", app.showSupplierHandler)).Methods("GET")
+	r.HandleFunc("/suppliers/{id:[0-9]+}", app.requirePermissions("purchasing:write", app.updateSupplierHandler)).Methods("PUT")
+	r.HandleFunc("/suppliers/{id:[0-9]+}", app.requirePermissions("purchasing:write", app.deleteSupplierHandler)).Methods("DELETE")
+
+	r.HandleFunc("/purchase-orders", app.requirePermissions("purchasing:read", app.listPurchaseOrdersHandler)).Methods("GET")
+	r.HandleFunc("/purchase-orders", app.requirePermissions("purchasing:write", app.createPurchaseOrderHandler)).Methods("POST")
+	r.HandleFunc("/purchase-orders/{id:[0-9]+}", app.requirePermissions("purchasing:read", app.showPurchaseOrderHandler)).Methods("GET")
+	r.HandleFunc("/purchase-orders/{id:[0-9]+}", app.requirePermissions("purchasing:write", app.updatePurchaseOrderHandler)).Methods("PUT")
+	r.HandleFunc("/purchase-orders/{id:[0-9]+}/status", app.requirePermissions("purchasing:write", app.updatePurchaseOrderStatusHandler)).Methods("PUT")
+	r.HandleFunc("/purchase-orders/{id:[0-9]+}/receipts", app.requirePermissions("purchasing:write", app.receivePurchaseOrderHandler)).Methods("POST")
+
+	r.HandleFunc("/reorder-alerts", app.requirePermissions("purchasing:read", app.listReorderAlertsHandler)).Methods("GET")
+	r.HandleFunc("/reorder-alerts/purchase-order", app.requirePermissions("purchasing:read", app.suggestPurchaseOrderHandler)).Methods("GET")
+
+	r.HandleFunc("/reports/revenue", app.requirePermissions("reports:read", app.revenueReportHandler)).Methods("GET")
+	r.HandleFunc("/reports/top-sellers", app.requirePermissions("reports:read", app.topSellersReportHandler)).Methods("GET")
+	r.HandleFunc("/reports/slow-movers", app.requirePermissions("reports:read", app.slowMoversReportHandler)).Methods("GET")
+	r.HandleFunc("/reports/stock-valuation", app.requirePermissions("reports:read", app.stockValuationReportHandler)).Methods("GET")
+
+	// The bound book is append-only: entries are written by receipts, sales, returns and
+	// transfers, and the only way to change one is to append a correction.
+	r.HandleFunc("/bound-book", app.requirePermissions("compliance:read", app.listBoundBookHandler)).Methods("GET")
+	r.HandleFunc("/bound-book/export", app.requirePermissions("compliance:read", app.exportBoundBookHandler)).Methods("GET")
+	r.HandleFunc("/bound-book/{id:[0-9]+}", app.requirePermissions("compliance:read", app.showBoundBookEntryHandler)).Methods("GET")
+	r.HandleFunc("/bound-book/{id:[0-9]+}/corrections", app.requirePermissions("compliance:write", app.correctBoundBookEntryHandler)).Methods("POST")
+
+	// Customer records are visible to every activated user, but their personal details are
+	// only included for users with the customers:read permission.
+	r.HandleFunc("/customers", app.requireActivatedUser(app.listCustomersHandler)).Methods("GET")
+	r.HandleFunc("/customers", app.requirePermissions("customers:write", app.createCustomerHandler)).Methods("POST")
+	r.HandleFunc("/customers/{id:[0-9]+}", app.requireActivatedUser(app.showCustomerHandler)).Methods("GET")
+	r.HandleFunc("/customers/{id:[0-9]+}", app.requirePermissions("customers:write", app.updateCustomerHandler)).Methods("PUT")
+	r.HandleFunc("/customers/{id:[0-9]+}", app.requirePermissions("customers:write", app.deleteCustomerHandler)).Methods("DELETE")
+	r.HandleFunc("/customers/{id:[0-9]+}/sales", app.requirePermissions("sales:read", app.listCustomerSalesHandler)).Methods("GET")
+
+	r.HandleFunc("/users", app.registerUserHandler).Methods("POST")
+	r.HandleFunc("/users/activated", app.activateUserHandler).Methods("PUT")
+	r.HandleFunc("/tokens/authentication", app.createAuthenticationTokenHandler).Methods("POST")
+
+	// Wrap the router with the authenticate middleware, so that every handler can find the
+	// (possibly anonymous) user in the request context.
+	return app.authenticate(r)
+}
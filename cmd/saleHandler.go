@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// createSaleHandler records a sale of one or more guns. Prices are taken from the catalog at the
+// time of the sale, less the promotions running then, and stock is decremented in the same
+// transaction. Sales of serialized units are left pending a background check.
+func (app *application) createSaleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CustomerID *int64 `json:"customer_id"`
+		Items      []struct {
+			GunID    int64  `json:"gun_id"`
+			UnitID   *int64 `json:"unit_id"`
+			Quantity int    `json:"quantity"`
+		} `json:"items"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	sale := &models.Sale{
+		UserID:     &user.ID,
+		CustomerID: input.CustomerID,
+	}
+	for _, item := range input.Items {
+		// A serialized unit is always a single item, so the quantity may be left out.
+		if item.UnitID != nil && item.Quantity == 0 {
+			item.Quantity = 1
+		}
+
+		sale.Items = append(sale.Items, &models.SaleItem{
+			GunID:    item.GunID,
+			UnitID:   item.UnitID,
+			Quantity: item.Quantity,
+		})
+	}
+
+	v := validator.New()
+
+	if models.ValidateSale(v, sale); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Sales.Insert(r.Context(), sale)
+	if err != nil {
+		var itemErr *models.ItemError
+
+		switch {
+		case errors.Is(err, models.ErrCustomerNotFound):
+			v.AddError("customer_id", "customer not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrCustomerRequired):
+			v.AddError("customer_id", "must be provided to sell serialized guns")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrRecordNotFound):
+			v.AddError(fmt.Sprintf("items[%d].gun_id", itemErr.Index), "gun not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrInsufficientStock):
+			v.AddError(fmt.Sprintf("items[%d].quantity", itemErr.Index), "exceeds the quantity available")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrUnitNotFound):
+			v.AddError(fmt.Sprintf("items[%d].unit_id", itemErr.Index), "unit not found")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrUnitUnavailable):
+			v.AddError(fmt.Sprintf("items[%d].unit_id", itemErr.Index), "unit is not in stock")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &itemErr) && errors.Is(err, models.ErrUnitRequired):
+			v.AddError(fmt.Sprintf("items[%d].unit_id", itemErr.Index), "gun is serialized, sell it by unit_id")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/sales/%d", sale.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"sale": sale}, headers)
+}
+
+// showSaleHandler returns a specific sale with its line items.
+func (app *application) showSaleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	sale, err := app.models.Sales.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"sale": sale}, nil)
+}
+
+// listSalesHandler returns a page of sales, newest first by default, optionally filtered by
+// status.
+func (app *application) listSalesHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	status := app.readStrings(qs, "status", "")
+	models.ValidateSaleStatusFilter(v, status)
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 10, v),
+		Sort:         app.readStrings(qs, "sort", "created_at"),
+		Order:        app.readStrings(qs, "order", "desc"),
+		SortSafelist: []string{"id", "created_at", "total"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	sales, totalRecords, err := app.models.Sales.GetAll(r.Context(), status, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, sales, totalRecords, filters)
+}
+
+// updateSaleStatusHandler moves a sale through the compliance workflow: marking it
+// ready_for_pickup, completed when the buyer picks up the guns, or cancelled. Guns can't be
+// released to the buyer before the waiting period has elapsed.
+func (app *application) updateSaleStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateSaleStatus(v, input.Status, input.Note); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	sale, err := app.models.Sales.UpdateStatus(r.Context(), id, input.Status, input.Note, &user.ID, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrWaitingPeriod):
+			v.AddError("status", "the waiting period has not elapsed yet")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrInvalidTransition):
+			v.AddError("status", "the sale can't move to this status from its current status")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"sale": sale}, nil)
+}
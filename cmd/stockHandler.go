@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// createStockMovementHandler records a receipt, sale, adjustment or return against the stock of
+// a gun. The movement is attributed to the authenticated user.
+func (app *application) createStockMovementHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Quantity is the number of items moved. For adjustments it is the signed correction, for
+	// every other kind it must be positive and the direction is implied by the kind.
+	var input struct {
+		Kind     string `json:"kind"`
+		Quantity int    `json:"quantity"`
+		Reason   string `json:"reason"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if input.Kind != models.MovementAdjust {
+		v.Check(input.Quantity > 0, "quantity", "must be greater than zero")
+	}
+
+	user := app.contextGetUser(r)
+
+	movement := &models.StockMovement{
+		GunID:    gunID,
+		Kind:     input.Kind,
+		Quantity: input.Quantity,
+		Reason:   input.Reason,
+		UserID:   &user.ID,
+	}
+
+	if movement.Kind == models.MovementSell {
+		movement.Quantity = -movement.Quantity
+	}
+
+	if models.ValidateStockMovement(v, movement); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Stock.Insert(r.Context(), movement)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrInsufficientStock):
+			v.AddError("quantity", "exceeds the quantity available")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusCreated, envelope{"movement": movement}, nil)
+}
+
+// showStockHandler returns the current stock of a gun.
+func (app *application) showStockHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	level, err := app.models.Stock.GetLevel(r.Context(), gunID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"stock": level}, nil)
+}
+
+// listStockMovementsHandler returns a page of the stock ledger of a gun, newest first by default.
+func (app *application) listStockMovementsHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "id"),
+		Order:        app.readStrings(qs, "order", "desc"),
+		SortSafelist: []string{"id"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Make sure the gun exists, so that an unknown id is a 404 rather than an empty history.
+	_, err = app.models.Stock.GetLevel(r.Context(), gunID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movements, totalRecords, err := app.models.Stock.GetAllForGun(r.Context(), gunID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, movements, totalRecords, filters)
+}
+
+// updateReorderLevelHandler sets the reorder point and quantity of a gun. Sending both as null
+// stops the gun from being reordered.
+func (app *application) updateReorderLevelHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ReorderPoint    *int `json:"reorder_point"`
+		ReorderQuantity *int `json:"reorder_quantity"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if models.ValidateReorderLevel(v, input.ReorderPoint, input.ReorderQuantity); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	level, err := app.models.Stock.SetReorderLevel(r.Context(), gunID, input.ReorderPoint, input.ReorderQuantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"stock": level}, nil)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+func (app *application) createSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name          string `json:"name"`
+		Email         string `json:"email"`
+		Phone         string `json:"phone"`
+		Address       string `json:"address"`
+		LicenceNumber string `json:"licence_number"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	supplier := &models.Supplier{
+		Name:          strings.TrimSpace(input.Name),
+		Email:         strings.TrimSpace(input.Email),
+		Phone:         strings.TrimSpace(input.Phone),
+		Address:       strings.TrimSpace(input.Address),
+		LicenceNumber: strings.TrimSpace(input.LicenceNumber),
+	}
+
+	v := validator.New()
+
+	if models.ValidateSupplier(v, supplier); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Suppliers.Insert(r.Context(), supplier)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateName):
+			v.AddError("name", "a supplier with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/suppliers/%d", supplier.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"supplier": supplier}, headers)
+}
+
+func (app *application) showSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	supplier, err := app.models.Suppliers.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"supplier": supplier}, nil)
+}
+
+func (app *application) listSuppliersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "name"),
+		Order:        app.readStrings(qs, "order", "asc"),
+		SortSafelist: []string{"id", "name", "created_at"},
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suppliers, totalRecords, err := app.models.Suppliers.GetAll(r.Context(), filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, suppliers, totalRecords, filters)
+}
+
+func (app *application) updateSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	supplier, err := app.models.Suppliers.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name          *string `json:"name"`
+		Email         *string `json:"email"`
+		Phone         *string `json:"phone"`
+		Address       *string `json:"address"`
+		LicenceNumber *string `json:"licence_number"`
+		Version       *int    `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Version != nil && *input.Version != supplier.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if input.Name != nil {
+		supplier.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Email != nil {
+		supplier.Email = strings.TrimSpace(*input.Email)
+	}
+	if input.Phone != nil {
+		supplier.Phone = strings.TrimSpace(*input.Phone)
+	}
+	if input.Address != nil {
+		supplier.Address = strings.TrimSpace(*input.Address)
+	}
+	if input.LicenceNumber != nil {
+		supplier.LicenceNumber = strings.TrimSpace(*input.LicenceNumber)
+	}
+
+	v := validator.New()
+
+	if models.ValidateSupplier(v, supplier); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Suppliers.Update(r.Context(), supplier)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, models.ErrDuplicateName):
+			v.AddError("name", "a supplier with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"supplier": supplier}, nil)
+}
+
+func (app *application) deleteSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Suppliers.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrRecordInUse):
+			app.failedValidationResponse(w, r, map[string]string{
+				"id": "supplier still has purchase orders and can't be deleted",
+			})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// receiveUnitHandler records the receipt of a physical firearm of a catalog gun, identified by
+// its manufacturer and serial number, from the transferor named in the request. The unit enters
+// stock straight away.
+func (app *application) receiveUnitHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Manufacturer      string `json:"manufacturer"`
+		SerialNumber      string `json:"serial_number"`
+		TransferorName    string `json:"transferor_name"`
+		TransferorAddress string `json:"transferor_address"`
+		TransferorLicence string `json:"transferor_licence"`
+		Note              string `json:"note"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	unit := &models.GunUnit{
+		GunID:        gunID,
+		Manufacturer: strings.TrimSpace(input.Manufacturer),
+		SerialNumber: models.NormalizeSerial(input.SerialNumber),
+	}
+
+	transferor := &models.BoundBookParty{
+		Name:    strings.TrimSpace(input.TransferorName),
+		Address: strings.TrimSpace(input.TransferorAddress),
+		Licence: strings.TrimSpace(input.TransferorLicence),
+	}
+
+	v := validator.New()
+
+	v.Check(len(input.Note) <= 500, "note", "must not be more than 500 bytes long")
+	models.ValidateBoundBookParty(v, "transferor", transferor)
+
+	if models.ValidateGunUnit(v, unit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Units.Receive(r.Context(), unit, transferor, &user.ID, input.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrDuplicateSerial):
+			v.AddError("serial_number", "a unit with this manufacturer and serial number already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrPartyRequired):
+			v.AddError("transferor", "must be named to receive the unit")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/units/%d", unit.ID))
+
+	app.writeJSON(w, http.StatusCreated, envelope{"unit": unit}, headers)
+}
+
+// listGunUnitsHandler returns a page of the units of a gun, optionally limited to a status.
+func (app *application) listGunUnitsHandler(w http.ResponseWriter, r *http.Request) {
+	gunID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	status := app.readStrings(qs, "status", "")
+
+	filters := models.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "pageSize", 20, v),
+		Sort:         app.readStrings(qs, "sort", "id"),
+		Order:        app.readStrings(qs, "order", "asc"),
+		SortSafelist: []string{"id", "serial_number", "status", "created_at"},
+	}
+
+	if status != "" {
+		v.Check(validator.In(status, models.UnitInStock, models.UnitReserved, models.UnitSold,
+			models.UnitReturned, models.UnitTransferred), "status", "invalid status")
+	}
+
+	if models.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	units, totalRecords, err := app.models.Units.GetAllForGun(r.Context(), gunID, status, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writePaginatedJSON(w, units, totalRecords, filters)
+}
+
+// showUnitHandler returns a specific unit with its full history.
+func (app *application) showUnitHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	unit, err := app.models.Units.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"unit": unit}, nil)
+}
+
+// unitHistoryHandler looks units up by serial number, optionally narrowed down to a
+// manufacturer, and returns each of them with its full history.
+func (app *application) unitHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	serial := app.readStrings(qs, "serial_number", "")
+	manufacturer := strings.TrimSpace(app.readStrings(qs, "manufacturer", ""))
+
+	v.Check(strings.TrimSpace(serial) != "", "serial_number", "must be provided")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	units, err := app.models.Units.GetBySerial(r.Context(), serial, manufacturer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if len(units) == 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"units": units}, nil)
+}
+
+// updateUnitStatusHandler puts a unit back in stock or transfers it. Transfers name the transferee,
+// who the disposition is written to in the bound book.
+func (app *application) updateUnitStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status            string `json:"status"`
+		TransfereeName    string `json:"transferee_name"`
+		TransfereeAddress string `json:"transferee_address"`
+		TransfereeLicence string `json:"transferee_licence"`
+		Note              string `json:"note"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	transferee := &models.BoundBookParty{
+		Name:    strings.TrimSpace(input.TransfereeName),
+		Address: strings.TrimSpace(input.TransfereeAddress),
+		Licence: strings.TrimSpace(input.TransfereeLicence),
+	}
+
+	v := validator.New()
+
+	if models.ValidateUnitStatus(v, input.Status, input.Note, transferee); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	unit, err := app.models.Units.UpdateStatus(r.Context(), id, input.Status, input.Note, transferee, &user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrInvalidTransition):
+			v.AddError("status", "the unit can't move to this status from its current status")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrPartyRequired):
+			v.AddError("transferee", "must be named to transfer the unit")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, models.ErrUnitHeld):
+			v.AddError("status", "the unit is held by an active reservation, cancel or convert the reservation instead")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, envelope{"unit": unit}, nil)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/compliance"
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/notify"
+)
+
+// runEvery starts a background goroutine that calls fn every interval for as long as the
+// application runs. Errors are logged and a panic in fn is recovered and logged too, so that a
+// failing run never takes the server down and the next run still happens.
+func (app *application) runEvery(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			app.runWorker(name, fn)
+		}
+	}()
+}
+
+// runWorker makes a single run of a background worker.
+func (app *application) runWorker(name string, fn func(ctx context.Context) error) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.PrintError(fmt.Errorf("%s", err), map[string]string{"worker": name})
+		}
+	}()
+
+	err := fn(context.Background())
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"worker": name})
+	}
+}
+
+// startWorkers starts the background workers of the application.
+func (app *application) startWorkers() {
+	app.runEvery("scheduled prices", time.Minute, app.applyScheduledPrices)
+	app.runEvery("reservation sweeper", time.Minute, app.expireReservations)
+	app.runEvery("reorder checker", 5*time.Minute, app.checkReorderLevels)
+	app.runEvery("background checks", time.Minute, app.runBackgroundChecks)
+}
+
+// applyScheduledPrices applies the scheduled price changes that have come into effect.
+func (app *application) applyScheduledPrices(ctx context.Context) error {
+	applied, err := app.models.Prices.ApplyDue(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if applied > 0 {
+		app.logger.PrintInfo("applied scheduled price changes", map[string]string{
+			"count": fmt.Sprint(applied),
+		})
+	}
+
+	return nil
+}
+
+// expireReservations releases the reservations that have run past their expiry time.
+func (app *application) expireReservations(ctx context.Context) error {
+	expired, err := app.models.Reservations.ExpireDue(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if expired > 0 {
+		app.logger.PrintInfo("released expired reservations", map[string]string{
+			"count": fmt.Sprint(expired),
+		})
+	}
+
+	return nil
+}
+
+// checkReorderLevels raises reorder alerts for the guns whose available stock has dropped below
+// their reorder point, resolves the alerts of guns that have been restocked, and sends the new
+// alerts through the notifier. Alerts that fail to send are sent again on the next run.
+func (app *application) checkReorderLevels(ctx context.Context) error {
+	raised, resolved, err := app.models.ReorderAlerts.Check(ctx)
+	if err != nil {
+		return err
+	}
+
+	if raised > 0 || resolved > 0 {
+		app.logger.PrintInfo("checked reorder levels", map[string]string{
+			"raised":   fmt.Sprint(raised),
+			"resolved": fmt.Sprint(resolved),
+		})
+	}
+
+	alerts, err := app.models.ReorderAlerts.GetUnnotified(ctx)
+	if err != nil || len(alerts) == 0 {
+		return err
+	}
+
+	err = app.notifier.Notify(ctx, reorderAlertMessage(alerts))
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, len(alerts))
+	for i, alert := range alerts {
+		ids[i] = alert.ID
+	}
+
+	return app.models.ReorderAlerts.MarkNotified(ctx, ids)
+}
+
+// reorderAlertMessage summarises new reorder alerts in a single message.
+func reorderAlertMessage(alerts []*models.ReorderAlert) notify.Message {
+	var body strings.Builder
+
+	body.WriteString("The available stock of these guns has dropped below their reorder point:\n\n")
+
+	guns := make([]string, len(alerts))
+
+	for i, alert := range alerts {
+		guns[i] = fmt.Sprint(alert.GunID)
+
+		fmt.Fprintf(&body, "- %s (gun %d): %d available, reorder point %d, reorder %d\n",
+			alert.GunName, alert.GunID, alert.QuantityAvailable, alert.ReorderPoint, alert.ReorderQuantity)
+	}
+
+	body.WriteString("\nA purchase order can be suggested from the open alerts at GET /reorder-alerts/purchase-order.\n")
+
+	return notify.Message{
+		Subject: fmt.Sprintf("Low stock: %d gun(s) below their reorder point", len(alerts)),
+		Body:    body.String(),
+		Properties: map[string]string{
+			"alerts": fmt.Sprint(len(alerts)),
+			"guns":   strings.Join(guns, ","),
+		},
+	}
+}
+
+// runBackgroundChecks runs the background checks of new sales, asks the provider again about
+// delayed checks, and makes approved sales ready for pickup once their waiting period has
+// elapsed. A failed check is logged and tried again on the next run, without holding up the
+// others. Without a provider there are no checks to run, which was logged at startup, but sales
+// approved before are still released.
+func (app *application) runBackgroundChecks(ctx context.Context) error {
+	if _, unavailable := app.checker.(compliance.Unavailable); !unavailable {
+		sales, err := app.models.Sales.GetAwaitingCheck(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, sale := range sales {
+			err := app.runBackgroundCheck(ctx, sale)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{
+					"worker":  "background checks",
+					"sale_id": fmt.Sprint(sale.ID),
+				})
+			}
+		}
+	}
+
+	released, err := app.models.Sales.ReleaseDue(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if released > 0 {
+		app.logger.PrintInfo("released sales for pickup", map[string]string{
+			"count": fmt.Sprint(released),
+		})
+	}
+
+	return nil
+}
+
+// runBackgroundCheck submits the background check of a sale, or asks about a delayed one, and
+// records the outcome. Checks the provider has forgotten about are submitted again.
+func (app *application) runBackgroundCheck(ctx context.Context, sale *models.Sale) error {
+	var (
+		result *compliance.Result
+		err    error
+	)
+
+	if sale.CheckReference != "" {
+		result, err = app.checker.Status(ctx, sale.CheckReference)
+	}
+
+	if sale.CheckReference == "" || errors.Is(err, compliance.ErrUnknownReference) {
+		var customer *models.Customer
+
+		customer, err = app.models.Customers.Get(ctx, *sale.CustomerID)
+		if err != nil {
+			return err
+		}
+
+		result, err = app.checker.Submit(ctx, compliance.Request{
+			SaleID: sale.ID,
+			Buyer: compliance.Buyer{
+				Name:          customer.Name,
+				DateOfBirth:   customer.DateOfBirth.Time,
+				Address:       customer.Address,
+				LicenceNumber: customer.LicenceNumber,
+			},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	var outcome string
+
+	switch result.Outcome {
+	case compliance.Approved:
+		outcome = models.SaleApproved
+	case compliance.Delayed:
+		outcome = models.SaleDelayed
+	case compliance.Denied:
+		outcome = models.SaleDenied
+	default:
+		return fmt.Errorf("background check %s: unknown outcome %q", result.Reference, result.Outcome)
+	}
+
+	_, err = app.models.Sales.RecordCheck(ctx, sale.ID, outcome, result.Reference, result.Reason, app.waitingPeriod)
+	if errors.Is(err, models.ErrInvalidTransition) {
+		// The sale was cancelled while the check was running.
+		return nil
+	}
+
+	return err
+}
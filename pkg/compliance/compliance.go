@@ -0,0 +1,56 @@
+// Package compliance runs the background checks that firearm sales need before the buyer can
+// pick up the gun. Checks go through the Checker interface, so that the provider can be swapped
+// without touching the sale workflow; Fake is a local provider for development and tests, and
+// Unavailable fails every check when no provider is configured.
+package compliance
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUnknownReference is returned by Checker.Status for a check the provider doesn't know about.
+// The check has to be submitted again.
+var ErrUnknownReference = errors.New("compliance: unknown check reference")
+
+// The outcomes of a background check. A delayed check needs more time, and its status has to be
+// asked for again later; the other outcomes are final.
+const (
+	Approved = "approved"
+	Delayed  = "delayed"
+	Denied   = "denied"
+)
+
+type (
+	// Buyer is the person a background check is run on.
+	Buyer struct {
+		Name          string
+		DateOfBirth   time.Time
+		Address       string
+		LicenceNumber string
+	}
+
+	// Request asks for a background check of the buyer of a sale.
+	Request struct {
+		SaleID int64
+		Buyer  Buyer
+	}
+
+	// Result is the outcome of a background check. Reference identifies the check with the
+	// provider, and Reason explains a delay or denial when the provider gives one.
+	Result struct {
+		Reference string
+		Outcome   string
+		Reason    string
+	}
+)
+
+// Checker is a background check provider.
+type Checker interface {
+	// Submit starts a background check and returns its outcome so far.
+	Submit(ctx context.Context, req Request) (*Result, error)
+
+	// Status returns the current outcome of a check started by Submit.
+	Status(ctx context.Context, reference string) (*Result, error)
+}
@@ -0,0 +1,71 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	tests := []struct {
+		licence string
+		want    string
+	}{
+		{licence: "AB123", want: Approved},
+		{licence: "deny-1", want: Denied},
+		{licence: " DELAY-1", want: Delayed},
+		{licence: "", want: Approved},
+	}
+
+	ctx := context.Background()
+	fake := NewFake(time.Hour)
+
+	for _, tt := range tests {
+		result, err := fake.Submit(ctx, Request{SaleID: 1, Buyer: Buyer{LicenceNumber: tt.licence}})
+		if err != nil {
+			t.Fatalf("Submit(%q) error = %v", tt.licence, err)
+		}
+		if result.Outcome != tt.want {
+			t.Errorf("Submit(%q) = %q, want %q", tt.licence, result.Outcome, tt.want)
+		}
+
+		status, err := fake.Status(ctx, result.Reference)
+		if err != nil {
+			t.Fatalf("Status(%q) error = %v", result.Reference, err)
+		}
+		if status.Outcome != tt.want {
+			t.Errorf("Status of %q = %q, want %q", tt.licence, status.Outcome, tt.want)
+		}
+	}
+
+	if _, err := fake.Status(ctx, "FAKE-9-9"); !errors.Is(err, ErrUnknownReference) {
+		t.Errorf("Status of an unknown check error = %v, want ErrUnknownReference", err)
+	}
+}
+
+func TestFakeApprovesDelayedChecks(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake(0)
+
+	result, err := fake.Submit(ctx, Request{SaleID: 1, Buyer: Buyer{LicenceNumber: "DELAY"}})
+	if err != nil || result.Outcome != Delayed {
+		t.Fatalf("Submit() = %v, %v, want a delayed check", result, err)
+	}
+
+	status, err := fake.Status(ctx, result.Reference)
+	if err != nil || status.Outcome != Approved || status.Reason != "" {
+		t.Errorf("Status() = %v, %v, want an approved check", status, err)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	var checker Checker = Unavailable{}
+
+	if _, err := checker.Submit(context.Background(), Request{SaleID: 1}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Submit() error = %v, want ErrNotConfigured", err)
+	}
+	if _, err := checker.Status(context.Background(), "REF"); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Status() error = %v, want ErrNotConfigured", err)
+	}
+}
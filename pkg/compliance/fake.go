@@ -0,0 +1,78 @@
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake is a Checker that decides checks locally, from the buyer's licence number: licence
+// numbers starting with "DENY" are denied, those starting with "DELAY" are delayed until
+// DelayFor has passed and then approved, and everyone else is approved straight away. Checks are
+// only kept in memory, so they are forgotten when the process restarts.
+type Fake struct {
+	DelayFor time.Duration
+
+	mu     sync.Mutex
+	next   int
+	checks map[string]*fakeCheck
+}
+
+type fakeCheck struct {
+	outcome     string
+	reason      string
+	submittedAt time.Time
+}
+
+// NewFake returns a Fake that delays checks for delayFor.
+func NewFake(delayFor time.Duration) *Fake {
+	return &Fake{
+		DelayFor: delayFor,
+		checks:   make(map[string]*fakeCheck),
+	}
+}
+
+// Submit decides the check from the buyer's licence number.
+func (f *Fake) Submit(ctx context.Context, req Request) (*Result, error) {
+	licence := strings.ToUpper(strings.TrimSpace(req.Buyer.LicenceNumber))
+
+	check := &fakeCheck{outcome: Approved, submittedAt: time.Now()}
+
+	switch {
+	case strings.HasPrefix(licence, "DENY"):
+		check.outcome = Denied
+		check.reason = "buyer is prohibited"
+	case strings.HasPrefix(licence, "DELAY"):
+		check.outcome = Delayed
+		check.reason = "further research required"
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.next++
+	reference := fmt.Sprintf("FAKE-%d-%d", req.SaleID, f.next)
+	f.checks[reference] = check
+
+	return &Result{Reference: reference, Outcome: check.outcome, Reason: check.reason}, nil
+}
+
+// Status approves delayed checks once DelayFor has passed since they were submitted.
+func (f *Fake) Status(ctx context.Context, reference string) (*Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	check, ok := f.checks[reference]
+	if !ok {
+		return nil, ErrUnknownReference
+	}
+
+	if check.outcome == Delayed && time.Since(check.submittedAt) >= f.DelayFor {
+		check.outcome = Approved
+		check.reason = ""
+	}
+
+	return &Result{Reference: reference, Outcome: check.outcome, Reason: check.reason}, nil
+}
@@ -0,0 +1,23 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotConfigured is returned by Unavailable for every check.
+var ErrNotConfigured = errors.New("compliance: no background check provider is configured")
+
+// Unavailable is the Checker used when no provider is configured. It fails every check, so sales
+// that need one stay pending_check instead of being approved without it.
+type Unavailable struct{}
+
+// Submit fails with ErrNotConfigured.
+func (Unavailable) Submit(ctx context.Context, req Request) (*Result, error) {
+	return nil, ErrNotConfigured
+}
+
+// Status fails with ErrNotConfigured.
+func (Unavailable) Status(ctx context.Context, reference string) (*Result, error) {
+	return nil, ErrNotConfigured
+}
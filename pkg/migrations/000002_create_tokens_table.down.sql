@@ -1,2 +1,2 @@
-
+
 DROP TABLE IF EXISTS tokens;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS guns (
+  id bigserial PRIMARY KEY,
+  name text NOT NULL,
+  price numeric(12, 2) NOT NULL,
+  damage integer NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
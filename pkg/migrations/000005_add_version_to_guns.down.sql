@@ -0,0 +1 @@
+ALTER TABLE guns DROP COLUMN IF EXISTS version;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS guns_name_trgm_idx;
+DROP INDEX IF EXISTS guns_search_idx;
+
+ALTER TABLE guns DROP COLUMN IF EXISTS search;
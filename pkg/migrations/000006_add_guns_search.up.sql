@@ -0,0 +1,8 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE guns
+  ADD COLUMN IF NOT EXISTS search tsvector
+  GENERATED ALWAYS AS (to_tsvector('simple', name)) STORED;
+
+CREATE INDEX IF NOT EXISTS guns_search_idx ON guns USING GIN (search);
+CREATE INDEX IF NOT EXISTS guns_name_trgm_idx ON guns USING GIN (name gin_trgm_ops);
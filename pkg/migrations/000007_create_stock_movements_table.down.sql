@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS stock_movements;
+DROP FUNCTION IF EXISTS stock_movements_append_only();
+DROP FUNCTION IF EXISTS stock_movements_apply();
+
+ALTER TABLE guns DROP CONSTRAINT IF EXISTS guns_quantity_on_hand_check;
+ALTER TABLE guns DROP COLUMN IF EXISTS quantity_on_hand;
@@ -0,0 +1,38 @@
+ALTER TABLE guns ADD COLUMN IF NOT EXISTS quantity_on_hand integer NOT NULL DEFAULT 0;
+ALTER TABLE guns ADD CONSTRAINT guns_quantity_on_hand_check CHECK (quantity_on_hand >= 0);
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+  id bigserial PRIMARY KEY,
+  gun_id bigint NOT NULL REFERENCES guns ON DELETE RESTRICT,
+  kind text NOT NULL CHECK (kind IN ('receive', 'sell', 'adjust', 'return')),
+  quantity integer NOT NULL CHECK (quantity <> 0),
+  reason text NOT NULL,
+  user_id bigint REFERENCES users,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS stock_movements_gun_id_idx ON stock_movements (gun_id, id);
+
+-- guns.quantity_on_hand is a running total of the ledger. It is only ever changed by this
+-- trigger, in the same transaction as the movement, so it always equals the sum of the ledger.
+CREATE OR REPLACE FUNCTION stock_movements_apply() RETURNS trigger AS $$
+BEGIN
+  UPDATE guns SET quantity_on_hand = quantity_on_hand + NEW.quantity WHERE id = NEW.gun_id;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_apply
+  AFTER INSERT ON stock_movements
+  FOR EACH ROW EXECUTE FUNCTION stock_movements_apply();
+
+-- The ledger is append-only: mistakes are fixed with a compensating 'adjust' movement.
+CREATE OR REPLACE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_append_only
+  BEFORE UPDATE OR DELETE ON stock_movements
+  FOR EACH ROW EXECUTE FUNCTION stock_movements_append_only();
@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE code IN ('sales:read', 'sales:write');
+
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS sale_id;
+
+DROP TABLE IF EXISTS sale_items;
+DROP TABLE IF EXISTS sales;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS sales (
+  id bigserial PRIMARY KEY,
+  user_id bigint REFERENCES users,
+  total numeric(12, 2) NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS sale_items (
+  id bigserial PRIMARY KEY,
+  sale_id bigint NOT NULL REFERENCES sales,
+  gun_id bigint NOT NULL REFERENCES guns,
+  quantity integer NOT NULL CHECK (quantity > 0),
+  unit_price numeric(12, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sale_items_sale_id_idx ON sale_items (sale_id);
+
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS sale_id bigint REFERENCES sales;
+
+INSERT INTO permissions (code)
+VALUES ('sales:read'),
+       ('sales:write');
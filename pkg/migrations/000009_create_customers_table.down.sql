@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code IN ('customers:read', 'customers:write');
+
+ALTER TABLE sales DROP COLUMN IF EXISTS customer_id;
+
+DROP TABLE IF EXISTS customers;
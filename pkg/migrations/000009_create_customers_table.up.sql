@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS customers (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  name text NOT NULL,
+  email text NOT NULL DEFAULT '',
+  phone text NOT NULL DEFAULT '',
+  address text NOT NULL DEFAULT '',
+  date_of_birth date NOT NULL,
+  licence_number text NOT NULL,
+  version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS customer_id bigint REFERENCES customers;
+
+CREATE INDEX IF NOT EXISTS sales_customer_id_idx ON sales (customer_id);
+
+INSERT INTO permissions (code)
+VALUES ('customers:read'),
+       ('customers:write');
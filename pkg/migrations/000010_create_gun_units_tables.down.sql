@@ -0,0 +1,5 @@
+ALTER TABLE sale_items DROP COLUMN IF EXISTS unit_id;
+
+DROP TABLE IF EXISTS gun_unit_events;
+DROP FUNCTION IF EXISTS gun_unit_events_append_only();
+DROP TABLE IF EXISTS gun_units;
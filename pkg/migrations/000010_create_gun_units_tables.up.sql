@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS gun_units (
+  id bigserial PRIMARY KEY,
+  gun_id bigint NOT NULL REFERENCES guns,
+  manufacturer text NOT NULL,
+  serial_number text NOT NULL,
+  status text NOT NULL DEFAULT 'in_stock'
+    CHECK (status IN ('in_stock', 'reserved', 'sold', 'returned', 'transferred')),
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  version integer NOT NULL DEFAULT 1,
+  CONSTRAINT gun_units_manufacturer_serial_number_key UNIQUE (manufacturer, serial_number)
+);
+
+CREATE INDEX IF NOT EXISTS gun_units_gun_id_idx ON gun_units (gun_id);
+CREATE INDEX IF NOT EXISTS gun_units_serial_number_idx ON gun_units (serial_number);
+
+-- Every status change of a unit is recorded here, from receipt to sale and beyond.
+CREATE TABLE IF NOT EXISTS gun_unit_events (
+  id bigserial PRIMARY KEY,
+  unit_id bigint NOT NULL REFERENCES gun_units,
+  status text NOT NULL,
+  note text NOT NULL DEFAULT '',
+  user_id bigint REFERENCES users,
+  sale_id bigint REFERENCES sales,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS gun_unit_events_unit_id_idx ON gun_unit_events (unit_id, id);
+
+CREATE OR REPLACE FUNCTION gun_unit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'gun_unit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER gun_unit_events_append_only
+  BEFORE UPDATE OR DELETE ON gun_unit_events
+  FOR EACH ROW EXECUTE FUNCTION gun_unit_events_append_only();
+
+ALTER TABLE sale_items ADD COLUMN IF NOT EXISTS unit_id bigint REFERENCES gun_units;
@@ -0,0 +1,5 @@
+ALTER TABLE guns DROP COLUMN IF EXISTS category_id;
+ALTER TABLE guns DROP COLUMN IF EXISTS manufacturer_id;
+
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS manufacturers;
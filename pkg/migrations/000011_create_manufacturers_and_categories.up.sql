@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS manufacturers (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  name text NOT NULL,
+  country text NOT NULL DEFAULT '',
+  version integer NOT NULL DEFAULT 1,
+  CONSTRAINT manufacturers_name_key UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  name text NOT NULL,
+  description text NOT NULL DEFAULT '',
+  version integer NOT NULL DEFAULT 1,
+  CONSTRAINT categories_name_key UNIQUE (name)
+);
+
+ALTER TABLE guns ADD COLUMN IF NOT EXISTS manufacturer_id bigint REFERENCES manufacturers;
+ALTER TABLE guns ADD COLUMN IF NOT EXISTS category_id bigint REFERENCES categories;
+
+CREATE INDEX IF NOT EXISTS guns_manufacturer_id_idx ON guns (manufacturer_id);
+CREATE INDEX IF NOT EXISTS guns_category_id_idx ON guns (category_id);
@@ -0,0 +1,3 @@
+ALTER TABLE guns DROP COLUMN IF EXISTS specs;
+
+ALTER TABLE categories DROP COLUMN IF EXISTS spec_schema;
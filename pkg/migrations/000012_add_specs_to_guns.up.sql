@@ -0,0 +1,3 @@
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS spec_schema jsonb NOT NULL DEFAULT '{}';
+
+ALTER TABLE guns ADD COLUMN IF NOT EXISTS specs jsonb NOT NULL DEFAULT '{}';
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS gun_images;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS gun_images (
+  id bigserial PRIMARY KEY,
+  gun_id bigint NOT NULL REFERENCES guns ON DELETE CASCADE,
+  position integer NOT NULL,
+  is_primary boolean NOT NULL DEFAULT FALSE,
+  content_type text NOT NULL,
+  size bigint NOT NULL,
+  width integer NOT NULL,
+  height integer NOT NULL,
+  storage_key text NOT NULL,
+  thumbnail_key text NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS gun_images_gun_id_idx ON gun_images (gun_id, position);
+
+-- A gun has at most one primary image.
+CREATE UNIQUE INDEX IF NOT EXISTS gun_images_primary_idx ON gun_images (gun_id) WHERE is_primary;
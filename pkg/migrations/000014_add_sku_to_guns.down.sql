@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS guns_lower_name_idx;
+
+ALTER TABLE guns DROP COLUMN IF EXISTS sku;
@@ -0,0 +1,5 @@
+ALTER TABLE guns ADD COLUMN IF NOT EXISTS sku text;
+
+ALTER TABLE guns ADD CONSTRAINT guns_sku_key UNIQUE (sku);
+
+CREATE INDEX IF NOT EXISTS guns_lower_name_idx ON guns (lower(name));
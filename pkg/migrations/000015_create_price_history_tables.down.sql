@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS gun_price_history;
+DROP TABLE IF EXISTS scheduled_prices;
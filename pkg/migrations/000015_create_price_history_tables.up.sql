@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS scheduled_prices (
+  id bigserial PRIMARY KEY,
+  gun_id bigint NOT NULL REFERENCES guns ON DELETE CASCADE,
+  price numeric(12, 2) NOT NULL CHECK (price >= 0),
+  effective_at timestamp(0) with time zone NOT NULL,
+  user_id bigint REFERENCES users,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  applied_at timestamp(0) with time zone,
+  cancelled_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_prices_pending_idx ON scheduled_prices (effective_at)
+  WHERE applied_at IS NULL AND cancelled_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS gun_price_history (
+  id bigserial PRIMARY KEY,
+  gun_id bigint NOT NULL REFERENCES guns ON DELETE CASCADE,
+  old_price numeric(12, 2),
+  new_price numeric(12, 2) NOT NULL,
+  user_id bigint REFERENCES users,
+  scheduled_price_id bigint REFERENCES scheduled_prices,
+  changed_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS gun_price_history_gun_id_idx ON gun_price_history (gun_id, changed_at);
+
+-- Start the history of the existing guns with their current price.
+INSERT INTO gun_price_history (gun_id, old_price, new_price, changed_at)
+SELECT id, NULL, price, created_at FROM guns;
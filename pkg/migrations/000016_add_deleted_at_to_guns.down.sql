@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code IN ('guns:admin', 'guns:purge');
+
+DROP INDEX IF EXISTS guns_deleted_at_idx;
+
+ALTER TABLE guns DROP COLUMN IF EXISTS deleted_at;
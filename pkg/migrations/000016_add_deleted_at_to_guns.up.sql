@@ -0,0 +1,8 @@
+ALTER TABLE guns ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS guns_deleted_at_idx ON guns (deleted_at);
+
+INSERT INTO permissions (code)
+VALUES
+  ('guns:admin'),
+  ('guns:purge');
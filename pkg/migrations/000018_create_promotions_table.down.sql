@@ -0,0 +1,3 @@
+ALTER TABLE sale_items DROP COLUMN IF EXISTS list_price;
+
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS promotions (
+  id bigserial PRIMARY KEY,
+  name text NOT NULL,
+  percent_off integer CHECK (percent_off BETWEEN 1 AND 100),
+  amount_off numeric(12, 2) CHECK (amount_off > 0),
+  gun_id bigint REFERENCES guns ON DELETE CASCADE,
+  category_id bigint REFERENCES categories ON DELETE CASCADE,
+  manufacturer_id bigint REFERENCES manufacturers ON DELETE CASCADE,
+  min_quantity integer NOT NULL DEFAULT 1 CHECK (min_quantity >= 1),
+  stackable boolean NOT NULL DEFAULT false,
+  starts_at timestamp(0) with time zone NOT NULL,
+  ends_at timestamp(0) with time zone NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  version integer NOT NULL DEFAULT 1,
+  CHECK ((percent_off IS NULL) <> (amount_off IS NULL)),
+  CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS promotions_window_idx ON promotions (starts_at, ends_at);
+
+-- Sale lines keep the catalog price next to the price paid after promotions.
+ALTER TABLE sale_items ADD COLUMN IF NOT EXISTS list_price numeric(12, 2);
+
+UPDATE sale_items SET list_price = unit_price WHERE list_price IS NULL;
+
+ALTER TABLE sale_items ALTER COLUMN list_price SET NOT NULL;
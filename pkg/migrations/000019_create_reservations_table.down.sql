@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reservations;
+
+ALTER TABLE guns DROP CONSTRAINT IF EXISTS guns_quantity_available_check;
+ALTER TABLE guns DROP COLUMN IF EXISTS quantity_reserved;
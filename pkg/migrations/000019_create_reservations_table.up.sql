@@ -0,0 +1,31 @@
+-- guns.quantity_reserved is the stock held by active reservations. It can never exceed the
+-- stock on hand, so a sale or adjustment that would eat into held stock fails.
+ALTER TABLE guns ADD COLUMN IF NOT EXISTS quantity_reserved integer NOT NULL DEFAULT 0;
+ALTER TABLE guns ADD CONSTRAINT guns_quantity_reserved_check CHECK (quantity_reserved >= 0);
+ALTER TABLE guns ADD CONSTRAINT guns_quantity_available_check CHECK (quantity_reserved <= quantity_on_hand);
+
+CREATE TABLE IF NOT EXISTS reservations (
+  id bigserial PRIMARY KEY,
+  customer_id bigint NOT NULL REFERENCES customers,
+  gun_id bigint NOT NULL REFERENCES guns,
+  unit_id bigint REFERENCES gun_units,
+  quantity integer NOT NULL CHECK (quantity > 0),
+  status text NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'expired', 'cancelled', 'converted')),
+  note text NOT NULL DEFAULT '',
+  expires_at timestamp(0) with time zone NOT NULL,
+  user_id bigint REFERENCES users,
+  sale_id bigint REFERENCES sales,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS reservations_customer_id_idx ON reservations (customer_id);
+CREATE INDEX IF NOT EXISTS reservations_gun_id_idx ON reservations (gun_id);
+CREATE INDEX IF NOT EXISTS reservations_active_expires_at_idx ON reservations (expires_at)
+  WHERE status = 'active';
+
+-- A unit is held by at most one active reservation.
+CREATE UNIQUE INDEX IF NOT EXISTS reservations_active_unit_id_key ON reservations (unit_id)
+  WHERE status = 'active';
@@ -0,0 +1,11 @@
+DELETE FROM permissions WHERE code IN ('purchasing:read', 'purchasing:write');
+
+ALTER TABLE gun_units DROP COLUMN IF EXISTS unit_cost;
+ALTER TABLE gun_units DROP COLUMN IF EXISTS purchase_order_id;
+
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS unit_cost;
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS purchase_order_id;
+
+DROP TABLE IF EXISTS purchase_order_lines;
+DROP TABLE IF EXISTS purchase_orders;
+DROP TABLE IF EXISTS suppliers;
@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS suppliers (
+  id bigserial PRIMARY KEY,
+  name text NOT NULL,
+  email text NOT NULL DEFAULT '',
+  phone text NOT NULL DEFAULT '',
+  address text NOT NULL DEFAULT '',
+  licence_number text NOT NULL DEFAULT '',
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  version integer NOT NULL DEFAULT 1,
+  CONSTRAINT suppliers_name_key UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+  id bigserial PRIMARY KEY,
+  supplier_id bigint NOT NULL REFERENCES suppliers,
+  status text NOT NULL DEFAULT 'draft'
+    CHECK (status IN ('draft', 'sent', 'partially_received', 'received', 'cancelled')),
+  note text NOT NULL DEFAULT '',
+  user_id bigint REFERENCES users,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  sent_at timestamp(0) with time zone,
+  closed_at timestamp(0) with time zone,
+  version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS purchase_orders_supplier_id_idx ON purchase_orders (supplier_id);
+
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+  id bigserial PRIMARY KEY,
+  purchase_order_id bigint NOT NULL REFERENCES purchase_orders ON DELETE CASCADE,
+  gun_id bigint NOT NULL REFERENCES guns,
+  quantity_ordered integer NOT NULL CHECK (quantity_ordered > 0),
+  quantity_received integer NOT NULL DEFAULT 0,
+  unit_cost numeric(12, 2) NOT NULL CHECK (unit_cost >= 0),
+  CONSTRAINT purchase_order_lines_quantity_received_check
+    CHECK (quantity_received BETWEEN 0 AND quantity_ordered),
+  CONSTRAINT purchase_order_lines_gun_id_key UNIQUE (purchase_order_id, gun_id)
+);
+
+-- Receipts against a purchase order keep the order and the cost price, for margins.
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS purchase_order_id bigint REFERENCES purchase_orders;
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS unit_cost numeric(12, 2);
+
+ALTER TABLE gun_units ADD COLUMN IF NOT EXISTS purchase_order_id bigint REFERENCES purchase_orders;
+ALTER TABLE gun_units ADD COLUMN IF NOT EXISTS unit_cost numeric(12, 2);
+
+INSERT INTO permissions (code)
+VALUES ('purchasing:read'),
+       ('purchasing:write');
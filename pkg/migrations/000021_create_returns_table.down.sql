@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS returns;
+
+ALTER TABLE customers DROP CONSTRAINT IF EXISTS customers_store_credit_check;
+ALTER TABLE customers DROP COLUMN IF EXISTS store_credit;
@@ -0,0 +1,28 @@
+-- The store credit balance owed to a customer, issued by returns.
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS store_credit numeric(12, 2) NOT NULL DEFAULT 0;
+ALTER TABLE customers ADD CONSTRAINT customers_store_credit_check CHECK (store_credit >= 0);
+
+CREATE TABLE IF NOT EXISTS returns (
+  id bigserial PRIMARY KEY,
+  sale_id bigint NOT NULL REFERENCES sales,
+  sale_item_id bigint NOT NULL REFERENCES sale_items,
+  gun_id bigint NOT NULL REFERENCES guns,
+  unit_id bigint REFERENCES gun_units,
+  customer_id bigint REFERENCES customers,
+  quantity integer NOT NULL CHECK (quantity > 0),
+  reason text NOT NULL,
+  condition text NOT NULL CHECK (condition IN ('new', 'used', 'damaged')),
+  refund_method text NOT NULL CHECK (refund_method IN ('refund', 'store_credit')),
+  value numeric(12, 2) NOT NULL CHECK (value >= 0),
+  refund_amount numeric(12, 2) NOT NULL CHECK (refund_amount >= 0),
+  amount_due numeric(12, 2) NOT NULL DEFAULT 0 CHECK (amount_due >= 0),
+  exchange_sale_id bigint REFERENCES sales,
+  stock_movement_id bigint NOT NULL REFERENCES stock_movements,
+  note text NOT NULL DEFAULT '',
+  user_id bigint REFERENCES users,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS returns_sale_item_id_idx ON returns (sale_item_id);
+CREATE INDEX IF NOT EXISTS returns_customer_id_idx ON returns (customer_id);
+CREATE INDEX IF NOT EXISTS returns_created_at_idx ON returns (created_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS reorder_alerts;
+
+ALTER TABLE guns DROP CONSTRAINT IF EXISTS guns_reorder_check;
+ALTER TABLE guns DROP COLUMN IF EXISTS reorder_quantity;
+ALTER TABLE guns DROP COLUMN IF EXISTS reorder_point;
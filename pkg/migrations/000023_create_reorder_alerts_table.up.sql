@@ -0,0 +1,25 @@
+-- A gun with a reorder point raises an alert when its available stock drops below that point,
+-- suggesting that reorder_quantity more are ordered. Guns without one are never reordered.
+ALTER TABLE guns ADD COLUMN IF NOT EXISTS reorder_point integer;
+ALTER TABLE guns ADD COLUMN IF NOT EXISTS reorder_quantity integer;
+ALTER TABLE guns ADD CONSTRAINT guns_reorder_point_check CHECK (reorder_point >= 0);
+ALTER TABLE guns ADD CONSTRAINT guns_reorder_quantity_check CHECK (reorder_quantity > 0);
+ALTER TABLE guns ADD CONSTRAINT guns_reorder_check
+  CHECK ((reorder_point IS NULL) = (reorder_quantity IS NULL));
+
+CREATE TABLE IF NOT EXISTS reorder_alerts (
+  id bigserial PRIMARY KEY,
+  gun_id bigint NOT NULL REFERENCES guns ON DELETE CASCADE,
+  status text NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+  quantity_available integer NOT NULL,
+  reorder_point integer NOT NULL,
+  reorder_quantity integer NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  notified_at timestamp(0) with time zone,
+  resolved_at timestamp(0) with time zone
+);
+
+-- A gun has at most one open alert, however long its stock stays low.
+CREATE UNIQUE INDEX IF NOT EXISTS reorder_alerts_open_gun_id_key ON reorder_alerts (gun_id)
+  WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS reorder_alerts_gun_id_idx ON reorder_alerts (gun_id);
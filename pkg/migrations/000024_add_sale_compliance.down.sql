@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS sale_events;
+DROP FUNCTION IF EXISTS sale_events_append_only();
+
+DROP INDEX IF EXISTS sales_open_status_idx;
+ALTER TABLE sales DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE sales DROP COLUMN IF EXISTS completed_at;
+ALTER TABLE sales DROP COLUMN IF EXISTS pickup_after;
+ALTER TABLE sales DROP COLUMN IF EXISTS check_reference;
+ALTER TABLE sales DROP COLUMN IF EXISTS status;
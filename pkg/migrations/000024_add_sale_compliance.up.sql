@@ -0,0 +1,42 @@
+-- Sales of serialized firearms go through a background check, and possibly a waiting period,
+-- before the buyer can pick them up. Every other sale completes at the register, as do all the
+-- sales recorded before this migration.
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'completed';
+ALTER TABLE sales ADD CONSTRAINT sales_status_check
+  CHECK (status IN ('pending_check', 'approved', 'delayed', 'denied', 'ready_for_pickup',
+    'completed', 'cancelled'));
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS check_reference text NOT NULL DEFAULT '';
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS pickup_after timestamp(0) with time zone;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS completed_at timestamp(0) with time zone;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+
+UPDATE sales SET completed_at = created_at, updated_at = created_at;
+
+CREATE INDEX IF NOT EXISTS sales_open_status_idx ON sales (status)
+  WHERE status IN ('pending_check', 'approved', 'delayed', 'ready_for_pickup');
+
+-- Every status change of a sale is recorded here, from the register to pickup.
+CREATE TABLE IF NOT EXISTS sale_events (
+  id bigserial PRIMARY KEY,
+  sale_id bigint NOT NULL REFERENCES sales,
+  status text NOT NULL,
+  note text NOT NULL DEFAULT '',
+  user_id bigint REFERENCES users,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS sale_events_sale_id_idx ON sale_events (sale_id, id);
+
+INSERT INTO sale_events (sale_id, status, user_id, created_at)
+SELECT id, 'completed', user_id, created_at
+FROM sales;
+
+CREATE OR REPLACE FUNCTION sale_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'sale_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sale_events_append_only
+  BEFORE UPDATE OR DELETE ON sale_events
+  FOR EACH ROW EXECUTE FUNCTION sale_events_append_only();
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code IN ('compliance:read', 'compliance:write');
+
+DROP TABLE IF EXISTS bound_book_entries;
+DROP FUNCTION IF EXISTS bound_book_entries_append_only();
@@ -0,0 +1,100 @@
+-- The acquisition and disposition record of every serialized firearm. Each entry describes the
+-- firearm and the party it came from or went to as they were at the time, so later edits to the
+-- catalog, customers or suppliers don't change the record. Entries are never edited: a mistake
+-- is corrected by a new entry that references the one it corrects.
+CREATE TABLE IF NOT EXISTS bound_book_entries (
+  id bigserial PRIMARY KEY,
+  unit_id bigint NOT NULL REFERENCES gun_units,
+  gun_id bigint NOT NULL REFERENCES guns,
+  kind text NOT NULL CHECK (kind IN ('acquisition', 'disposition')),
+  source text NOT NULL CHECK (source IN ('receipt', 'sale', 'return', 'transfer', 'correction')),
+  manufacturer text NOT NULL,
+  model text NOT NULL,
+  firearm_type text NOT NULL DEFAULT '',
+  serial_number text NOT NULL,
+  party_name text NOT NULL DEFAULT '',
+  party_address text NOT NULL DEFAULT '',
+  party_licence text NOT NULL DEFAULT '',
+  purchase_order_id bigint REFERENCES purchase_orders,
+  sale_id bigint REFERENCES sales,
+  return_id bigint REFERENCES returns,
+  corrects_id bigint REFERENCES bound_book_entries,
+  note text NOT NULL DEFAULT '',
+  user_id bigint REFERENCES users,
+  occurred_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT bound_book_entries_correction_check CHECK ((source = 'correction') = (corrects_id IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS bound_book_entries_unit_id_idx ON bound_book_entries (unit_id, id);
+CREATE INDEX IF NOT EXISTS bound_book_entries_serial_number_idx ON bound_book_entries (serial_number);
+CREATE INDEX IF NOT EXISTS bound_book_entries_occurred_at_idx ON bound_book_entries (occurred_at);
+
+-- An entry is corrected at most once; a correction that turns out to be wrong is corrected in
+-- turn, so the corrections of an entry form a single chain.
+CREATE UNIQUE INDEX IF NOT EXISTS bound_book_entries_corrects_id_key ON bound_book_entries (corrects_id);
+
+-- The record of the units already in the system is rebuilt from their history: receipts and
+-- returns are acquisitions, and completed sales and transfers are dispositions. Who units received
+-- without a purchase order came from, and who transfers went to, was never recorded, so those
+-- parties are left blank to be filled in by corrections.
+INSERT INTO bound_book_entries (unit_id, gun_id, kind, source, manufacturer, model, firearm_type,
+  serial_number, party_name, party_address, party_licence, purchase_order_id, sale_id, return_id,
+  note, user_id, occurred_at)
+SELECT gun_units.id, gun_units.gun_id, history.kind, history.source, gun_units.manufacturer,
+  guns.name, COALESCE(categories.name, ''), gun_units.serial_number, history.party_name,
+  history.party_address, history.party_licence, history.purchase_order_id, history.sale_id,
+  history.return_id, history.note, history.user_id, history.occurred_at
+FROM (
+  SELECT gun_unit_events.id AS event_id, gun_unit_events.unit_id, 'acquisition' AS kind,
+    'receipt' AS source, COALESCE(suppliers.name, '') AS party_name,
+    COALESCE(suppliers.address, '') AS party_address,
+    COALESCE(suppliers.licence_number, '') AS party_licence,
+    gun_units.purchase_order_id, NULL::bigint AS sale_id, NULL::bigint AS return_id,
+    gun_unit_events.note, gun_unit_events.user_id, gun_unit_events.created_at AS occurred_at
+  FROM gun_unit_events
+  INNER JOIN gun_units ON gun_units.id = gun_unit_events.unit_id
+  LEFT JOIN purchase_orders ON purchase_orders.id = gun_units.purchase_order_id
+  LEFT JOIN suppliers ON suppliers.id = purchase_orders.supplier_id
+  WHERE gun_unit_events.id = (SELECT min(earliest.id) FROM gun_unit_events earliest WHERE earliest.unit_id = gun_units.id)
+  UNION ALL
+  SELECT gun_unit_events.id, gun_unit_events.unit_id, 'disposition', 'sale',
+    COALESCE(customers.name, ''), COALESCE(customers.address, ''),
+    COALESCE(customers.licence_number, ''), NULL, sales.id, NULL, gun_unit_events.note,
+    gun_unit_events.user_id, sales.completed_at
+  FROM gun_unit_events
+  INNER JOIN sales ON sales.id = gun_unit_events.sale_id
+  LEFT JOIN customers ON customers.id = sales.customer_id
+  WHERE gun_unit_events.status = 'sold' AND sales.status = 'completed'
+  UNION ALL
+  SELECT gun_unit_events.id, gun_unit_events.unit_id, 'acquisition', 'return',
+    COALESCE(customers.name, ''), COALESCE(customers.address, ''),
+    COALESCE(customers.licence_number, ''), NULL, gun_unit_events.sale_id, returns.id,
+    gun_unit_events.note, gun_unit_events.user_id, gun_unit_events.created_at
+  FROM gun_unit_events
+  LEFT JOIN returns ON returns.unit_id = gun_unit_events.unit_id AND returns.sale_id = gun_unit_events.sale_id
+  LEFT JOIN customers ON customers.id = returns.customer_id
+  WHERE gun_unit_events.status = 'returned'
+  UNION ALL
+  SELECT gun_unit_events.id, gun_unit_events.unit_id, 'disposition', 'transfer', '', '', '',
+    NULL, NULL, NULL, gun_unit_events.note, gun_unit_events.user_id, gun_unit_events.created_at
+  FROM gun_unit_events
+  WHERE gun_unit_events.status = 'transferred'
+) history
+INNER JOIN gun_units ON gun_units.id = history.unit_id
+INNER JOIN guns ON guns.id = gun_units.gun_id
+LEFT JOIN categories ON categories.id = guns.category_id
+ORDER BY history.occurred_at, history.event_id;
+
+CREATE OR REPLACE FUNCTION bound_book_entries_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'bound_book_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bound_book_entries_append_only
+  BEFORE UPDATE OR DELETE ON bound_book_entries
+  FOR EACH ROW EXECUTE FUNCTION bound_book_entries_append_only();
+
+INSERT INTO permissions (code)
+VALUES ('compliance:read'), ('compliance:write');
@@ -0,0 +1,548 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrAlreadyCorrected = errors.New("bound book entry already corrected")
+	ErrPartyRequired    = errors.New("bound book party required")
+)
+
+// The kinds of bound book entry: a firearm coming into the shop's possession, or leaving it.
+const (
+	BoundBookAcquisition = "acquisition"
+	BoundBookDisposition = "disposition"
+)
+
+// The events that write a bound book entry. Receipts and returns are acquisitions, completed
+// sales and transfers are dispositions, and corrections are of the same kind as the entry they
+// correct.
+const (
+	BoundBookReceipt    = "receipt"
+	BoundBookSale       = "sale"
+	BoundBookReturn     = "return"
+	BoundBookTransfer   = "transfer"
+	BoundBookCorrection = "correction"
+)
+
+type (
+	// BoundBookEntry is a line of the acquisition and disposition record of a serialized
+	// firearm. The firearm and the party it came from or went to are copied in when the entry
+	// is written, so the record reads as it did at the time. Entries are never changed: a
+	// correction is a new entry whose CorrectsID is the entry it replaces, and CorrectedByID,
+	// which is only ever read, points from an entry to its correction.
+	BoundBookEntry struct {
+		ID              int64     `json:"id"`
+		UnitID          int64     `json:"unit_id"`
+		GunID           int64     `json:"gun_id"`
+		Kind            string    `json:"kind"`
+		Source          string    `json:"source"`
+		Manufacturer    string    `json:"manufacturer"`
+		Model           string    `json:"model"`
+		FirearmType     string    `json:"firearm_type"`
+		SerialNumber    string    `json:"serial_number"`
+		PartyName       string    `json:"party_name"`
+		PartyAddress    string    `json:"party_address"`
+		PartyLicence    string    `json:"party_licence"`
+		PurchaseOrderID *int64    `json:"purchase_order_id,omitempty"`
+		SaleID          *int64    `json:"sale_id,omitempty"`
+		ReturnID        *int64    `json:"return_id,omitempty"`
+		CorrectsID      *int64    `json:"corrects_id,omitempty"`
+		CorrectedByID   *int64    `json:"corrected_by_id,omitempty"`
+		Note            string    `json:"note"`
+		UserID          *int64    `json:"user_id"`
+		OccurredAt      time.Time `json:"occurred_at"`
+		CreatedAt       time.Time `json:"created_at"`
+	}
+
+	// BoundBookParty is who a firearm was received from or transferred to, when there is no
+	// supplier or customer record to copy it from.
+	BoundBookParty struct {
+		Name    string
+		Address string
+		Licence string
+	}
+
+	// BoundBookFilters narrows down the bound book. Zero values don't filter. From and To are
+	// calendar days in UTC, and both are included.
+	BoundBookFilters struct {
+		UnitID       int64
+		SerialNumber string
+		Kind         string
+		From         Date
+		To           Date
+	}
+
+	// BoundBookModel struct wraps a sql.DB connection pool and allows us to work with the
+	// bound_book_entries table. Entries are written by the receipts, sales, returns and transfers
+	// of units; the only entries written through the model itself are corrections.
+	BoundBookModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+// start returns the first instant of the date range, or nil if it has no start.
+func (f BoundBookFilters) start() *time.Time {
+	if f.From.IsZero() {
+		return nil
+	}
+	return &f.From.Time
+}
+
+// end returns the first instant after the date range, or nil if it has no end.
+func (f BoundBookFilters) end() *time.Time {
+	if f.To.IsZero() {
+		return nil
+	}
+	end := f.To.AddDate(0, 0, 1)
+	return &end
+}
+
+// args returns the filters as the query parameters $1 to $5 of boundBookWhere.
+func (f BoundBookFilters) args() []interface{} {
+	return []interface{}{f.UnitID, NormalizeSerial(f.SerialNumber), f.Kind, f.start(), f.end()}
+}
+
+const boundBookWhere = `
+	WHERE ($1 = 0 OR unit_id = $1)
+	AND ($2 = '' OR serial_number = $2)
+	AND ($3 = '' OR kind = $3)
+	AND ($4::timestamptz IS NULL OR occurred_at >= $4)
+	AND ($5::timestamptz IS NULL OR occurred_at < $5)`
+
+const boundBookColumns = `id, unit_id, gun_id, kind, source, manufacturer, model, firearm_type,
+	serial_number, party_name, party_address, party_licence, purchase_order_id, sale_id, return_id,
+	corrects_id,
+	(SELECT correction.id FROM bound_book_entries correction
+		WHERE correction.corrects_id = bound_book_entries.id) AS corrected_by_id,
+	note, user_id, occurred_at, created_at`
+
+func scanBoundBookEntry(row rowScanner, entry *BoundBookEntry) error {
+	return row.Scan(
+		&entry.ID,
+		&entry.UnitID,
+		&entry.GunID,
+		&entry.Kind,
+		&entry.Source,
+		&entry.Manufacturer,
+		&entry.Model,
+		&entry.FirearmType,
+		&entry.SerialNumber,
+		&entry.PartyName,
+		&entry.PartyAddress,
+		&entry.PartyLicence,
+		&entry.PurchaseOrderID,
+		&entry.SaleID,
+		&entry.ReturnID,
+		&entry.CorrectsID,
+		&entry.CorrectedByID,
+		&entry.Note,
+		&entry.UserID,
+		&entry.OccurredAt,
+		&entry.CreatedAt,
+	)
+}
+
+// The queries that look up the party of an entry: the supplier of a purchase order, or a
+// customer.
+const (
+	supplierPartyQuery = `
+		SELECT suppliers.name, suppliers.address, suppliers.licence_number
+		FROM purchase_orders
+		INNER JOIN suppliers ON suppliers.id = purchase_orders.supplier_id
+		WHERE purchase_orders.id = $1
+		`
+
+	customerPartyQuery = `
+		SELECT name, address, licence_number
+		FROM customers
+		WHERE id = $1
+		`
+)
+
+// complete reports whether the name, address and licence number of the party are all known.
+func (p *BoundBookParty) complete() bool {
+	return p != nil && p.Name != "" && p.Address != "" && p.Licence != ""
+}
+
+// setParty copies the party into the entry.
+func (p *BoundBookParty) setParty(entry *BoundBookEntry) {
+	entry.PartyName = p.Name
+	entry.PartyAddress = p.Address
+	entry.PartyLicence = p.Licence
+}
+
+// setBoundBookParty copies the name, address and licence number returned by one of the party
+// queries for id into the entry.
+func setBoundBookParty(ctx context.Context, q queryer, entry *BoundBookEntry, query string, id int64) error {
+	return q.QueryRowContext(ctx, query, id).Scan(&entry.PartyName, &entry.PartyAddress, &entry.PartyLicence)
+}
+
+// insertBoundBookEntry appends an entry for a unit to the bound book. The manufacturer and
+// serial number are copied from the unit, and the model and type of firearm from its gun and
+// the gun's category.
+func insertBoundBookEntry(ctx context.Context, q queryer, entry *BoundBookEntry) error {
+	query := `
+		INSERT INTO bound_book_entries (unit_id, gun_id, kind, source, manufacturer, model,
+			firearm_type, serial_number, party_name, party_address, party_licence,
+			purchase_order_id, sale_id, return_id, note, user_id)
+		SELECT gun_units.id, gun_units.gun_id, $2, $3, gun_units.manufacturer, guns.name,
+			COALESCE(categories.name, ''), gun_units.serial_number, $4, $5, $6, $7::bigint,
+			$8::bigint, $9::bigint, $10, $11::bigint
+		FROM gun_units
+		INNER JOIN guns ON guns.id = gun_units.gun_id
+		LEFT JOIN categories ON categories.id = guns.category_id
+		WHERE gun_units.id = $1
+		RETURNING id, gun_id, manufacturer, model, firearm_type, serial_number, occurred_at, created_at
+		`
+
+	args := []interface{}{
+		entry.UnitID,
+		entry.Kind,
+		entry.Source,
+		entry.PartyName,
+		entry.PartyAddress,
+		entry.PartyLicence,
+		entry.PurchaseOrderID,
+		entry.SaleID,
+		entry.ReturnID,
+		entry.Note,
+		entry.UserID,
+	}
+
+	return q.QueryRowContext(ctx, query, args...).Scan(
+		&entry.ID,
+		&entry.GunID,
+		&entry.Manufacturer,
+		&entry.Model,
+		&entry.FirearmType,
+		&entry.SerialNumber,
+		&entry.OccurredAt,
+		&entry.CreatedAt,
+	)
+}
+
+// recordSaleDispositions writes a disposition to the buyer for every unit of a sale, once the
+// sale has been completed and the guns have left the shop.
+func recordSaleDispositions(ctx context.Context, tx *sql.Tx, sale *Sale, userID *int64) error {
+	query := `
+		SELECT unit_id
+		FROM sale_items
+		WHERE sale_id = $1 AND unit_id IS NOT NULL
+		ORDER BY id
+		`
+
+	rows, err := tx.QueryContext(ctx, query, sale.ID)
+	if err != nil {
+		return err
+	}
+
+	var unitIDs []int64
+
+	for rows.Next() {
+		var unitID int64
+
+		err := rows.Scan(&unitID)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		unitIDs = append(unitIDs, unitID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, unitID := range unitIDs {
+		entry := &BoundBookEntry{
+			UnitID: unitID,
+			Kind:   BoundBookDisposition,
+			Source: BoundBookSale,
+			SaleID: &sale.ID,
+			Note:   fmt.Sprintf("sale #%d", sale.ID),
+			UserID: userID,
+		}
+
+		if sale.CustomerID != nil {
+			err = setBoundBookParty(ctx, tx, entry, customerPartyQuery, *sale.CustomerID)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = insertBoundBookEntry(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Correct appends a correction of the entry entry.CorrectsID. The kind, unit and references
+// are those of the original entry; the description of the firearm, the party, the date and the
+// note are taken from entry, which is filled in with the rest of the correction. It returns an
+// ErrRecordNotFound error if the original entry doesn't exist, and an ErrAlreadyCorrected error
+// if it has been corrected before, in which case the correction should be made to its latest
+// correction instead.
+func (m BoundBookModel) Correct(ctx context.Context, entry *BoundBookEntry) error {
+	query := `
+		INSERT INTO bound_book_entries (unit_id, gun_id, kind, source, manufacturer, model,
+			firearm_type, serial_number, party_name, party_address, party_licence,
+			purchase_order_id, sale_id, return_id, corrects_id, note, user_id, occurred_at)
+		SELECT unit_id, gun_id, kind, 'correction', $2, $3, $4, $5, $6, $7, $8,
+			purchase_order_id, sale_id, return_id, id, $9, $10::bigint, $11::timestamptz
+		FROM bound_book_entries
+		WHERE id = $1
+		RETURNING id, unit_id, gun_id, kind, source, purchase_order_id, sale_id, return_id, created_at
+		`
+
+	args := []interface{}{
+		*entry.CorrectsID,
+		entry.Manufacturer,
+		entry.Model,
+		entry.FirearmType,
+		entry.SerialNumber,
+		entry.PartyName,
+		entry.PartyAddress,
+		entry.PartyLicence,
+		entry.Note,
+		entry.UserID,
+		entry.OccurredAt,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&entry.ID,
+		&entry.UnitID,
+		&entry.GunID,
+		&entry.Kind,
+		&entry.Source,
+		&entry.PurchaseOrderID,
+		&entry.SaleID,
+		&entry.ReturnID,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case violatesConstraint(err, "bound_book_entries_corrects_id_key"):
+			return ErrAlreadyCorrected
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get retrieves a specific bound book entry.
+func (m BoundBookModel) Get(ctx context.Context, id int64) (*BoundBookEntry, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + boundBookColumns + `
+		FROM bound_book_entries
+		WHERE id = $1
+		`
+
+	var entry BoundBookEntry
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanBoundBookEntry(m.DB.QueryRowContext(ctx, query, id), &entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &entry, nil
+}
+
+// GetAll returns a page of the bound book matching the filters, along with the total number of
+// matching entries.
+func (m BoundBookModel) GetAll(ctx context.Context, bookFilters BoundBookFilters, filters Filters) ([]*BoundBookEntry, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM bound_book_entries
+		%s
+		ORDER BY %s %s, id ASC
+		LIMIT $6 OFFSET $7
+		`, boundBookColumns, boundBookWhere, filters.sortColumn(), filters.sortDirection())
+
+	args := append(bookFilters.args(), filters.limit(), filters.offset())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	entries := []*BoundBookEntry{}
+
+	for rows.Next() {
+		var entry BoundBookEntry
+
+		err := rows.Scan(
+			&totalRecords,
+			&entry.ID,
+			&entry.UnitID,
+			&entry.GunID,
+			&entry.Kind,
+			&entry.Source,
+			&entry.Manufacturer,
+			&entry.Model,
+			&entry.FirearmType,
+			&entry.SerialNumber,
+			&entry.PartyName,
+			&entry.PartyAddress,
+			&entry.PartyLicence,
+			&entry.PurchaseOrderID,
+			&entry.SaleID,
+			&entry.ReturnID,
+			&entry.CorrectsID,
+			&entry.CorrectedByID,
+			&entry.Note,
+			&entry.UserID,
+			&entry.OccurredAt,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, totalRecords, nil
+}
+
+// GetRange returns every entry matching the filters, in the order the events happened, for
+// printing or exporting a date range of the bound book.
+func (m BoundBookModel) GetRange(ctx context.Context, bookFilters BoundBookFilters) ([]*BoundBookEntry, error) {
+	query := `
+		SELECT ` + boundBookColumns + `
+		FROM bound_book_entries
+		` + boundBookWhere + `
+		ORDER BY occurred_at, id
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, bookFilters.args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	entries := []*BoundBookEntry{}
+
+	for rows.Next() {
+		var entry BoundBookEntry
+
+		err := scanBoundBookEntry(rows, &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ValidateBoundBookFilters checks the filters of a bound book listing or export.
+func ValidateBoundBookFilters(v *validator.Validator, f BoundBookFilters) {
+	v.Check(f.UnitID >= 0, "unit_id", "must be a valid unit id")
+
+	if f.Kind != "" {
+		v.Check(validator.In(f.Kind, BoundBookAcquisition, BoundBookDisposition), "kind",
+			"must be acquisition or disposition")
+	}
+
+	if !f.From.IsZero() && !f.To.IsZero() {
+		v.Check(!f.To.Before(f.From.Time), "to", "must not be before from")
+	}
+}
+
+// ValidateBoundBookParty checks a party given by hand, whose fields are read from the request as
+// prefix_name, prefix_address and prefix_licence.
+func ValidateBoundBookParty(v *validator.Validator, prefix string, party *BoundBookParty) {
+	v.Check(party.Name != "", prefix+"_name", "must be provided")
+	v.Check(len(party.Name) <= 200, prefix+"_name", "must not be more than 200 bytes long")
+
+	v.Check(party.Address != "", prefix+"_address", "must be provided")
+	v.Check(len(party.Address) <= 500, prefix+"_address", "must not be more than 500 bytes long")
+
+	v.Check(party.Licence != "", prefix+"_licence", "must be provided")
+	v.Check(len(party.Licence) <= 100, prefix+"_licence", "must not be more than 100 bytes long")
+}
+
+// ValidateBoundBookCorrection checks a correction before it is appended. The note explains
+// what was wrong with the original entry, and is required.
+func ValidateBoundBookCorrection(v *validator.Validator, entry *BoundBookEntry) {
+	v.Check(entry.Manufacturer != "", "manufacturer", "must be provided")
+	v.Check(len(entry.Manufacturer) <= 200, "manufacturer", "must not be more than 200 bytes long")
+
+	v.Check(entry.Model != "", "model", "must be provided")
+	v.Check(len(entry.Model) <= 200, "model", "must not be more than 200 bytes long")
+
+	v.Check(len(entry.FirearmType) <= 100, "firearm_type", "must not be more than 100 bytes long")
+
+	v.Check(entry.SerialNumber != "", "serial_number", "must be provided")
+	v.Check(len(entry.SerialNumber) <= 100, "serial_number", "must not be more than 100 bytes long")
+
+	v.Check(len(entry.PartyName) <= 200, "party_name", "must not be more than 200 bytes long")
+	v.Check(len(entry.PartyAddress) <= 500, "party_address", "must not be more than 500 bytes long")
+	v.Check(len(entry.PartyLicence) <= 100, "party_licence", "must not be more than 100 bytes long")
+
+	v.Check(!entry.OccurredAt.IsZero(), "occurred_at", "must be provided")
+	v.Check(!entry.OccurredAt.After(time.Now()), "occurred_at", "must not be in the future")
+
+	v.Check(entry.Note != "", "note", "must explain the correction")
+	v.Check(len(entry.Note) <= 500, "note", "must not be more than 500 bytes long")
+}
@@ -0,0 +1,275 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+)
+
+type (
+	// Category represents a type of gun, such as pistol, rifle or shotgun. Its spec schema lists
+	// the specs that guns of the category can have, see ValidateSpecs.
+	Category struct {
+		ID          int64      `json:"id"`
+		Name        string     `json:"name"`
+		Description string     `json:"description"`
+		SpecSchema  SpecSchema `json:"spec_schema"`
+		CreatedAt   time.Time  `json:"created_at"`
+		UpdatedAt   time.Time  `json:"updated_at"`
+		Version     int        `json:"version"`
+	}
+
+	// CategoryModel struct wraps a sql.DB connection pool and allows us to work with the
+	// Category struct type and the categories table in our database.
+	CategoryModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+// Insert inserts a new record in the categories table. Category names are unique, so we
+// return an ErrDuplicateName error if the name is already taken.
+func (m CategoryModel) Insert(ctx context.Context, category *Category) error {
+	query := `
+		INSERT INTO categories (name, description, spec_schema)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at, version
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, category.Name, category.Description, category.SpecSchema).Scan(
+		&category.ID,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+		&category.Version,
+	)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "categories_name_key"):
+			return ErrDuplicateName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get retrieves a specific category by id.
+func (m CategoryModel) Get(ctx context.Context, id int64) (*Category, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, name, description, spec_schema, created_at, updated_at, version
+		FROM categories
+		WHERE id = $1
+		`
+
+	var category Category
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&category.ID,
+		&category.Name,
+		&category.Description,
+		&category.SpecSchema,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+		&category.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &category, nil
+}
+
+// GetAll returns a page of categories along with the total number of categories.
+func (m CategoryModel) GetAll(ctx context.Context, filters Filters) ([]*Category, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, name, description, spec_schema, created_at, updated_at, version
+		FROM categories
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2
+		`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	categories := []*Category{}
+
+	for rows.Next() {
+		var category Category
+
+		err := rows.Scan(
+			&totalRecords,
+			&category.ID,
+			&category.Name,
+			&category.Description,
+			&category.SpecSchema,
+			&category.CreatedAt,
+			&category.UpdatedAt,
+			&category.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		categories = append(categories, &category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return categories, totalRecords, nil
+}
+
+// GetSpecKeys returns every spec key that appears in the spec schema of a category, which are the
+// only specs that guns can have.
+func (m CategoryModel) GetSpecKeys(ctx context.Context) (map[string]bool, error) {
+	query := `
+		SELECT DISTINCT jsonb_object_keys(spec_schema)
+		FROM categories
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	keys := make(map[string]bool)
+
+	for rows.Next() {
+		var key string
+
+		err := rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+
+		keys[key] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Update updates a specific category, checking against the version field to prevent lost
+// updates.
+func (m CategoryModel) Update(ctx context.Context, category *Category) error {
+	query := `
+		UPDATE categories
+		SET name = $1, description = $2, spec_schema = $3, updated_at = NOW(), version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version
+		`
+
+	args := []interface{}{category.Name, category.Description, category.SpecSchema, category.ID, category.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&category.UpdatedAt, &category.Version)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "categories_name_key"):
+			return ErrDuplicateName
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a specific category. Categories that still have guns can't be deleted,
+// in which case we return an ErrRecordInUse error.
+func (m CategoryModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM categories
+		WHERE id = $1
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordInUse
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ValidateCategory checks the fields of a category.
+func ValidateCategory(v *validator.Validator, category *Category) {
+	v.Check(category.Name != "", "name", "must be provided")
+	v.Check(len(category.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(category.Description) <= 1000, "description", "must not be more than 1000 bytes long")
+
+	ValidateSpecSchema(v, category.SpecSchema)
+}
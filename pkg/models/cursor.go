@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Cursor is the decoded form of the opaque keyset pagination cursor handed out to clients as
+// next_cursor. It records the sort parameters it was created for, together with the sort key and
+// id of the last row on the page, so that the next page starts right after that row.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Key   string `json:"k"`
+	ID    int64  `json:"i"`
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c Cursor) Encode() string {
+	// Marshalling a struct of strings and integers can't fail, so we can ignore the error.
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// DecodeCursor parses a cursor previously returned by Encode. It returns ErrInvalidCursor if the
+// string isn't a cursor we issued, including when its key isn't a valid value of the column it
+// is sorted by.
+func DecodeCursor(s string) (*Cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.Sort == "" || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	parseKey, ok := keysetKeyParsers[c.Sort]
+	if !ok || parseKey(c.Key) != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
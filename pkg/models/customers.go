@@ -0,0 +1,284 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrCustomerNotFound = errors.New("customer not found")
+)
+
+type (
+	// Customer represents a customer of the shop. Everything apart from the id and name is
+	// personally identifiable information, and must only be shown to users with the
+	// customers:read permission, see Redacted.
+	Customer struct {
+		ID            int64     `json:"id"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Name          string    `json:"name"`
+		Email         string    `json:"email"`
+		Phone         string    `json:"phone"`
+		Address       string    `json:"address"`
+		DateOfBirth   Date      `json:"date_of_birth"`
+		LicenceNumber string    `json:"licence_number"`
+		StoreCredit   Money     `json:"store_credit"`
+		Version       int       `json:"version"`
+	}
+
+	// RedactedCustomer is the view of a customer that is safe to show to any user.
+	RedactedCustomer struct {
+		ID      int64  `json:"id"`
+		Name    string `json:"name"`
+		Version int    `json:"version"`
+	}
+
+	// CustomerModel struct wraps a sql.DB connection pool and allows us to work with the
+	// Customer struct type and the customers table in our database.
+	CustomerModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+// Redacted returns the customer without its personally identifiable fields.
+func (c *Customer) Redacted() *RedactedCustomer {
+	return &RedactedCustomer{
+		ID:      c.ID,
+		Name:    c.Name,
+		Version: c.Version,
+	}
+}
+
+// Insert inserts a new record in the customers table.
+func (m CustomerModel) Insert(ctx context.Context, customer *Customer) error {
+	query := `
+		INSERT INTO customers (name, email, phone, address, date_of_birth, licence_number)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at, store_credit, version
+		`
+
+	args := []interface{}{
+		customer.Name,
+		customer.Email,
+		customer.Phone,
+		customer.Address,
+		customer.DateOfBirth,
+		customer.LicenceNumber,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&customer.ID,
+		&customer.CreatedAt,
+		&customer.UpdatedAt,
+		&customer.StoreCredit,
+		&customer.Version,
+	)
+}
+
+// Get retrieves a specific customer by id.
+func (m CustomerModel) Get(ctx context.Context, id int64) (*Customer, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, updated_at, name, email, phone, address, date_of_birth,
+			licence_number, store_credit, version
+		FROM customers
+		WHERE id = $1
+		`
+
+	var customer Customer
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&customer.ID,
+		&customer.CreatedAt,
+		&customer.UpdatedAt,
+		&customer.Name,
+		&customer.Email,
+		&customer.Phone,
+		&customer.Address,
+		&customer.DateOfBirth,
+		&customer.LicenceNumber,
+		&customer.StoreCredit,
+		&customer.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &customer, nil
+}
+
+// GetAll returns a page of customers whose name contains the provided string (all customers if
+// it is empty), along with the total number of matching customers.
+func (m CustomerModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Customer, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, updated_at, name, email, phone, address,
+			date_of_birth, licence_number, store_credit, version
+		FROM customers
+		WHERE name ILIKE '%%' || $1 || '%%'
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3
+		`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, escapeLike(name), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	customers := []*Customer{}
+
+	for rows.Next() {
+		var customer Customer
+
+		err := rows.Scan(
+			&totalRecords,
+			&customer.ID,
+			&customer.CreatedAt,
+			&customer.UpdatedAt,
+			&customer.Name,
+			&customer.Email,
+			&customer.Phone,
+			&customer.Address,
+			&customer.DateOfBirth,
+			&customer.LicenceNumber,
+			&customer.StoreCredit,
+			&customer.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		customers = append(customers, &customer)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return customers, totalRecords, nil
+}
+
+// Update updates a specific customer, checking against the version field to prevent lost
+// updates. If the record changed since it was read we return an ErrEditConflict error.
+func (m CustomerModel) Update(ctx context.Context, customer *Customer) error {
+	query := `
+		UPDATE customers
+		SET name = $1, email = $2, phone = $3, address = $4, date_of_birth = $5,
+			licence_number = $6, updated_at = NOW(), version = version + 1
+		WHERE id = $7 AND version = $8
+		RETURNING updated_at, version
+		`
+
+	args := []interface{}{
+		customer.Name,
+		customer.Email,
+		customer.Phone,
+		customer.Address,
+		customer.DateOfBirth,
+		customer.LicenceNumber,
+		customer.ID,
+		customer.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&customer.UpdatedAt, &customer.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a specific customer. Customers with sales can't be deleted, in which case we
+// return an ErrRecordInUse error.
+func (m CustomerModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM customers
+		WHERE id = $1
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordInUse
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ValidateCustomer checks the fields of a customer.
+func ValidateCustomer(v *validator.Validator, customer *Customer) {
+	v.Check(customer.Name != "", "name", "must be provided")
+	v.Check(len(customer.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	if customer.Email != "" {
+		ValidateEmail(v, customer.Email)
+	}
+	v.Check(len(customer.Phone) <= 50, "phone", "must not be more than 50 bytes long")
+	v.Check(len(customer.Address) <= 1000, "address", "must not be more than 1000 bytes long")
+
+	v.Check(!customer.DateOfBirth.IsZero(), "date_of_birth", "must be provided")
+	v.Check(customer.DateOfBirth.Before(time.Now()), "date_of_birth", "must be in the past")
+	v.Check(customer.DateOfBirth.Year() >= 1900, "date_of_birth", "must be after 1900")
+
+	v.Check(customer.LicenceNumber != "", "licence_number", "must be provided")
+	v.Check(len(customer.LicenceNumber) <= 100, "licence_number", "must not be more than 100 bytes long")
+}
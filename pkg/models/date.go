@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// dateLayout is the layout used for calendar dates in JSON, e.g. "1990-05-17".
+const dateLayout = "2006-01-02"
+
+var ErrInvalidDateFormat = errors.New("invalid date format, expected YYYY-MM-DD")
+
+// Date is a calendar date without a time of day, such as a date of birth. It is encoded in JSON
+// as a "YYYY-MM-DD" string and stored in date columns.
+type Date struct {
+	time.Time
+}
+
+// ParseDate parses a "YYYY-MM-DD" string.
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, ErrInvalidDateFormat
+	}
+
+	return Date{t}, nil
+}
+
+// String returns the date in "YYYY-MM-DD" form.
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+// MarshalJSON encodes the date as a "YYYY-MM-DD" string.
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON decodes a "YYYY-MM-DD" string.
+func (d *Date) UnmarshalJSON(js []byte) error {
+	s, err := strconv.Unquote(string(js))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	*d, err = ParseDate(s)
+	return err
+}
+
+// Scan implements the sql.Scanner interface for date columns.
+func (d *Date) Scan(src interface{}) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into Date", src)
+	}
+
+	d.Time = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return nil
+}
+
+// Value implements the driver.Valuer interface for date columns.
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// Filters holds the pagination and sorting parameters used by the list queries. The
+// SortSafelist field holds the sort values that are allowed for a specific query, and it is the
+// only thing standing between a client supplied sort value and the ORDER BY clause.
+type Filters struct {
+	Page         int
+	PageSize     int
+	Sort         string
+	Order        string
+	SortSafelist []string
+}
+
+// sortColumn checks that the client-provided Sort field matches one of the entries in the
+// safelist and if it does, returns it. We panic if there is no match, since that can only happen
+// when a handler forgot to validate the sort value first.
+func (f Filters) sortColumn() string {
+	for _, safeValue := range f.SortSafelist {
+		if f.Sort == safeValue {
+			return f.Sort
+		}
+	}
+
+	panic("unsafe sort parameter: " + f.Sort)
+}
+
+// sortDirection returns the sort direction ("ASC" or "DESC") for the Order field.
+func (f Filters) sortDirection() string {
+	if strings.ToLower(f.Order) == "desc" {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// limit returns the LIMIT value for the current page size.
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+// offset returns the OFFSET value for the current page.
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// ValidateFilters checks the pagination and sorting parameters for sensible values.
+func ValidateFilters(v *validator.Validator, f Filters) {
+	v.Check(f.Page > 0, "page", "must be greater than zero")
+	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(f.PageSize > 0, "pageSize", "must be greater than zero")
+	v.Check(f.PageSize <= 100, "pageSize", "must be a maximum of 100")
+
+	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+	v.Check(validator.In(strings.ToLower(f.Order), "asc", "desc"), "order", "must be asc or desc")
+}
@@ -1,22 +1,740 @@
-package models
-
-import (
-	"database/sql"
-	"log"
-	"time"
-)
-
-type Gun struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	Damage    int       `json:"damage"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
-
-type GunModel struct {
-	DB       *sql.DB
-	InfoLog  *log.Logger
-	ErrorLog *log.Logger
-}
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrDuplicateSKU = errors.New("duplicate sku")
+)
+
+type Gun struct {
+	ID        int64     `json:"id"`
+	SKU       *string   `json:"sku"`
+	Name      string    `json:"name"`
+	Price     Money     `json:"price"`
+	Damage    int       `json:"damage"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+
+	// ManufacturerID and CategoryID reference the catalog taxonomy. Manufacturer and Category
+	// hold the names of the referenced records and are only ever read.
+	ManufacturerID *int64 `json:"manufacturer_id"`
+	Manufacturer   string `json:"manufacturer,omitempty"`
+	CategoryID     *int64 `json:"category_id"`
+	Category       string `json:"category,omitempty"`
+
+	// Specs are checked against the spec schema of the gun's category, see ValidateSpecs.
+	Specs Specs `json:"specs"`
+
+	// QuantityOnHand is maintained by the stock_movements ledger, see StockModel. The part of it
+	// held by reservations is QuantityReserved, and the rest is QuantityAvailable.
+	QuantityOnHand    int `json:"quantity_on_hand"`
+	QuantityReserved  int `json:"quantity_reserved"`
+	QuantityAvailable int `json:"quantity_available"`
+
+	// CurrentPrice is the price after the promotions running now, next to the list price in
+	// Price. It is only filled in for catalog reads, see CurrentPrice.
+	CurrentPrice *Money `json:"current_price,omitempty"`
+
+	// DeletedAt is set when the gun is in the trash, see Delete.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// gunColumns lists the guns columns in the order that scanGun expects them. They have to be
+// selected from gunTables, which joins in the taxonomy names.
+const gunColumns = `guns.id, guns.sku, guns.name, guns.price, guns.damage, guns.created_at, guns.updated_at,
+	guns.version, guns.manufacturer_id, COALESCE(manufacturers.name, ''), guns.category_id,
+	COALESCE(categories.name, ''), guns.specs, guns.quantity_on_hand, guns.quantity_reserved,
+	guns.quantity_on_hand - guns.quantity_reserved, guns.deleted_at`
+
+const gunTables = `guns
+	LEFT JOIN manufacturers ON manufacturers.id = guns.manufacturer_id
+	LEFT JOIN categories ON categories.id = guns.category_id`
+
+// gunSortColumns maps the sort values accepted by GetAll to the columns they sort by.
+var gunSortColumns = map[string]string{
+	"id":           "guns.id",
+	"name":         "guns.name",
+	"price":        "guns.price",
+	"damage":       "guns.damage",
+	"manufacturer": "manufacturers.name",
+	"category":     "categories.name",
+	"deleted_at":   "guns.deleted_at",
+}
+
+// GunKeysetSorts are the sort values supported by GetAllAfter.
+var GunKeysetSorts = []string{"id", "name", "price", "damage"}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanGun scans a row selected with gunColumns into the gun.
+func scanGun(row rowScanner, gun *Gun) error {
+	return row.Scan(
+		&gun.ID,
+		&gun.SKU,
+		&gun.Name,
+		&gun.Price,
+		&gun.Damage,
+		&gun.CreatedAt,
+		&gun.UpdatedAt,
+		&gun.Version,
+		&gun.ManufacturerID,
+		&gun.Manufacturer,
+		&gun.CategoryID,
+		&gun.Category,
+		&gun.Specs,
+		&gun.QuantityOnHand,
+		&gun.QuantityReserved,
+		&gun.QuantityAvailable,
+		&gun.DeletedAt,
+	)
+}
+
+// GunFilters holds the catalog specific filtering parameters for GunModel.GetAll. Nil range
+// bounds and an empty name mean that the corresponding filter is not applied. Name matches
+// anywhere in the gun's name, with the LIKE wildcards in it taken literally.
+type GunFilters struct {
+	Name      string
+	MinPrice  *Money
+	MaxPrice  *Money
+	MinDamage *int
+	MaxDamage *int
+
+	ManufacturerID *int64
+	CategoryID     *int64
+
+	// Specs filters on the values of the gun specs, all of which have to match.
+	Specs []SpecFilter
+
+	// Deleted selects the guns in the trash instead of the live catalog.
+	Deleted bool
+}
+
+// where builds the WHERE clause for the filters, appending the placeholder values to args.
+func (f GunFilters) where(args *[]interface{}) string {
+	conditions := []string{"guns.deleted_at IS NULL"}
+	if f.Deleted {
+		conditions[0] = "guns.deleted_at IS NOT NULL"
+	}
+
+	add := func(condition string, value interface{}) {
+		*args = append(*args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(*args)))
+	}
+
+	if f.Name != "" {
+		add("guns.name ILIKE '%%' || $%d || '%%'", escapeLike(f.Name))
+	}
+	if f.MinPrice != nil {
+		add("guns.price >= $%d", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		add("guns.price <= $%d", *f.MaxPrice)
+	}
+	if f.MinDamage != nil {
+		add("guns.damage >= $%d", *f.MinDamage)
+	}
+	if f.MaxDamage != nil {
+		add("guns.damage <= $%d", *f.MaxDamage)
+	}
+	if f.ManufacturerID != nil {
+		add("guns.manufacturer_id = $%d", *f.ManufacturerID)
+	}
+	if f.CategoryID != nil {
+		add("guns.category_id = $%d", *f.CategoryID)
+	}
+	for _, spec := range f.Specs {
+		*args = append(*args, spec.Key, spec.Value)
+		conditions = append(conditions, spec.condition(len(*args)-1, len(*args)))
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// GunModel struct wraps a sql.DB connection pool and allows us to work with the Gun struct type
+// and the guns table in our database.
+type GunModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// Insert inserts a new record in the guns table. The id, created_at and updated_at fields are
+// generated by the database, so we read them back into the Gun struct with the RETURNING clause,
+// together with the names of the referenced manufacturer and category. The initial price is
+// recorded in the price history as set by the user with userID.
+func (m GunModel) Insert(ctx context.Context, gun *Gun, userID *int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return insertGun(ctx, m.DB, gun, userID)
+}
+
+// insertGun inserts the gun using q, so that it can also be part of a larger transaction.
+func insertGun(ctx context.Context, q queryer, gun *Gun, userID *int64) error {
+	query := `
+		WITH inserted AS (
+			INSERT INTO guns (sku, name, price, damage, manufacturer_id, category_id, specs)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, created_at, updated_at, version, price, manufacturer_id, category_id
+		), history AS (
+			INSERT INTO gun_price_history (gun_id, old_price, new_price, user_id)
+			SELECT id, NULL, price, $8
+			FROM inserted
+		)
+		SELECT id, created_at, updated_at, version, ` + taxonomyNamesOf("inserted") + `
+		FROM inserted
+		`
+
+	args := []interface{}{
+		gun.SKU,
+		gun.Name,
+		gun.Price,
+		gun.Damage,
+		gun.ManufacturerID,
+		gun.CategoryID,
+		gun.Specs,
+		userID,
+	}
+
+	err := q.QueryRowContext(ctx, query, args...).Scan(
+		&gun.ID,
+		&gun.CreatedAt,
+		&gun.UpdatedAt,
+		&gun.Version,
+		&gun.Manufacturer,
+		&gun.Category,
+	)
+	if err != nil {
+		return gunWriteError(err)
+	}
+
+	return nil
+}
+
+// taxonomyNamesOf selects the names of the manufacturer and category referenced by the
+// manufacturer_id and category_id columns of the named row source.
+func taxonomyNamesOf(source string) string {
+	return fmt.Sprintf(`
+		COALESCE((SELECT name FROM manufacturers WHERE id = %[1]s.manufacturer_id), ''),
+		COALESCE((SELECT name FROM categories WHERE id = %[1]s.category_id), '')`, source)
+}
+
+// gunWriteError translates a foreign key violation on the manufacturer or category of a gun
+// into an ErrManufacturerNotFound or ErrCategoryNotFound error, and a duplicate SKU into an
+// ErrDuplicateSKU error.
+func gunWriteError(err error) error {
+	switch {
+	case violatesConstraint(err, "guns_sku_key"):
+		return ErrDuplicateSKU
+	case violatesConstraint(err, "guns_manufacturer_id_fkey"):
+		return ErrManufacturerNotFound
+	case violatesConstraint(err, "guns_category_id_fkey"):
+		return ErrCategoryNotFound
+	default:
+		return err
+	}
+}
+
+// Get retrieves a specific gun by its id. If no matching record is found we return an
+// ErrRecordNotFound error.
+func (m GunModel) Get(ctx context.Context, id int64) (*Gun, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + gunColumns + `
+		FROM ` + gunTables + `
+		WHERE guns.id = $1 AND guns.deleted_at IS NULL
+		`
+
+	var gun Gun
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanGun(m.DB.QueryRowContext(ctx, query, id), &gun)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &gun, nil
+}
+
+// GetAll returns a page of guns matching the provided filters, sorted according to the
+// pagination filters, along with the total number of matching records.
+func (m GunModel) GetAll(ctx context.Context, gunFilters GunFilters, filters Filters) ([]*Gun, int, error) {
+	var args []interface{}
+	where := gunFilters.where(&args)
+
+	// The sort column and direction can't be passed as placeholder parameters, so we interpolate
+	// them into the query. This is safe because sortColumn() only ever returns safelisted values.
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		%s
+		ORDER BY %s %s, guns.id ASC
+		LIMIT $%d OFFSET $%d
+		`, gunColumns, gunTables, where, gunSortColumns[filters.sortColumn()], filters.sortDirection(),
+		len(args)+1, len(args)+2)
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, append(args, filters.limit(), filters.offset())...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	guns := []*Gun{}
+
+	for rows.Next() {
+		var gun Gun
+
+		err := scanGun(rows, &gun)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		guns = append(guns, &gun)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Count the records with the same WHERE clause, so that the totals describe the filtered
+	// result set rather than the whole table.
+	var totalRecords int
+
+	err = m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM guns `+where, args...).Scan(&totalRecords)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return guns, totalRecords, nil
+}
+
+// Export calls fn for every gun matching the filters, in the sort order of filters, reading the
+// rows from the database as fn consumes them rather than loading them all into memory. The page
+// and page size of filters are ignored. Exports of the whole catalog can take a while, so unlike
+// the other methods there is no timeout: it runs until it is done or ctx is cancelled, which
+// for a request happens when the client goes away.
+func (m GunModel) Export(ctx context.Context, gunFilters GunFilters, filters Filters, fn func(*Gun) error) error {
+	var args []interface{}
+	where := gunFilters.where(&args)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		%s
+		ORDER BY %s %s, guns.id ASC
+		`, gunColumns, gunTables, where, gunSortColumns[filters.sortColumn()], filters.sortDirection())
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	for rows.Next() {
+		var gun Gun
+
+		err := scanGun(rows, &gun)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&gun)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// keysetCasts maps the sort columns supported by keyset pagination to the SQL type that the
+// cursor key is cast to before it is compared.
+var keysetCasts = map[string]string{
+	"id":     "bigint",
+	"name":   "text",
+	"price":  "numeric",
+	"damage": "integer",
+}
+
+// keysetKeyParsers check that the key of a cursor is a valid value of its sort column, so that a
+// tampered cursor is rejected before its key is cast in the query.
+var keysetKeyParsers = map[string]func(string) error{
+	"id": func(key string) error {
+		_, err := strconv.ParseInt(key, 10, 64)
+		return err
+	},
+	"name": func(string) error {
+		return nil
+	},
+	"price": func(key string) error {
+		_, err := ParseMoney(key)
+		return err
+	},
+	"damage": func(key string) error {
+		_, err := strconv.ParseInt(key, 10, 32)
+		return err
+	},
+}
+
+// GetAllAfter returns up to filters.PageSize guns matching the provided filters that come after
+// the cursor in the sort order, along with the cursor for the next page. A nil cursor starts
+// from the beginning, and a nil next cursor means that there are no more rows.
+//
+// Unlike GetAll, the rows are located with a (sort key, id) comparison instead of an OFFSET, so
+// the cost doesn't grow with the depth of the page, and rows inserted while a client is paging
+// through don't cause other rows to be skipped or repeated.
+func (m GunModel) GetAllAfter(ctx context.Context, gunFilters GunFilters, filters Filters, after *Cursor) ([]*Gun, *Cursor, error) {
+	column := filters.sortColumn()
+	direction := filters.sortDirection()
+
+	cast, ok := keysetCasts[column]
+	if !ok {
+		panic("unsupported keyset sort parameter: " + column)
+	}
+
+	var args []interface{}
+	where := gunFilters.where(&args)
+
+	if after != nil {
+		comparison := ">"
+		if direction == "DESC" {
+			comparison = "<"
+		}
+
+		var condition string
+		if column == "id" {
+			args = append(args, after.ID)
+			condition = fmt.Sprintf("guns.id %s $%d", comparison, len(args))
+		} else {
+			args = append(args, after.Key, after.ID)
+			condition = fmt.Sprintf("(guns.%s, guns.id) %s ($%d::%s, $%d)",
+				column, comparison, len(args)-1, cast, len(args))
+		}
+
+		where += " AND " + condition
+	}
+
+	// Fetch one row more than the page size, so that we know whether there is a next page
+	// without running a separate COUNT query.
+	args = append(args, filters.limit()+1)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		%s
+		ORDER BY guns.%s %s, guns.id %s
+		LIMIT $%d
+		`, gunColumns, gunTables, where, column, direction, direction, len(args))
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	guns := []*Gun{}
+
+	for rows.Next() {
+		var gun Gun
+
+		err := scanGun(rows, &gun)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		guns = append(guns, &gun)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(guns) <= filters.limit() {
+		return guns, nil, nil
+	}
+
+	guns = guns[:filters.limit()]
+	last := guns[len(guns)-1]
+
+	next := &Cursor{
+		Sort:  filters.Sort,
+		Order: strings.ToLower(filters.Order),
+		Key:   gunSortKey(last, column),
+		ID:    last.ID,
+	}
+
+	return guns, next, nil
+}
+
+// gunSortKey returns the value of the gun's sort column in the text form stored in a Cursor.
+func gunSortKey(gun *Gun, column string) string {
+	switch column {
+	case "name":
+		return gun.Name
+	case "price":
+		return gun.Price.String()
+	case "damage":
+		return strconv.Itoa(gun.Damage)
+	default:
+		return strconv.FormatInt(gun.ID, 10)
+	}
+}
+
+// Update updates the details for a specific gun in the guns table. Note, we check against the
+// version field to help prevent any race conditions during the request cycle, so if the gun was
+// changed (or deleted) since it was read we return an ErrEditConflict error.
+func (m GunModel) Update(ctx context.Context, gun *Gun, userID *int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return updateGun(ctx, m.DB, gun, userID)
+}
+
+// updateGun updates the gun using q, so that it can also be part of a larger transaction. If
+// the price changed, the old and new price are recorded in the price history in the same
+// statement, as changed by the user with userID.
+func updateGun(ctx context.Context, q queryer, gun *Gun, userID *int64) error {
+	query := `
+		WITH previous AS (
+			SELECT price FROM guns WHERE id = $8
+		), updated AS (
+			UPDATE guns
+			SET sku = $1, name = $2, price = $3, damage = $4, manufacturer_id = $5,
+				category_id = $6, specs = $7, updated_at = NOW(), version = version + 1
+			WHERE id = $8 AND version = $9 AND deleted_at IS NULL
+			RETURNING id, updated_at, version, price, manufacturer_id, category_id
+		), history AS (
+			INSERT INTO gun_price_history (gun_id, old_price, new_price, user_id)
+			SELECT updated.id, previous.price, updated.price, $10
+			FROM previous, updated
+			WHERE previous.price <> updated.price
+		)
+		SELECT updated_at, version, ` + taxonomyNamesOf("updated") + `
+		FROM updated
+		`
+
+	args := []interface{}{
+		gun.SKU,
+		gun.Name,
+		gun.Price,
+		gun.Damage,
+		gun.ManufacturerID,
+		gun.CategoryID,
+		gun.Specs,
+		gun.ID,
+		gun.Version,
+		userID,
+	}
+
+	err := q.QueryRowContext(ctx, query, args...).Scan(
+		&gun.UpdatedAt,
+		&gun.Version,
+		&gun.Manufacturer,
+		&gun.Category,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return gunWriteError(err)
+		}
+	}
+
+	return nil
+}
+
+// Delete moves a specific gun to the trash by setting its deleted_at time. Guns in the trash
+// are hidden from every catalog read, but keep their stock, sales and unit history. If there is
+// no such gun outside the trash we return an ErrRecordNotFound error.
+func (m GunModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE guns
+		SET deleted_at = NOW(), updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Restore takes a specific gun out of the trash and returns it. If there is no such gun in the
+// trash we return an ErrRecordNotFound error.
+func (m GunModel) Restore(ctx context.Context, id int64) (*Gun, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE guns
+		SET deleted_at = NULL, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return m.Get(ctx, id)
+}
+
+// Purge permanently removes a specific gun from the trash. Only guns in the trash can be
+// purged, otherwise we return an ErrRecordNotFound error. Guns with stock movements, sales or
+// serialized units can never be purged, since that would destroy their compliance history, in
+// which case we return an ErrRecordInUse error.
+func (m GunModel) Purge(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM guns
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordInUse
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ValidateGun checks the client supplied fields of a gun.
+func ValidateGun(v *validator.Validator, gun *Gun) {
+	if gun.SKU != nil {
+		v.Check(*gun.SKU != "", "sku", "must not be empty")
+		v.Check(len(*gun.SKU) <= 100, "sku", "must not be more than 100 bytes long")
+	}
+
+	v.Check(gun.Name != "", "name", "must be provided")
+	v.Check(len(gun.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	v.Check(!gun.Price.IsNegative(), "price", "must not be negative")
+	v.Check(gun.Price.InCurrency(DefaultCurrency), "price", "must be in "+DefaultCurrency)
+
+	v.Check(gun.Damage >= 0, "damage", "must not be negative")
+
+	if gun.ManufacturerID != nil {
+		v.Check(*gun.ManufacturerID > 0, "manufacturer_id", "must be a valid manufacturer id")
+	}
+	if gun.CategoryID != nil {
+		v.Check(*gun.CategoryID > 0, "category_id", "must be a valid category id")
+	}
+}
+
+// ValidateGunFilters checks that the catalog filter ranges are sensible.
+func ValidateGunFilters(v *validator.Validator, f GunFilters) {
+	v.Check(len(f.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	if f.MinPrice != nil {
+		v.Check(!f.MinPrice.IsNegative(), "min_price", "must not be negative")
+		v.Check(f.MinPrice.InCurrency(DefaultCurrency), "min_price", "must be in "+DefaultCurrency)
+	}
+	if f.MaxPrice != nil {
+		v.Check(f.MaxPrice.InCurrency(DefaultCurrency), "max_price", "must be in "+DefaultCurrency)
+	}
+	if f.MinPrice != nil && f.MaxPrice != nil && f.MinPrice.currency() == f.MaxPrice.currency() {
+		v.Check(f.MinPrice.Cmp(*f.MaxPrice) <= 0, "max_price", "must not be less than min_price")
+	}
+
+	if f.MinDamage != nil {
+		v.Check(*f.MinDamage >= 0, "min_damage", "must not be negative")
+	}
+	if f.MinDamage != nil && f.MaxDamage != nil {
+		v.Check(*f.MinDamage <= *f.MaxDamage, "max_damage", "must not be less than min_damage")
+	}
+}
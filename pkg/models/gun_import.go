@@ -0,0 +1,291 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrAmbiguousName = errors.New("name matches more than one gun")
+	ErrInvalidSpecs  = errors.New("specs don't match the category's spec schema")
+)
+
+// The actions an import takes for a row.
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+)
+
+// GunImportRow is a row of a catalog import. Rows are matched to existing guns by SKU, or by
+// name for rows without one. Manufacturer and Category are names, since that is what suppliers
+// send. A nil Damage, Manufacturer or Category means that the import leaves it unchanged.
+type GunImportRow struct {
+	Line         int
+	Gun          Gun
+	Damage       *int
+	Manufacturer *string
+	Category     *string
+
+	// Action is set by GunModel.Import to ImportCreate or ImportUpdate.
+	Action string
+}
+
+// ImportError lists the rows of an import that can't be applied. Index is the Line of the row.
+type ImportError struct {
+	Rows []*ItemError
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("%d import rows failed", len(e.Rows))
+}
+
+// Import creates or updates a gun for each row, in a single transaction, on behalf of the user
+// with userID. Either every row is applied, or none is and an *ImportError lists the rows at
+// fault. With dryRun the transaction is always rolled back, so the caller learns what the
+// import would do without changing anything.
+func (m GunModel) Import(ctx context.Context, rows []*GunImportRow, userID *int64, dryRun bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	manufacturers, err := manufacturerIDs(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	categories, err := categorySchemas(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	importErr := &ImportError{}
+
+	for _, row := range rows {
+		// A failed statement aborts the whole transaction, so each row runs in a savepoint that
+		// is rolled back on error, which lets us carry on and report every failing row.
+		_, err := tx.ExecContext(ctx, `SAVEPOINT import_row`)
+		if err != nil {
+			return err
+		}
+
+		err = importRow(ctx, tx, row, userID, manufacturers, categories)
+		if err != nil {
+			_, rollbackErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_row`)
+			if rollbackErr != nil {
+				return rollbackErr
+			}
+
+			switch {
+			case errors.Is(err, ErrManufacturerNotFound), errors.Is(err, ErrCategoryNotFound),
+				errors.Is(err, ErrAmbiguousName), errors.Is(err, ErrInvalidSpecs),
+				errors.Is(err, ErrDuplicateSKU):
+				importErr.Rows = append(importErr.Rows, &ItemError{Index: row.Line, Err: err})
+			default:
+				return err
+			}
+		}
+	}
+
+	if len(importErr.Rows) > 0 {
+		return importErr
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	return tx.Commit()
+}
+
+// importRow applies a single row as part of the transaction tx.
+func importRow(ctx context.Context, tx *sql.Tx, row *GunImportRow, userID *int64,
+	manufacturers map[string]int64, categories map[string]*Category) error {
+	gun := row.Gun
+
+	existing, err := findImportedGun(ctx, tx, &row.Gun)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		// Keep what the row doesn't set, and copy over what it does.
+		existing.Name, existing.Price = gun.Name, gun.Price
+		if gun.SKU != nil {
+			existing.SKU = gun.SKU
+		}
+		gun = *existing
+	} else {
+		gun.Specs = Specs{}
+	}
+
+	if row.Damage != nil {
+		gun.Damage = *row.Damage
+	}
+
+	if row.Manufacturer != nil {
+		gun.ManufacturerID = nil
+		if *row.Manufacturer != "" {
+			id, ok := manufacturers[strings.ToLower(*row.Manufacturer)]
+			if !ok {
+				return ErrManufacturerNotFound
+			}
+			gun.ManufacturerID = &id
+		}
+	}
+
+	var schema SpecSchema
+
+	if row.Category != nil {
+		gun.CategoryID = nil
+		if *row.Category != "" {
+			category, ok := categories[strings.ToLower(*row.Category)]
+			if !ok {
+				return ErrCategoryNotFound
+			}
+			gun.CategoryID = &category.ID
+		}
+	}
+
+	if gun.CategoryID != nil {
+		for _, category := range categories {
+			if category.ID == *gun.CategoryID {
+				schema = category.SpecSchema
+			}
+		}
+	}
+
+	// A new category can invalidate the specs the gun already has.
+	v := validator.New()
+	if ValidateSpecs(v, gun.Specs, schema); !v.Valid() {
+		return ErrInvalidSpecs
+	}
+
+	if existing != nil {
+		row.Action = ImportUpdate
+		err = updateGun(ctx, tx, &gun, userID)
+	} else {
+		row.Action = ImportCreate
+		err = insertGun(ctx, tx, &gun, userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	row.Gun = gun
+
+	return nil
+}
+
+// findImportedGun returns the gun that an import row applies to, or nil if the row is for a new
+// gun. The gun is locked until the end of the transaction.
+func findImportedGun(ctx context.Context, tx *sql.Tx, gun *Gun) (*Gun, error) {
+	var where string
+	var arg interface{}
+
+	if gun.SKU != nil {
+		where, arg = "guns.sku = $1", *gun.SKU
+	} else {
+		where, arg = "lower(guns.name) = lower($1)", gun.Name
+	}
+
+	query := `
+		SELECT ` + gunColumns + `
+		FROM ` + gunTables + `
+		WHERE ` + where + ` AND guns.deleted_at IS NULL
+		LIMIT 2
+		FOR UPDATE OF guns
+		`
+
+	rows, err := tx.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []*Gun
+
+	for rows.Next() {
+		var existing Gun
+
+		err := scanGun(rows, &existing)
+		if err != nil {
+			return nil, err
+		}
+
+		found = append(found, &existing)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, nil
+	case 1:
+		return found[0], nil
+	default:
+		return nil, ErrAmbiguousName
+	}
+}
+
+// manufacturerIDs maps the lowercased names of the manufacturers to their ids.
+func manufacturerIDs(ctx context.Context, q queryer) (map[string]int64, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, name FROM manufacturers`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64)
+
+	for rows.Next() {
+		var id int64
+		var name string
+
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+
+		ids[strings.ToLower(name)] = id
+	}
+
+	return ids, rows.Err()
+}
+
+// categorySchemas maps the lowercased names of the categories to the categories, with their
+// spec schemas.
+func categorySchemas(ctx context.Context, q queryer) (map[string]*Category, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, name, spec_schema FROM categories`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[string]*Category)
+
+	for rows.Next() {
+		var category Category
+
+		err := rows.Scan(&category.ID, &category.Name, &category.SpecSchema)
+		if err != nil {
+			return nil, err
+		}
+
+		categories[strings.ToLower(category.Name)] = &category
+	}
+
+	return categories, rows.Err()
+}
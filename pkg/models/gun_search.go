@@ -0,0 +1,127 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// searchCondition matches guns either through the full-text search column or through trigram
+// similarity on the name, so that misspelled queries such as "glok 19" still find "Glock 19".
+const searchCondition = `
+	(guns.search @@ websearch_to_tsquery('simple', $1)
+		OR guns.name % $1
+		OR $1 <% guns.name)
+	AND guns.deleted_at IS NULL
+	`
+
+// Search returns a page of guns matching the search query, ordered by relevance, along with the
+// total number of matching records. Relevance is the full-text rank plus the trigram word
+// similarity, so exact word matches rank first and fuzzy matches follow.
+func (m GunModel) Search(ctx context.Context, q string, filters Filters) ([]*Gun, int, error) {
+	query := `
+		SELECT ` + gunColumns + `
+		FROM ` + gunTables + `
+		WHERE ` + searchCondition + `
+		ORDER BY ts_rank(guns.search, websearch_to_tsquery('simple', $1))
+			+ word_similarity($1, guns.name) DESC, guns.id ASC
+		LIMIT $2 OFFSET $3
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	guns := []*Gun{}
+
+	for rows.Next() {
+		var gun Gun
+
+		err := scanGun(rows, &gun)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		guns = append(guns, &gun)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var totalRecords int
+
+	err = m.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM guns WHERE `+searchCondition, q).Scan(&totalRecords)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return guns, totalRecords, nil
+}
+
+// Suggest returns up to limit distinct gun names for the autocomplete box. Names starting with
+// the prefix come first, followed by fuzzy matches ordered by word similarity.
+func (m GunModel) Suggest(ctx context.Context, prefix string, limit int) ([]string, error) {
+	query := `
+		SELECT name
+		FROM guns
+		WHERE (name ILIKE $1 || '%' OR $2 <% name) AND deleted_at IS NULL
+		GROUP BY name
+		ORDER BY bool_or(name ILIKE $1 || '%') DESC, max(word_similarity($2, name)) DESC, name ASC
+		LIMIT $3
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, escapeLike(prefix), prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	suggestions := []string{}
+
+	for rows.Next() {
+		var name string
+
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// escapeLike escapes the LIKE wildcard characters in s so that it is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ValidateSearchQuery checks the search query string provided by the client.
+func ValidateSearchQuery(v *validator.Validator, q string) {
+	v.Check(strings.TrimSpace(q) != "", "q", "must be provided")
+	v.Check(len(q) <= 200, "q", "must not be more than 200 bytes long")
+}
@@ -0,0 +1,353 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInvalidImageOrder = errors.New("image ids don't match the images of the gun")
+)
+
+type (
+	// GunImage is a photo of a catalog gun. The image file and its thumbnail live in file
+	// storage under StorageKey and ThumbnailKey; the table only holds their metadata. Images
+	// are shown in Position order, and exactly one image of a gun that has any is its primary
+	// image.
+	GunImage struct {
+		ID           int64     `json:"id"`
+		GunID        int64     `json:"gun_id"`
+		Position     int       `json:"position"`
+		Primary      bool      `json:"primary"`
+		ContentType  string    `json:"content_type"`
+		Size         int64     `json:"size"`
+		Width        int       `json:"width"`
+		Height       int       `json:"height"`
+		StorageKey   string    `json:"-"`
+		ThumbnailKey string    `json:"-"`
+		URL          string    `json:"url"`
+		ThumbnailURL string    `json:"thumbnail_url"`
+		CreatedAt    time.Time `json:"created_at"`
+	}
+
+	// ImageModel struct wraps a sql.DB connection pool and allows us to work with the GunImage
+	// struct type and the gun_images table in our database.
+	ImageModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+const imageColumns = `id, gun_id, position, is_primary, content_type, size, width, height,
+	storage_key, thumbnail_key, created_at`
+
+// scanImage scans a row selected with imageColumns into the image, and fills in its URLs.
+func scanImage(row rowScanner, image *GunImage) error {
+	err := row.Scan(
+		&image.ID,
+		&image.GunID,
+		&image.Position,
+		&image.Primary,
+		&image.ContentType,
+		&image.Size,
+		&image.Width,
+		&image.Height,
+		&image.StorageKey,
+		&image.ThumbnailKey,
+		&image.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	image.setURLs()
+
+	return nil
+}
+
+func (i *GunImage) setURLs() {
+	i.URL = fmt.Sprintf("/images/%d/file", i.ID)
+	i.ThumbnailURL = fmt.Sprintf("/images/%d/thumbnail", i.ID)
+}
+
+// Insert adds an image at the end of the gun's images. The first image of a gun becomes its
+// primary image. It returns an ErrRecordNotFound error if the gun doesn't exist.
+func (m ImageModel) Insert(ctx context.Context, image *GunImage) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the gun so that concurrent uploads for the same gun get distinct positions.
+	err = tx.QueryRowContext(ctx, `SELECT id FROM guns WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, image.GunID).Scan(&image.GunID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO gun_images (gun_id, position, is_primary, content_type, size, width, height,
+			storage_key, thumbnail_key)
+		SELECT $1, COALESCE(MAX(position), 0) + 1, COUNT(*) = 0, $2, $3, $4, $5, $6, $7
+		FROM gun_images
+		WHERE gun_id = $1
+		RETURNING id, position, is_primary, created_at
+		`
+
+	args := []interface{}{
+		image.GunID,
+		image.ContentType,
+		image.Size,
+		image.Width,
+		image.Height,
+		image.StorageKey,
+		image.ThumbnailKey,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&image.ID,
+		&image.Position,
+		&image.Primary,
+		&image.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	image.setURLs()
+
+	return tx.Commit()
+}
+
+// Get retrieves a specific image by id.
+func (m ImageModel) Get(ctx context.Context, id int64) (*GunImage, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + imageColumns + ` FROM gun_images WHERE id = $1`
+
+	var image GunImage
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanImage(m.DB.QueryRowContext(ctx, query, id), &image)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &image, nil
+}
+
+// GetAllForGun returns the images of a gun in display order.
+func (m ImageModel) GetAllForGun(ctx context.Context, gunID int64) ([]*GunImage, error) {
+	query := `
+		SELECT ` + imageColumns + `
+		FROM gun_images
+		WHERE gun_id = $1
+		ORDER BY position, id
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, gunID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	images := []*GunImage{}
+
+	for rows.Next() {
+		var image GunImage
+
+		err := scanImage(rows, &image)
+		if err != nil {
+			return nil, err
+		}
+
+		images = append(images, &image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// Reorder sets the display order of the images of a gun to the order of ids, which must list
+// every image of the gun exactly once. Otherwise it returns an ErrInvalidImageOrder error.
+func (m ImageModel) Reorder(ctx context.Context, gunID int64, ids []int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The new positions are the 1-based indexes of the ids in the array. The update only
+	// succeeds if the ids cover all of the gun's images and nothing else.
+	query := `
+		WITH gun_image_ids AS (
+			SELECT id FROM gun_images WHERE gun_id = $1 FOR UPDATE
+		)
+		SELECT
+			(SELECT COUNT(*) FROM gun_image_ids),
+			(SELECT COUNT(DISTINCT id) FROM unnest($2::bigint[]) AS id WHERE id IN (SELECT id FROM gun_image_ids))
+		`
+
+	var total, matched int
+
+	err = tx.QueryRowContext(ctx, query, gunID, pq.Array(ids)).Scan(&total, &matched)
+	if err != nil {
+		return err
+	}
+
+	if total != len(ids) || matched != len(ids) {
+		return ErrInvalidImageOrder
+	}
+
+	query = `
+		UPDATE gun_images
+		SET position = ordered.position
+		FROM unnest($2::bigint[]) WITH ORDINALITY AS ordered(id, position)
+		WHERE gun_images.id = ordered.id AND gun_images.gun_id = $1
+		`
+
+	_, err = tx.ExecContext(ctx, query, gunID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetPrimary makes a specific image the primary image of its gun.
+func (m ImageModel) SetPrimary(ctx context.Context, id int64) (*GunImage, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The unique index on the primary image of a gun is checked row by row, so the current
+	// primary image has to be cleared before the new one is set.
+	query := `
+		UPDATE gun_images
+		SET is_primary = FALSE
+		WHERE gun_id = (SELECT gun_id FROM gun_images WHERE id = $1) AND is_primary AND id <> $1
+		`
+
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `UPDATE gun_images SET is_primary = TRUE WHERE id = $1 RETURNING ` + imageColumns
+
+	var image GunImage
+
+	err = scanImage(tx.QueryRowContext(ctx, query, id), &image)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &image, nil
+}
+
+// Delete removes a specific image and returns it, so that the caller can remove its files from
+// storage. If it was the primary image, the next image in display order becomes primary.
+func (m ImageModel) Delete(ctx context.Context, id int64) (*GunImage, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM gun_images WHERE id = $1 RETURNING ` + imageColumns
+
+	var image GunImage
+
+	err = scanImage(tx.QueryRowContext(ctx, query, id), &image)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if image.Primary {
+		query = `
+			UPDATE gun_images
+			SET is_primary = TRUE
+			WHERE id = (SELECT id FROM gun_images WHERE gun_id = $1 ORDER BY position, id LIMIT 1)
+			`
+
+		_, err = tx.ExecContext(ctx, query, image.GunID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &image, nil
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrDuplicateName        = errors.New("duplicate name")
+	ErrManufacturerNotFound = errors.New("manufacturer not found")
+)
+
+type (
+	// Manufacturer represents the maker of a gun.
+	Manufacturer struct {
+		ID        int64     `json:"id"`
+		Name      string    `json:"name"`
+		Country   string    `json:"country"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Version   int       `json:"version"`
+	}
+
+	// ManufacturerModel struct wraps a sql.DB connection pool and allows us to work with the
+	// Manufacturer struct type and the manufacturers table in our database.
+	ManufacturerModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+// Insert inserts a new record in the manufacturers table. Manufacturer names are unique, so we
+// return an ErrDuplicateName error if the name is already taken.
+func (m ManufacturerModel) Insert(ctx context.Context, manufacturer *Manufacturer) error {
+	query := `
+		INSERT INTO manufacturers (name, country)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at, version
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, manufacturer.Name, manufacturer.Country).Scan(
+		&manufacturer.ID,
+		&manufacturer.CreatedAt,
+		&manufacturer.UpdatedAt,
+		&manufacturer.Version,
+	)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "manufacturers_name_key"):
+			return ErrDuplicateName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get retrieves a specific manufacturer by id.
+func (m ManufacturerModel) Get(ctx context.Context, id int64) (*Manufacturer, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, name, country, created_at, updated_at, version
+		FROM manufacturers
+		WHERE id = $1
+		`
+
+	var manufacturer Manufacturer
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&manufacturer.ID,
+		&manufacturer.Name,
+		&manufacturer.Country,
+		&manufacturer.CreatedAt,
+		&manufacturer.UpdatedAt,
+		&manufacturer.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &manufacturer, nil
+}
+
+// GetAll returns a page of manufacturers along with the total number of manufacturers.
+func (m ManufacturerModel) GetAll(ctx context.Context, filters Filters) ([]*Manufacturer, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, name, country, created_at, updated_at, version
+		FROM manufacturers
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2
+		`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	manufacturers := []*Manufacturer{}
+
+	for rows.Next() {
+		var manufacturer Manufacturer
+
+		err := rows.Scan(
+			&totalRecords,
+			&manufacturer.ID,
+			&manufacturer.Name,
+			&manufacturer.Country,
+			&manufacturer.CreatedAt,
+			&manufacturer.UpdatedAt,
+			&manufacturer.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		manufacturers = append(manufacturers, &manufacturer)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return manufacturers, totalRecords, nil
+}
+
+// Update updates a specific manufacturer, checking against the version field to prevent lost
+// updates.
+func (m ManufacturerModel) Update(ctx context.Context, manufacturer *Manufacturer) error {
+	query := `
+		UPDATE manufacturers
+		SET name = $1, country = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING updated_at, version
+		`
+
+	args := []interface{}{manufacturer.Name, manufacturer.Country, manufacturer.ID, manufacturer.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&manufacturer.UpdatedAt, &manufacturer.Version)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "manufacturers_name_key"):
+			return ErrDuplicateName
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a specific manufacturer. Manufacturers that still have guns can't be deleted,
+// in which case we return an ErrRecordInUse error.
+func (m ManufacturerModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM manufacturers
+		WHERE id = $1
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordInUse
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ValidateManufacturer checks the fields of a manufacturer.
+func ValidateManufacturer(v *validator.Validator, manufacturer *Manufacturer) {
+	v.Check(manufacturer.Name != "", "name", "must be provided")
+	v.Check(len(manufacturer.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(manufacturer.Country) <= 100, "country", "must not be more than 100 bytes long")
+}
@@ -1,47 +1,170 @@
-package models
-
-import (
-	"database/sql"
-	"errors"
-	"log"
-	"os"
-)
-
-var (
-	ErrRecordNotFound = errors.New("record not found")
-	ErrEditConflict   = errors.New("edit conflict")
-)
-
-type Models struct {
-	Guns        GunModel
-	Users       UserModel
-	Token       TokenModel
-	Permissions PermissionModel
-}
-
-func NewModels(db *sql.DB) Models {
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	return Models{
-		Guns: GunModel{
-			DB:       db,
-			InfoLog:  infoLog,
-			ErrorLog: errorLog,
-		},
-		Users: UserModel{
-			DB:       db,
-			InfoLog:  infoLog,
-			ErrorLog: errorLog,
-		},
-		Token: TokenModel{
-			DB:       db,
-			InfoLog:  infoLog,
-			ErrorLog: errorLog,
-		},
-		Permissions: PermissionModel{
-			DB:       db,
-			InfoLog:  infoLog,
-			ErrorLog: errorLog,
-		},
-	}
-}
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	ErrEditConflict   = errors.New("edit conflict")
+	ErrRecordInUse    = errors.New("record in use")
+)
+
+// ItemError wraps an error caused by a specific line item of a multi-line request, such as a
+// sale, so that handlers can tell the client which line was at fault.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// violatesConstraint reports whether err is a PostgreSQL error raised by the named constraint.
+func violatesConstraint(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Constraint == constraint
+}
+
+// isForeignKeyViolation reports whether err is a PostgreSQL foreign key violation, which is
+// what we get when deleting a row that other rows still reference (or inserting a row that
+// references a missing one).
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+type Models struct {
+	Guns           GunModel
+	Users          UserModel
+	Token          TokenModel
+	Permissions    PermissionModel
+	Stock          StockModel
+	Sales          SaleModel
+	Customers      CustomerModel
+	Units          UnitModel
+	Manufacturers  ManufacturerModel
+	Categories     CategoryModel
+	Images         ImageModel
+	Prices         PriceModel
+	Promotions     PromotionModel
+	Reservations   ReservationModel
+	Suppliers      SupplierModel
+	PurchaseOrders PurchaseOrderModel
+	Returns        ReturnModel
+	ReorderAlerts  ReorderAlertModel
+	BoundBook      BoundBookModel
+}
+
+func NewModels(db *sql.DB) Models {
+	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	return Models{
+		Guns: GunModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Users: UserModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Token: TokenModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Permissions: PermissionModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Stock: StockModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Sales: SaleModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Customers: CustomerModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Units: UnitModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Manufacturers: ManufacturerModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Categories: CategoryModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Images: ImageModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Prices: PriceModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Promotions: PromotionModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Reservations: ReservationModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Suppliers: SupplierModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		PurchaseOrders: PurchaseOrderModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Returns: ReturnModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		ReorderAlerts: ReorderAlertModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		BoundBook: BoundBookModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+	}
+}
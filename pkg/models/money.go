@@ -0,0 +1,313 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the ISO 4217 code of the currency the catalog is priced in. Amounts without
+// a currency code, and amounts in numeric columns, are in this currency.
+const DefaultCurrency = "USD"
+
+// moneyScale is the number of minor units in a major unit, e.g. cents in a dollar. Every
+// currency we price in has two decimal places.
+const moneyScale = 100
+
+var (
+	ErrInvalidMoneyFormat = errors.New("invalid money format, expected a decimal amount with at most two decimal places and an optional currency code")
+	ErrMoneyOverflow      = errors.New("money: amount out of range")
+	ErrCurrencyMismatch   = errors.New("money: amounts in different currencies")
+)
+
+// Money is an exact amount of money in a currency, held as an integer number of minor units
+// (cents) so that sums and products never pick up the rounding drift of floating point. It is
+// encoded in JSON as a decimal string followed by the currency code, such as "499.99 USD", and
+// stored in numeric columns, which hold amounts in DefaultCurrency.
+//
+// An empty Currency is DefaultCurrency, so the zero Money is zero in the default currency; use
+// Equal rather than == to compare amounts. Adding, subtracting or comparing amounts in
+// different currencies panics with ErrCurrencyMismatch, and arithmetic that would overflow int64
+// panics with ErrMoneyOverflow rather than wrapping around. Validated amounts are always in
+// DefaultCurrency and far too small to overflow, so either panic is a programming error.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney returns an amount of minor units in the default currency.
+func NewMoney(amount int64) Money {
+	return Money{Amount: amount, Currency: DefaultCurrency}
+}
+
+// ParseMoney parses a decimal amount such as "499.99" or "-5", optionally followed by a currency
+// code as in "499.99 EUR". Amounts without a code are in the default currency. More than two
+// decimal places are rejected rather than rounded.
+func ParseMoney(s string) (Money, error) {
+	s, currency, err := cutCurrency(s)
+	if err != nil {
+		return Money{}, err
+	}
+
+	amount, exact, err := parseMinorUnits(s)
+	if err != nil || !exact {
+		return Money{}, ErrInvalidMoneyFormat
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// cutCurrency splits the currency code off the end of an amount, returning DefaultCurrency if
+// there is none. Codes are three upper case letters.
+func cutCurrency(s string) (amount, currency string, err error) {
+	amount, currency, found := strings.Cut(strings.TrimSpace(s), " ")
+	if !found {
+		return amount, DefaultCurrency, nil
+	}
+
+	currency = strings.TrimSpace(currency)
+	if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", "", ErrInvalidMoneyFormat
+	}
+
+	return amount, currency, nil
+}
+
+// parseMinorUnits converts a decimal string to minor units, rounding half away from zero. exact
+// reports whether no rounding was needed.
+func parseMinorUnits(s string) (amount int64, exact bool, err error) {
+	s = strings.TrimSpace(s)
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, false, ErrInvalidMoneyFormat
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	for _, part := range []string{whole, frac} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, false, ErrInvalidMoneyFormat
+		}
+	}
+
+	exact = len(strings.TrimRight(frac, "0")) <= 2
+
+	// Round on the third decimal place and drop the rest.
+	roundUp := len(frac) > 2 && frac[2] >= '5'
+	frac = (frac + "00")[:2]
+
+	amount, err = strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, false, ErrInvalidMoneyFormat
+	}
+	if roundUp {
+		if amount == math.MaxInt64 {
+			return 0, false, ErrInvalidMoneyFormat
+		}
+		amount++
+	}
+	if negative {
+		amount = -amount
+	}
+
+	return amount, exact, nil
+}
+
+// String returns the amount as a decimal string with two decimal places, without the currency.
+func (m Money) String() string {
+	sign := ""
+	amount := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, amount/moneyScale, amount%moneyScale)
+}
+
+// currency returns the currency of the amount, treating an empty Currency as DefaultCurrency.
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// mustMatch panics with ErrCurrencyMismatch if two amounts are in different currencies.
+func (m Money) mustMatch(o Money) {
+	if m.currency() != o.currency() {
+		panic(ErrCurrencyMismatch)
+	}
+}
+
+// InCurrency reports whether the amount is in the currency with the provided code.
+func (m Money) InCurrency(currency string) bool {
+	return m.currency() == currency
+}
+
+// Equal reports whether two amounts are the same amount in the same currency.
+func (m Money) Equal(o Money) bool {
+	return m.Amount == o.Amount && m.currency() == o.currency()
+}
+
+// Add returns m + o.
+func (m Money) Add(o Money) Money {
+	m.mustMatch(o)
+
+	sum := m.Amount + o.Amount
+	if (sum > m.Amount) != (o.Amount > 0) {
+		panic(ErrMoneyOverflow)
+	}
+	return Money{Amount: sum, Currency: m.currency()}
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) Money {
+	m.mustMatch(o)
+
+	difference := m.Amount - o.Amount
+	if (difference < m.Amount) != (o.Amount > 0) {
+		panic(ErrMoneyOverflow)
+	}
+	return Money{Amount: difference, Currency: m.currency()}
+}
+
+// Mul returns m multiplied by a quantity.
+func (m Money) Mul(quantity int64) Money {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(quantity))
+	return Money{Amount: moneyAmount(product), Currency: m.currency()}
+}
+
+// MulRatio returns m * num / den, rounded half away from zero to a whole minor unit. It is used
+// for percentages, e.g. MulRatio(125, 1000) for 12.5%. The product is worked out exactly, so
+// only a result that doesn't fit in a Money overflows. den must not be zero.
+func (m Money) MulRatio(num, den int64) Money {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(num))
+	divisor := big.NewInt(den)
+	negative := product.Sign()*divisor.Sign() < 0
+
+	quotient, remainder := new(big.Int).QuoRem(product, divisor, new(big.Int))
+
+	// Round away from zero when the remainder is at least half the divisor.
+	twiceRemainder := remainder.Lsh(remainder.Abs(remainder), 1)
+	if twiceRemainder.Cmp(divisor.Abs(divisor)) >= 0 {
+		if negative {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+
+	return Money{Amount: moneyAmount(quotient), Currency: m.currency()}
+}
+
+// moneyAmount returns an exact amount as minor units, panicking if it doesn't fit in int64.
+func moneyAmount(amount *big.Int) int64 {
+	if !amount.IsInt64() {
+		panic(ErrMoneyOverflow)
+	}
+	return amount.Int64()
+}
+
+// Cmp compares two amounts in the same currency and returns -1, 0 or +1.
+func (m Money) Cmp(o Money) int {
+	m.mustMatch(o)
+
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is less than zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// MarshalJSON encodes the amount as a decimal string followed by its currency, e.g.
+// "499.99 USD".
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String() + " " + m.currency())), nil
+}
+
+// UnmarshalJSON decodes a decimal string with an optional currency code, as ParseMoney does.
+// Plain JSON numbers are accepted too, in the default currency, for clients written before
+// prices became strings, but are held to the same two decimal places. null leaves the amount
+// unchanged, as it does for the built-in types.
+func (m *Money) UnmarshalJSON(js []byte) error {
+	s := string(js)
+	if s == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	money, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+
+	*m = money
+	return nil
+}
+
+// Scan implements the sql.Scanner interface for numeric columns, whose amounts are in the
+// default currency, and for text holding an amount and its currency code. Amounts with more than
+// two decimal places are rounded half away from zero.
+func (m *Money) Scan(src interface{}) error {
+	var s string
+
+	switch src := src.(type) {
+	case []byte:
+		s = string(src)
+	case string:
+		s = src
+	case int64:
+		s = strconv.FormatInt(src, 10)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+
+	value, currency, err := cutCurrency(s)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into Money: %w", s, err)
+	}
+
+	amount, _, err := parseMinorUnits(value)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into Money: %w", s, err)
+	}
+
+	*m = Money{Amount: amount, Currency: currency}
+	return nil
+}
+
+// Value implements the driver.Valuer interface for numeric columns. Those hold amounts in the
+// default currency, so amounts in any other currency are refused with ErrCurrencyMismatch rather
+// than stored as if they were.
+func (m Money) Value() (driver.Value, error) {
+	if m.currency() != DefaultCurrency {
+		return nil, fmt.Errorf("cannot store a %s amount in a %s column: %w", m.currency(), DefaultCurrency, ErrCurrencyMismatch)
+	}
+	return m.String(), nil
+}
@@ -0,0 +1,358 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		want     int64
+		currency string
+		wantErr  bool
+	}{
+		{input: "499.99", want: 49999},
+		{input: "499.99 EUR", want: 49999, currency: "EUR"},
+		{input: "-5 USD", want: -500},
+		{input: "5  GBP ", want: 500, currency: "GBP"},
+		{input: "5", want: 500},
+		{input: "-5", want: -500},
+		{input: "+5.5", want: 550},
+		{input: ".5", want: 50},
+		{input: "7.", want: 700},
+		{input: " 12.30 ", want: 1230},
+		{input: "1.2300", want: 123},
+		{input: "0.00", want: 0},
+		{input: "92233720368547758.07", want: math.MaxInt64},
+		{input: "1.234", wantErr: true},
+		{input: "", wantErr: true},
+		{input: ".", wantErr: true},
+		{input: "-", wantErr: true},
+		{input: "1,000.00", wantErr: true},
+		{input: "1e3", wantErr: true},
+		{input: "12.3.4", wantErr: true},
+		{input: "--5", wantErr: true},
+		{input: "92233720368547758.08", wantErr: true},
+		{input: "5 eur", wantErr: true},
+		{input: "5 EURO", wantErr: true},
+		{input: "5 US$", wantErr: true},
+		{input: "USD 5", wantErr: true},
+		{input: "1 000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if tt.currency == "" {
+			tt.currency = DefaultCurrency
+		}
+
+		got, err := ParseMoney(tt.input)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidMoneyFormat) {
+				t.Errorf("ParseMoney(%q) error = %v, want ErrInvalidMoneyFormat", tt.input, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got != (Money{Amount: tt.want, Currency: tt.currency}) {
+			t.Errorf("ParseMoney(%q) = %d %s, want %d %s", tt.input, got.Amount, got.Currency, tt.want, tt.currency)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{amount: 0, want: "0.00"},
+		{amount: 5, want: "0.05"},
+		{amount: 50, want: "0.50"},
+		{amount: 49999, want: "499.99"},
+		{amount: -5, want: "-0.05"},
+		{amount: -49999, want: "-499.99"},
+		{amount: math.MaxInt64, want: "92233720368547758.07"},
+		{amount: math.MinInt64, want: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		if got := NewMoney(tt.amount).String(); got != tt.want {
+			t.Errorf("NewMoney(%d).String() = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyScanRounds(t *testing.T) {
+	tests := []struct {
+		src      interface{}
+		want     int64
+		currency string
+	}{
+		{src: []byte("499.99"), want: 49999},
+		{src: "1.005", want: 101},
+		{src: "1.004", want: 100},
+		{src: "1.0049", want: 100},
+		{src: "-1.005", want: -101},
+		{src: "-1.004", want: -100},
+		{src: int64(3), want: 300},
+		{src: "12.50 EUR", want: 1250, currency: "EUR"},
+	}
+
+	for _, tt := range tests {
+		if tt.currency == "" {
+			tt.currency = DefaultCurrency
+		}
+
+		var m Money
+		if err := m.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%v) error = %v", tt.src, err)
+			continue
+		}
+		if m.Amount != tt.want || m.Currency != tt.currency {
+			t.Errorf("Scan(%v) = %d %s, want %d %s", tt.src, m.Amount, m.Currency, tt.want, tt.currency)
+		}
+	}
+
+	var m Money
+	if err := m.Scan(1.5); err == nil {
+		t.Error("Scan(float64) error = nil, want an error")
+	}
+	if err := m.Scan("1.50 euros"); err == nil {
+		t.Error(`Scan("1.50 euros") error = nil, want an error`)
+	}
+}
+
+func TestMoneyValue(t *testing.T) {
+	tests := []struct {
+		m       Money
+		want    string
+		wantErr bool
+	}{
+		{m: NewMoney(49999), want: "499.99"},
+		{m: Money{Amount: -5}, want: "-0.05"},
+		{m: Money{Amount: 100, Currency: "EUR"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.m.Value()
+		if tt.wantErr {
+			if !errors.Is(err, ErrCurrencyMismatch) {
+				t.Errorf("%+v.Value() error = %v, want ErrCurrencyMismatch", tt.m, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%+v.Value() = %v, %v, want %q", tt.m, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyEqual(t *testing.T) {
+	tests := []struct {
+		a, b Money
+		want bool
+	}{
+		{a: Money{}, b: NewMoney(0), want: true},
+		{a: Money{Amount: 5}, b: NewMoney(5), want: true},
+		{a: NewMoney(5), b: NewMoney(6), want: false},
+		{a: NewMoney(5), b: Money{Amount: 5, Currency: "EUR"}, want: false},
+		{a: Money{Amount: 5, Currency: "EUR"}, b: Money{Amount: 5, Currency: "EUR"}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.want {
+			t.Errorf("%+v.Equal(%+v) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	parsed, err := ParseMoney("0")
+	if err != nil || !parsed.Equal(Money{}) {
+		t.Errorf(`ParseMoney("0") = %v, %v, want the zero Money`, parsed, err)
+	}
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	usd, eur := NewMoney(100), Money{Amount: 100, Currency: "EUR"}
+
+	if got := (Money{}).Add(usd); got != NewMoney(100) {
+		t.Errorf("Money{}.Add(100 USD) = %+v, want 100 USD", got)
+	}
+	if got := eur.Mul(3); got != (Money{Amount: 300, Currency: "EUR"}) {
+		t.Errorf("Mul kept currency %q, want EUR", got.Currency)
+	}
+	if got := eur.MulRatio(1, 2); got.Currency != "EUR" {
+		t.Errorf("MulRatio kept currency %q, want EUR", got.Currency)
+	}
+
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{name: "Add", fn: func() { usd.Add(eur) }},
+		{name: "Sub", fn: func() { eur.Sub(usd) }},
+		{name: "Cmp", fn: func() { usd.Cmp(eur) }},
+		{name: "zero Add", fn: func() { Money{}.Add(eur) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != ErrCurrencyMismatch {
+					t.Errorf("recovered %v, want ErrCurrencyMismatch", r)
+				}
+			}()
+
+			tt.fn()
+			t.Error("mixing currencies didn't panic")
+		})
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a, b := NewMoney(1050), NewMoney(-250)
+
+	if got := a.Add(b); got != NewMoney(800) {
+		t.Errorf("Add = %d, want 800", got.Amount)
+	}
+	if got := a.Sub(b); got != NewMoney(1300) {
+		t.Errorf("Sub = %d, want 1300", got.Amount)
+	}
+	if got := a.Mul(3); got != NewMoney(3150) {
+		t.Errorf("Mul = %d, want 3150", got.Amount)
+	}
+	if got := b.Mul(-4); got != NewMoney(1000) {
+		t.Errorf("Mul = %d, want 1000", got.Amount)
+	}
+	if a.Cmp(b) != 1 || b.Cmp(a) != -1 || a.Cmp(NewMoney(1050)) != 0 {
+		t.Error("Cmp gave the wrong order")
+	}
+}
+
+func TestMoneyMulRatio(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		num, den int64
+		want     int64
+	}{
+		{amount: 10000, num: 125, den: 1000, want: 1250},
+		{amount: 999, num: 10, den: 100, want: 100},  // 99.9 rounds up
+		{amount: 994, num: 10, den: 100, want: 99},   // 99.4 rounds down
+		{amount: 5, num: 1, den: 2, want: 3},         // 2.5 rounds away from zero
+		{amount: -5, num: 1, den: 2, want: -3},       // -2.5 rounds away from zero
+		{amount: 5, num: -1, den: 2, want: -3},       // negative ratio
+		{amount: 5, num: 1, den: -2, want: -3},       // negative divisor
+		{amount: -5, num: -1, den: -2, want: -3},     // three negatives
+		{amount: -994, num: 10, den: 100, want: -99}, // -99.4 rounds towards zero
+		{amount: 1, num: 1, den: 3, want: 0},
+		{amount: 2, num: 1, den: 3, want: 1},
+		{amount: 0, num: 7, den: 9, want: 0},
+		{amount: math.MaxInt64, num: 100, den: 100, want: math.MaxInt64},       // product overflows int64
+		{amount: math.MaxInt64 / 2, num: 3, den: 4, want: 3458764513820540927}, // exact product wider than int64
+	}
+
+	for _, tt := range tests {
+		if got := NewMoney(tt.amount).MulRatio(tt.num, tt.den); got.Amount != tt.want {
+			t.Errorf("NewMoney(%d).MulRatio(%d, %d) = %d, want %d", tt.amount, tt.num, tt.den, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestMoneyOverflow(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() Money
+	}{
+		{name: "Add", fn: func() Money { return NewMoney(math.MaxInt64).Add(NewMoney(1)) }},
+		{name: "Add negative", fn: func() Money { return NewMoney(math.MinInt64).Add(NewMoney(-1)) }},
+		{name: "Sub", fn: func() Money { return NewMoney(math.MinInt64).Sub(NewMoney(1)) }},
+		{name: "Sub negative", fn: func() Money { return NewMoney(math.MaxInt64).Sub(NewMoney(-1)) }},
+		{name: "Mul", fn: func() Money { return NewMoney(math.MaxInt64 / 2).Mul(3) }},
+		{name: "Mul MinInt64", fn: func() Money { return NewMoney(math.MinInt64).Mul(-1) }},
+		{name: "MulRatio", fn: func() Money { return NewMoney(math.MaxInt64).MulRatio(3, 2) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != ErrMoneyOverflow {
+					t.Errorf("recovered %v, want ErrMoneyOverflow", r)
+				}
+			}()
+
+			got := tt.fn()
+			t.Errorf("got %d, want a panic", got.Amount)
+		})
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	marshalTests := []struct {
+		m    Money
+		want string
+	}{
+		{m: NewMoney(-49999), want: `"-499.99 USD"`},
+		{m: Money{}, want: `"0.00 USD"`},
+		{m: Money{Amount: 1250, Currency: "EUR"}, want: `"12.50 EUR"`},
+	}
+
+	for _, tt := range marshalTests {
+		js, err := json.Marshal(tt.m)
+		if err != nil || string(js) != tt.want {
+			t.Errorf("Marshal(%+v) = %s, %v, want %s", tt.m, js, err, tt.want)
+		}
+
+		var back Money
+		if err := json.Unmarshal(js, &back); err != nil || !back.Equal(tt.m) {
+			t.Errorf("Unmarshal(%s) = %+v, %v, want %+v", js, back, err, tt.m)
+		}
+	}
+
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: `"499.99"`, want: 49999},
+		{input: `"499.99 USD"`, want: 49999},
+		{input: `499.99`, want: 49999},
+		{input: `"-5"`, want: -500},
+		{input: `12`, want: 1200},
+		{input: `null`, want: 777},
+		{input: `"1.234"`, wantErr: true},
+		{input: `1.234`, wantErr: true},
+		{input: `""`, wantErr: true},
+		{input: `"abc"`, wantErr: true},
+		{input: `true`, wantErr: true},
+		{input: `"5 usd"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		m := NewMoney(777)
+		err := json.Unmarshal([]byte(tt.input), &m)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) error = nil, want an error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.input, err)
+			continue
+		}
+		if m.Amount != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.input, m.Amount, tt.want)
+		}
+	}
+
+	var input struct {
+		Price *Money `json:"price"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": null}`), &input); err != nil || input.Price != nil {
+		t.Errorf("Unmarshal of a null *Money = %v, %v, want nil", input.Price, err)
+	}
+}
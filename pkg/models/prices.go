@@ -0,0 +1,327 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrScheduledPriceNotPending = errors.New("scheduled price change already applied or cancelled")
+)
+
+type (
+	// PriceChange is an entry of the price history of a gun. OldPrice is nil for the price the
+	// gun was created with. ScheduledPriceID is set when the change was a scheduled one.
+	PriceChange struct {
+		ID               int64     `json:"id"`
+		GunID            int64     `json:"gun_id"`
+		OldPrice         *Money    `json:"old_price"`
+		NewPrice         Money     `json:"new_price"`
+		UserID           *int64    `json:"user_id"`
+		ScheduledPriceID *int64    `json:"scheduled_price_id,omitempty"`
+		ChangedAt        time.Time `json:"changed_at"`
+	}
+
+	// ScheduledPrice is a price change that takes effect at EffectiveAt. It is pending until it
+	// is either applied by ApplyDue or cancelled.
+	ScheduledPrice struct {
+		ID          int64      `json:"id"`
+		GunID       int64      `json:"gun_id"`
+		Price       Money      `json:"price"`
+		EffectiveAt time.Time  `json:"effective_at"`
+		UserID      *int64     `json:"user_id"`
+		CreatedAt   time.Time  `json:"created_at"`
+		AppliedAt   *time.Time `json:"applied_at"`
+		CancelledAt *time.Time `json:"cancelled_at"`
+	}
+
+	// PriceModel struct wraps a sql.DB connection pool and allows us to work with the price
+	// history and the scheduled price changes of guns.
+	PriceModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+// GetHistory returns a page of the price history of a gun, along with the total number of
+// entries. With at set, only the entries up to that time are returned, so the first entry in
+// the default newest first order is the price the gun had at that time.
+func (m PriceModel) GetHistory(ctx context.Context, gunID int64, at *time.Time, filters Filters) ([]*PriceChange, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, gun_id, old_price, new_price, user_id, scheduled_price_id,
+			changed_at
+		FROM gun_price_history
+		WHERE gun_id = $1 AND ($2::timestamptz IS NULL OR changed_at <= $2)
+		ORDER BY %s %s, id %[2]s
+		LIMIT $3 OFFSET $4
+		`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, gunID, at, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	changes := []*PriceChange{}
+
+	for rows.Next() {
+		var change PriceChange
+
+		err := rows.Scan(
+			&totalRecords,
+			&change.ID,
+			&change.GunID,
+			&change.OldPrice,
+			&change.NewPrice,
+			&change.UserID,
+			&change.ScheduledPriceID,
+			&change.ChangedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		changes = append(changes, &change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return changes, totalRecords, nil
+}
+
+const scheduledPriceColumns = `id, gun_id, price, effective_at, user_id, created_at, applied_at,
+	cancelled_at`
+
+func scanScheduledPrice(row rowScanner, sp *ScheduledPrice) error {
+	return row.Scan(
+		&sp.ID,
+		&sp.GunID,
+		&sp.Price,
+		&sp.EffectiveAt,
+		&sp.UserID,
+		&sp.CreatedAt,
+		&sp.AppliedAt,
+		&sp.CancelledAt,
+	)
+}
+
+// Schedule records a future price change. It returns an ErrRecordNotFound error if the gun
+// doesn't exist.
+func (m PriceModel) Schedule(ctx context.Context, sp *ScheduledPrice) error {
+	query := `
+		INSERT INTO scheduled_prices (gun_id, price, effective_at, user_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, sp.GunID, sp.Price, sp.EffectiveAt, sp.UserID).Scan(
+		&sp.ID,
+		&sp.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetScheduled returns the scheduled price changes of a gun that are still pending, in the
+// order they take effect.
+func (m PriceModel) GetScheduled(ctx context.Context, gunID int64) ([]*ScheduledPrice, error) {
+	query := `
+		SELECT ` + scheduledPriceColumns + `
+		FROM scheduled_prices
+		WHERE gun_id = $1 AND applied_at IS NULL AND cancelled_at IS NULL
+		ORDER BY effective_at, id
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, gunID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	scheduled := []*ScheduledPrice{}
+
+	for rows.Next() {
+		var sp ScheduledPrice
+
+		err := scanScheduledPrice(rows, &sp)
+		if err != nil {
+			return nil, err
+		}
+
+		scheduled = append(scheduled, &sp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return scheduled, nil
+}
+
+// Cancel cancels a pending scheduled price change. It returns an ErrRecordNotFound error if
+// there is no such change, and an ErrScheduledPriceNotPending error if it was already applied
+// or cancelled.
+func (m PriceModel) Cancel(ctx context.Context, id int64) (*ScheduledPrice, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE scheduled_prices
+		SET cancelled_at = NOW()
+		WHERE id = $1 AND applied_at IS NULL AND cancelled_at IS NULL
+		RETURNING ` + scheduledPriceColumns
+
+	var sp ScheduledPrice
+
+	err := scanScheduledPrice(m.DB.QueryRowContext(ctx, query, id), &sp)
+	if err == nil {
+		return &sp, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// Nothing was updated, so find out whether the change doesn't exist or isn't pending.
+	err = m.DB.QueryRowContext(ctx, `SELECT id FROM scheduled_prices WHERE id = $1`, id).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, ErrScheduledPriceNotPending
+}
+
+// ApplyDue applies every pending scheduled price change whose effective time is at or before
+// now, in the order they take effect, and returns how many were applied. Each change updates
+// the price and version of its gun and is recorded in the price history as made by the user
+// who scheduled it. Rows locked by another worker are skipped, so that several instances can
+// run this concurrently.
+func (m PriceModel) ApplyDue(ctx context.Context, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + scheduledPriceColumns + `
+		FROM scheduled_prices
+		WHERE effective_at <= $1 AND applied_at IS NULL AND cancelled_at IS NULL
+		ORDER BY effective_at, id
+		LIMIT 500
+		FOR UPDATE SKIP LOCKED
+		`
+
+	rows, err := tx.QueryContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var due []*ScheduledPrice
+
+	for rows.Next() {
+		var sp ScheduledPrice
+
+		err := scanScheduledPrice(rows, &sp)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		due = append(due, &sp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for _, sp := range due {
+		query := `
+			WITH previous AS (
+				SELECT price FROM guns WHERE id = $1 FOR UPDATE
+			), updated AS (
+				UPDATE guns
+				SET price = $2, updated_at = NOW(), version = version + 1
+				WHERE id = $1
+				RETURNING id, price
+			)
+			INSERT INTO gun_price_history (gun_id, old_price, new_price, user_id, scheduled_price_id)
+			SELECT updated.id, previous.price, updated.price, $3, $4
+			FROM previous, updated
+			WHERE previous.price <> updated.price
+			`
+
+		_, err := tx.ExecContext(ctx, query, sp.GunID, sp.Price, sp.UserID, sp.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE scheduled_prices SET applied_at = $2 WHERE id = $1`, sp.ID, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(due), nil
+}
+
+// ValidateScheduledPrice checks a new scheduled price change.
+func ValidateScheduledPrice(v *validator.Validator, sp *ScheduledPrice) {
+	v.Check(!sp.Price.IsNegative(), "price", "must not be negative")
+	v.Check(sp.Price.InCurrency(DefaultCurrency), "price", "must be in "+DefaultCurrency)
+
+	v.Check(!sp.EffectiveAt.IsZero(), "effective_at", "must be provided")
+	v.Check(sp.EffectiveAt.After(time.Now()), "effective_at", "must be in the future")
+}
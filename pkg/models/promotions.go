@@ -0,0 +1,604 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPromotionTargetNotFound = errors.New("promotion target not found")
+)
+
+type (
+	// Promotion is a discount that applies between StartsAt and EndsAt to the guns it targets.
+	// Exactly one of PercentOff and AmountOff is set; AmountOff is taken off each unit. The
+	// targets narrow down the guns the promotion applies to, and a promotion without targets
+	// applies to the whole catalog. With MinQuantity above one, the promotion only applies once
+	// the basket holds at least that many of the targeted guns, e.g. for buy-two bundles.
+	//
+	// A gun is priced with the single best non-stackable promotion, or with all its stackable
+	// promotions together, whichever is cheaper. See bestPrice for how they combine.
+	Promotion struct {
+		ID             int64     `json:"id"`
+		Name           string    `json:"name"`
+		PercentOff     *int      `json:"percent_off,omitempty"`
+		AmountOff      *Money    `json:"amount_off,omitempty"`
+		GunID          *int64    `json:"gun_id"`
+		CategoryID     *int64    `json:"category_id"`
+		ManufacturerID *int64    `json:"manufacturer_id"`
+		MinQuantity    int       `json:"min_quantity"`
+		Stackable      bool      `json:"stackable"`
+		StartsAt       time.Time `json:"starts_at"`
+		EndsAt         time.Time `json:"ends_at"`
+		CreatedAt      time.Time `json:"created_at"`
+		UpdatedAt      time.Time `json:"updated_at"`
+		Version        int       `json:"version"`
+	}
+
+	// QuoteItem is a line of a basket to be priced.
+	QuoteItem struct {
+		GunID    int64 `json:"gun_id"`
+		Quantity int   `json:"quantity"`
+	}
+
+	// QuoteLine is a priced line of a basket. ListPrice is the catalog price of the gun and
+	// UnitPrice the price after the promotions listed in PromotionIDs. Discount and Total are for
+	// the whole line.
+	QuoteLine struct {
+		GunID        int64   `json:"gun_id"`
+		Quantity     int     `json:"quantity"`
+		ListPrice    Money   `json:"list_price"`
+		UnitPrice    Money   `json:"unit_price"`
+		Discount     Money   `json:"discount"`
+		Total        Money   `json:"total"`
+		PromotionIDs []int64 `json:"promotion_ids"`
+	}
+
+	// Quote is a priced basket. Subtotal is the basket at list prices.
+	Quote struct {
+		Lines    []*QuoteLine `json:"lines"`
+		Subtotal Money        `json:"subtotal"`
+		Discount Money        `json:"discount"`
+		Total    Money        `json:"total"`
+		QuotedAt time.Time    `json:"quoted_at"`
+	}
+
+	// PromotionModel struct wraps a sql.DB connection pool and allows us to work with the
+	// Promotion struct type and the promotions table in our database.
+	PromotionModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+const promotionColumns = `id, name, percent_off, amount_off, gun_id, category_id, manufacturer_id,
+	min_quantity, stackable, starts_at, ends_at, created_at, updated_at, version`
+
+func scanPromotion(row rowScanner, promotion *Promotion) error {
+	return row.Scan(
+		&promotion.ID,
+		&promotion.Name,
+		&promotion.PercentOff,
+		&promotion.AmountOff,
+		&promotion.GunID,
+		&promotion.CategoryID,
+		&promotion.ManufacturerID,
+		&promotion.MinQuantity,
+		&promotion.Stackable,
+		&promotion.StartsAt,
+		&promotion.EndsAt,
+		&promotion.CreatedAt,
+		&promotion.UpdatedAt,
+		&promotion.Version,
+	)
+}
+
+// promotionWriteError maps the errors of promotion inserts and updates.
+func promotionWriteError(err error) error {
+	switch {
+	case isForeignKeyViolation(err):
+		return ErrPromotionTargetNotFound
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrEditConflict
+	default:
+		return err
+	}
+}
+
+// Insert inserts a new record in the promotions table. It returns an ErrPromotionTargetNotFound
+// error if one of the targets doesn't exist.
+func (m PromotionModel) Insert(ctx context.Context, promotion *Promotion) error {
+	query := `
+		INSERT INTO promotions (name, percent_off, amount_off, gun_id, category_id, manufacturer_id,
+			min_quantity, stackable, starts_at, ends_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at, version
+		`
+
+	args := []interface{}{
+		promotion.Name,
+		promotion.PercentOff,
+		promotion.AmountOff,
+		promotion.GunID,
+		promotion.CategoryID,
+		promotion.ManufacturerID,
+		promotion.MinQuantity,
+		promotion.Stackable,
+		promotion.StartsAt,
+		promotion.EndsAt,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&promotion.ID,
+		&promotion.CreatedAt,
+		&promotion.UpdatedAt,
+		&promotion.Version,
+	)
+	if err != nil {
+		return promotionWriteError(err)
+	}
+
+	return nil
+}
+
+// Get retrieves a specific promotion by id.
+func (m PromotionModel) Get(ctx context.Context, id int64) (*Promotion, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + promotionColumns + `
+		FROM promotions
+		WHERE id = $1
+		`
+
+	var promotion Promotion
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanPromotion(m.DB.QueryRowContext(ctx, query, id), &promotion)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &promotion, nil
+}
+
+// GetAll returns a page of promotions along with the total number of promotions. With activeAt
+// set, only the promotions running at that time are returned.
+func (m PromotionModel) GetAll(ctx context.Context, activeAt *time.Time, filters Filters) ([]*Promotion, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), `+promotionColumns+`
+		FROM promotions
+		WHERE ($1::timestamptz IS NULL OR (starts_at <= $1 AND ends_at > $1))
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3
+		`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, activeAt, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	promotions := []*Promotion{}
+
+	for rows.Next() {
+		var promotion Promotion
+
+		err := rows.Scan(
+			&totalRecords,
+			&promotion.ID,
+			&promotion.Name,
+			&promotion.PercentOff,
+			&promotion.AmountOff,
+			&promotion.GunID,
+			&promotion.CategoryID,
+			&promotion.ManufacturerID,
+			&promotion.MinQuantity,
+			&promotion.Stackable,
+			&promotion.StartsAt,
+			&promotion.EndsAt,
+			&promotion.CreatedAt,
+			&promotion.UpdatedAt,
+			&promotion.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		promotions = append(promotions, &promotion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return promotions, totalRecords, nil
+}
+
+// GetActive returns every promotion running at the provided time.
+func (m PromotionModel) GetActive(ctx context.Context, at time.Time) ([]*Promotion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return activePromotions(ctx, m.DB, at)
+}
+
+// activePromotions returns every promotion running at the provided time, in id order.
+func activePromotions(ctx context.Context, q queryer, at time.Time) ([]*Promotion, error) {
+	query := `
+		SELECT ` + promotionColumns + `
+		FROM promotions
+		WHERE starts_at <= $1 AND ends_at > $1
+		ORDER BY id
+		`
+
+	rows, err := q.QueryContext(ctx, query, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promotions []*Promotion
+
+	for rows.Next() {
+		var promotion Promotion
+
+		err := scanPromotion(rows, &promotion)
+		if err != nil {
+			return nil, err
+		}
+
+		promotions = append(promotions, &promotion)
+	}
+
+	return promotions, rows.Err()
+}
+
+// Update updates a specific promotion, checking against the version field to prevent lost
+// updates.
+func (m PromotionModel) Update(ctx context.Context, promotion *Promotion) error {
+	query := `
+		UPDATE promotions
+		SET name = $1, percent_off = $2, amount_off = $3, gun_id = $4, category_id = $5,
+			manufacturer_id = $6, min_quantity = $7, stackable = $8, starts_at = $9, ends_at = $10,
+			updated_at = NOW(), version = version + 1
+		WHERE id = $11 AND version = $12
+		RETURNING updated_at, version
+		`
+
+	args := []interface{}{
+		promotion.Name,
+		promotion.PercentOff,
+		promotion.AmountOff,
+		promotion.GunID,
+		promotion.CategoryID,
+		promotion.ManufacturerID,
+		promotion.MinQuantity,
+		promotion.Stackable,
+		promotion.StartsAt,
+		promotion.EndsAt,
+		promotion.ID,
+		promotion.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&promotion.UpdatedAt, &promotion.Version)
+	if err != nil {
+		return promotionWriteError(err)
+	}
+
+	return nil
+}
+
+// Delete removes a specific promotion.
+func (m PromotionModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM promotions
+		WHERE id = $1
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Quote prices a basket with the promotions running at the provided time. Unknown guns are
+// reported as an ErrRecordNotFound error wrapped in an *ItemError.
+func (m PromotionModel) Quote(ctx context.Context, items []QuoteItem, at time.Time) (*Quote, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	gunIDs := make([]int64, len(items))
+	for i, item := range items {
+		gunIDs[i] = item.GunID
+	}
+
+	guns, err := pricedGuns(ctx, m.DB, gunIDs, false)
+	if err != nil {
+		return nil, err
+	}
+
+	promotions, err := activePromotions(ctx, m.DB, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return priceBasket(items, guns, promotions, at)
+}
+
+// pricedGuns returns the price and the promotion targets of the provided guns, by id. Guns in
+// the trash are left out. With lock set the rows are locked for update, in id order to avoid
+// deadlocks between concurrent transactions.
+func pricedGuns(ctx context.Context, q queryer, gunIDs []int64, lock bool) (map[int64]*Gun, error) {
+	query := `
+		SELECT id, price, category_id, manufacturer_id
+		FROM guns
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		ORDER BY id
+		`
+	if lock {
+		query += "FOR UPDATE"
+	}
+
+	rows, err := q.QueryContext(ctx, query, pq.Array(gunIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guns := make(map[int64]*Gun)
+
+	for rows.Next() {
+		var gun Gun
+
+		err := rows.Scan(&gun.ID, &gun.Price, &gun.CategoryID, &gun.ManufacturerID)
+		if err != nil {
+			return nil, err
+		}
+
+		guns[gun.ID] = &gun
+	}
+
+	return guns, rows.Err()
+}
+
+// priceBasket prices the items of a basket. Each promotion with a MinQuantity is checked against
+// the total quantity of its targeted guns across the whole basket.
+func priceBasket(items []QuoteItem, guns map[int64]*Gun, promotions []*Promotion, at time.Time) (*Quote, error) {
+	quantities := make(map[int64]int, len(promotions))
+
+	for i, item := range items {
+		gun, ok := guns[item.GunID]
+		if !ok {
+			return nil, &ItemError{Index: i, Err: ErrRecordNotFound}
+		}
+
+		for _, promotion := range promotions {
+			if promotion.targets(gun) {
+				quantities[promotion.ID] += item.Quantity
+			}
+		}
+	}
+
+	quote := &Quote{
+		Lines:    []*QuoteLine{},
+		Subtotal: NewMoney(0),
+		Discount: NewMoney(0),
+		Total:    NewMoney(0),
+		QuotedAt: at,
+	}
+
+	for _, item := range items {
+		gun := guns[item.GunID]
+
+		var applicable []*Promotion
+		for _, promotion := range promotions {
+			if promotion.targets(gun) && quantities[promotion.ID] >= promotion.MinQuantity {
+				applicable = append(applicable, promotion)
+			}
+		}
+
+		unitPrice, promotionIDs := bestPrice(gun.Price, applicable)
+
+		line := &QuoteLine{
+			GunID:        item.GunID,
+			Quantity:     item.Quantity,
+			ListPrice:    gun.Price,
+			UnitPrice:    unitPrice,
+			Total:        unitPrice.Mul(int64(item.Quantity)),
+			PromotionIDs: promotionIDs,
+		}
+		line.Discount = gun.Price.Mul(int64(item.Quantity)).Sub(line.Total)
+
+		quote.Lines = append(quote.Lines, line)
+		quote.Subtotal = quote.Subtotal.Add(gun.Price.Mul(int64(item.Quantity)))
+		quote.Discount = quote.Discount.Add(line.Discount)
+		quote.Total = quote.Total.Add(line.Total)
+	}
+
+	return quote, nil
+}
+
+// CurrentPrice returns the price of a single unit of a gun with the provided promotions, which
+// should be the ones running now. Bundle promotions don't apply to a single unit.
+func CurrentPrice(gun *Gun, promotions []*Promotion) Money {
+	var applicable []*Promotion
+	for _, promotion := range promotions {
+		if promotion.targets(gun) && promotion.MinQuantity <= 1 {
+			applicable = append(applicable, promotion)
+		}
+	}
+
+	price, _ := bestPrice(gun.Price, applicable)
+	return price
+}
+
+// bestPrice returns the lowest unit price that the applicable promotions give, along with the
+// ids of the promotions that give it. Non-stackable promotions only ever apply on their own,
+// while the stackable ones apply together: percentages first, then fixed amounts.
+func bestPrice(listPrice Money, promotions []*Promotion) (Money, []int64) {
+	best, bestIDs := listPrice, []int64{}
+
+	var stackable []*Promotion
+
+	for _, promotion := range promotions {
+		if promotion.Stackable {
+			stackable = append(stackable, promotion)
+			continue
+		}
+
+		if price := promotion.apply(listPrice); price.Cmp(best) < 0 {
+			best, bestIDs = price, []int64{promotion.ID}
+		}
+	}
+
+	if len(stackable) > 0 {
+		sort.SliceStable(stackable, func(i, j int) bool {
+			return stackable[i].PercentOff != nil && stackable[j].PercentOff == nil
+		})
+
+		price := listPrice
+		ids := []int64{}
+
+		for _, promotion := range stackable {
+			price = promotion.apply(price)
+			ids = append(ids, promotion.ID)
+		}
+
+		if price.Cmp(best) < 0 {
+			best, bestIDs = price, ids
+		}
+	}
+
+	return best, bestIDs
+}
+
+// targets reports whether the promotion applies to the gun.
+func (p *Promotion) targets(gun *Gun) bool {
+	if p.GunID != nil && *p.GunID != gun.ID {
+		return false
+	}
+	if p.CategoryID != nil && (gun.CategoryID == nil || *p.CategoryID != *gun.CategoryID) {
+		return false
+	}
+	if p.ManufacturerID != nil && (gun.ManufacturerID == nil || *p.ManufacturerID != *gun.ManufacturerID) {
+		return false
+	}
+	return true
+}
+
+// apply returns the unit price after the promotion's discount, which never goes below zero.
+func (p *Promotion) apply(price Money) Money {
+	var discounted Money
+
+	switch {
+	case p.PercentOff != nil:
+		discounted = price.Sub(price.MulRatio(int64(*p.PercentOff), 100))
+	case p.AmountOff != nil:
+		discounted = price.Sub(*p.AmountOff)
+	default:
+		return price
+	}
+
+	if discounted.IsNegative() {
+		return NewMoney(0)
+	}
+	return discounted
+}
+
+// ValidatePromotion checks the fields of a promotion.
+func ValidatePromotion(v *validator.Validator, promotion *Promotion) {
+	v.Check(promotion.Name != "", "name", "must be provided")
+	v.Check(len(promotion.Name) <= 200, "name", "must not be more than 200 bytes long")
+
+	v.Check(promotion.PercentOff != nil || promotion.AmountOff != nil, "percent_off", "either percent_off or amount_off must be provided")
+	v.Check(promotion.PercentOff == nil || promotion.AmountOff == nil, "percent_off", "must not be provided together with amount_off")
+
+	if promotion.PercentOff != nil {
+		v.Check(*promotion.PercentOff > 0 && *promotion.PercentOff <= 100, "percent_off", "must be between 1 and 100")
+	}
+	if promotion.AmountOff != nil {
+		v.Check(promotion.AmountOff.Amount > 0, "amount_off", "must be greater than zero")
+		v.Check(promotion.AmountOff.InCurrency(DefaultCurrency), "amount_off", "must be in "+DefaultCurrency)
+	}
+
+	if promotion.GunID != nil {
+		v.Check(*promotion.GunID > 0, "gun_id", "must be a valid gun id")
+	}
+	if promotion.CategoryID != nil {
+		v.Check(*promotion.CategoryID > 0, "category_id", "must be a valid category id")
+	}
+	if promotion.ManufacturerID != nil {
+		v.Check(*promotion.ManufacturerID > 0, "manufacturer_id", "must be a valid manufacturer id")
+	}
+
+	v.Check(promotion.MinQuantity >= 1, "min_quantity", "must be at least 1")
+
+	v.Check(!promotion.StartsAt.IsZero(), "starts_at", "must be provided")
+	v.Check(!promotion.EndsAt.IsZero(), "ends_at", "must be provided")
+	v.Check(promotion.EndsAt.After(promotion.StartsAt), "ends_at", "must be after starts_at")
+}
+
+// ValidateQuoteItems checks the items of a basket to be quoted.
+func ValidateQuoteItems(v *validator.Validator, items []QuoteItem) {
+	v.Check(len(items) > 0, "items", "must contain at least one item")
+	v.Check(len(items) <= 100, "items", "must not contain more than 100 items")
+
+	for i, item := range items {
+		key := fmt.Sprintf("items[%d]", i)
+
+		v.Check(item.GunID > 0, key+".gun_id", "must be provided")
+		v.Check(item.Quantity > 0, key+".quantity", "must be greater than zero")
+		v.Check(item.Quantity <= 10000, key+".quantity", "must not be more than 10000")
+	}
+}
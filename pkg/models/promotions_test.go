@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+func TestValidateQuoteItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int
+		valid    bool
+	}{
+		{name: "one", quantity: 1, valid: true},
+		{name: "at the limit", quantity: 10000, valid: true},
+		{name: "zero", quantity: 0},
+		{name: "negative", quantity: -1},
+		{name: "over the limit", quantity: 10001},
+		{name: "large enough to overflow a total", quantity: 1 << 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateQuoteItems(v, []QuoteItem{{GunID: 1, Quantity: tt.quantity}})
+
+			if v.Valid() != tt.valid {
+				t.Errorf("valid = %t, want %t: %v", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,710 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPurchaseOrderNotDraft = errors.New("purchase order is not a draft")
+	ErrPurchaseOrderNotOpen  = errors.New("purchase order is not open for receiving")
+	ErrOverReceipt           = errors.New("quantity received exceeds quantity ordered")
+)
+
+// The statuses of a purchase order. A draft can be edited freely; once sent to the supplier its
+// lines are fixed and stock is received against them until every line is complete.
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderSent              = "sent"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+	PurchaseOrderCancelled         = "cancelled"
+)
+
+// purchaseOrderTransitions lists the statuses that a purchase order may move to from each
+// status.
+var purchaseOrderTransitions = map[string][]string{
+	PurchaseOrderDraft:             {PurchaseOrderSent, PurchaseOrderCancelled},
+	PurchaseOrderSent:              {PurchaseOrderPartiallyReceived, PurchaseOrderReceived, PurchaseOrderCancelled},
+	PurchaseOrderPartiallyReceived: {PurchaseOrderReceived, PurchaseOrderCancelled},
+	PurchaseOrderReceived:          {},
+	PurchaseOrderCancelled:         {},
+}
+
+// manualPurchaseOrderStatuses are the statuses that can be set directly through UpdateStatus.
+// Orders only become (partially) received by receiving stock against them.
+var manualPurchaseOrderStatuses = []string{PurchaseOrderSent, PurchaseOrderCancelled}
+
+type (
+	// PurchaseOrder represents an order of stock from a supplier. Total is the cost of the
+	// ordered quantities and is calculated from the lines.
+	PurchaseOrder struct {
+		ID         int64                `json:"id"`
+		SupplierID int64                `json:"supplier_id"`
+		Status     string               `json:"status"`
+		Note       string               `json:"note"`
+		UserID     *int64               `json:"user_id"`
+		Lines      []*PurchaseOrderLine `json:"lines"`
+		Total      Money                `json:"total"`
+		CreatedAt  time.Time            `json:"created_at"`
+		UpdatedAt  time.Time            `json:"updated_at"`
+		SentAt     *time.Time           `json:"sent_at"`
+		ClosedAt   *time.Time           `json:"closed_at"`
+		Version    int                  `json:"version"`
+	}
+
+	// PurchaseOrderLine is the quantity of a single gun ordered, at the cost price agreed with
+	// the supplier, and how much of it has been received so far.
+	PurchaseOrderLine struct {
+		ID               int64 `json:"id"`
+		PurchaseOrderID  int64 `json:"-"`
+		GunID            int64 `json:"gun_id"`
+		QuantityOrdered  int   `json:"quantity_ordered"`
+		QuantityReceived int   `json:"quantity_received"`
+		UnitCost         Money `json:"unit_cost"`
+	}
+
+	// PurchaseOrderFilters narrows down a list of purchase orders. Zero values don't filter.
+	PurchaseOrderFilters struct {
+		Status     string
+		SupplierID int64
+	}
+
+	// Receipt is a delivery of stock against a purchase order. Serialized firearms are received
+	// by listing their units, one per item received.
+	Receipt struct {
+		Lines []*ReceiptLine `json:"lines"`
+		Note  string         `json:"note"`
+	}
+
+	// ReceiptLine is the quantity of a purchase order line received in a delivery.
+	ReceiptLine struct {
+		LineID   int64      `json:"line_id"`
+		Quantity int        `json:"quantity"`
+		Units    []*GunUnit `json:"units,omitempty"`
+	}
+
+	// PurchaseOrderModel struct wraps a sql.DB connection pool and allows us to work with the
+	// purchase_orders and purchase_order_lines tables.
+	PurchaseOrderModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+// canTransitionPurchaseOrder reports whether a purchase order may move from one status to
+// another.
+func canTransitionPurchaseOrder(from, to string) bool {
+	return validator.In(to, purchaseOrderTransitions[from]...)
+}
+
+const purchaseOrderColumns = `id, supplier_id, status, note, user_id, created_at, updated_at,
+	sent_at, closed_at, version`
+
+func scanPurchaseOrder(row rowScanner, order *PurchaseOrder) error {
+	return row.Scan(
+		&order.ID,
+		&order.SupplierID,
+		&order.Status,
+		&order.Note,
+		&order.UserID,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+		&order.SentAt,
+		&order.ClosedAt,
+		&order.Version,
+	)
+}
+
+// Insert creates a draft purchase order with its lines in a single transaction. An unknown
+// supplier results in an ErrSupplierNotFound error, and an unknown gun in an *ItemError
+// wrapping ErrRecordNotFound.
+func (m PurchaseOrderModel) Insert(ctx context.Context, order *PurchaseOrder) error {
+	query := `
+		INSERT INTO purchase_orders (supplier_id, note, user_id)
+		VALUES ($1, $2, $3)
+		RETURNING ` + purchaseOrderColumns
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = scanPurchaseOrder(tx.QueryRowContext(ctx, query, order.SupplierID, order.Note, order.UserID), order)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrSupplierNotFound
+		default:
+			return err
+		}
+	}
+
+	err = insertPurchaseOrderLines(ctx, tx, order)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertPurchaseOrderLines writes the lines of a purchase order and calculates its total.
+// Deleted guns can't be ordered.
+func insertPurchaseOrderLines(ctx context.Context, tx *sql.Tx, order *PurchaseOrder) error {
+	query := `
+		INSERT INTO purchase_order_lines (purchase_order_id, gun_id, quantity_ordered, unit_cost)
+		SELECT $1, id, $3, $4
+		FROM guns
+		WHERE id = $2 AND deleted_at IS NULL
+		RETURNING id, quantity_received
+		`
+
+	order.Total = NewMoney(0)
+
+	for i, line := range order.Lines {
+		line.PurchaseOrderID = order.ID
+
+		args := []interface{}{order.ID, line.GunID, line.QuantityOrdered, line.UnitCost}
+
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&line.ID, &line.QuantityReceived)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return &ItemError{Index: i, Err: ErrRecordNotFound}
+			default:
+				return err
+			}
+		}
+
+		order.Total = order.Total.Add(line.UnitCost.Mul(int64(line.QuantityOrdered)))
+	}
+
+	return nil
+}
+
+// Get retrieves a specific purchase order, including its lines.
+func (m PurchaseOrderModel) Get(ctx context.Context, id int64) (*PurchaseOrder, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + purchaseOrderColumns + `
+		FROM purchase_orders
+		WHERE id = $1
+		`
+
+	var order PurchaseOrder
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanPurchaseOrder(m.DB.QueryRowContext(ctx, query, id), &order)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = loadPurchaseOrderLines(ctx, m.DB, []*PurchaseOrder{&order})
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// GetAll returns a page of purchase orders, including their lines, along with the total number
+// of matching purchase orders.
+func (m PurchaseOrderModel) GetAll(ctx context.Context, orderFilters PurchaseOrderFilters, filters Filters) ([]*PurchaseOrder, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM purchase_orders
+		WHERE ($1 = '' OR status = $1)
+		AND ($2 = 0 OR supplier_id = $2)
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4
+		`, purchaseOrderColumns, filters.sortColumn(), filters.sortDirection())
+
+	args := []interface{}{orderFilters.Status, orderFilters.SupplierID, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	orders := []*PurchaseOrder{}
+
+	for rows.Next() {
+		var order PurchaseOrder
+
+		err := rows.Scan(
+			&totalRecords,
+			&order.ID,
+			&order.SupplierID,
+			&order.Status,
+			&order.Note,
+			&order.UserID,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+			&order.SentAt,
+			&order.ClosedAt,
+			&order.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		orders = append(orders, &order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	err = loadPurchaseOrderLines(ctx, m.DB, orders)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return orders, totalRecords, nil
+}
+
+// loadPurchaseOrderLines fetches the lines of the provided purchase orders with a single query,
+// and calculates their totals.
+func loadPurchaseOrderLines(ctx context.Context, q queryer, orders []*PurchaseOrder) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*PurchaseOrder, len(orders))
+	ids := make([]int64, len(orders))
+
+	for i, order := range orders {
+		order.Lines = []*PurchaseOrderLine{}
+		order.Total = NewMoney(0)
+		byID[order.ID] = order
+		ids[i] = order.ID
+	}
+
+	query := `
+		SELECT id, purchase_order_id, gun_id, quantity_ordered, quantity_received, unit_cost
+		FROM purchase_order_lines
+		WHERE purchase_order_id = ANY($1)
+		ORDER BY id
+		`
+
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line PurchaseOrderLine
+
+		err := rows.Scan(
+			&line.ID,
+			&line.PurchaseOrderID,
+			&line.GunID,
+			&line.QuantityOrdered,
+			&line.QuantityReceived,
+			&line.UnitCost,
+		)
+		if err != nil {
+			return err
+		}
+
+		order := byID[line.PurchaseOrderID]
+		order.Lines = append(order.Lines, &line)
+		order.Total = order.Total.Add(line.UnitCost.Mul(int64(line.QuantityOrdered)))
+	}
+
+	return rows.Err()
+}
+
+// Update replaces the supplier, note and lines of a draft purchase order, checking against the
+// version field to prevent lost updates. Orders that have been sent can't be edited, which also
+// results in an ErrEditConflict error if the order was sent in the meantime.
+func (m PurchaseOrderModel) Update(ctx context.Context, order *PurchaseOrder) error {
+	query := `
+		UPDATE purchase_orders
+		SET supplier_id = $1, note = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4 AND status = 'draft'
+		RETURNING updated_at, version
+		`
+
+	args := []interface{}{order.SupplierID, order.Note, order.ID, order.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&order.UpdatedAt, &order.Version)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrSupplierNotFound
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM purchase_order_lines WHERE purchase_order_id = $1`, order.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertPurchaseOrderLines(ctx, tx, order)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockPurchaseOrder reads a purchase order and its lines, locking the order until the end of
+// the transaction.
+func lockPurchaseOrder(ctx context.Context, tx *sql.Tx, id int64) (*PurchaseOrder, error) {
+	query := `
+		SELECT ` + purchaseOrderColumns + `
+		FROM purchase_orders
+		WHERE id = $1
+		FOR UPDATE
+		`
+
+	var order PurchaseOrder
+
+	err := scanPurchaseOrder(tx.QueryRowContext(ctx, query, id), &order)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = loadPurchaseOrderLines(ctx, tx, []*PurchaseOrder{&order})
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// UpdateStatus sends a draft purchase order to its supplier, or cancels an order. The status
+// must be a valid transition from the current one, otherwise an ErrInvalidTransition error is
+// returned. Stock already received against a cancelled order stays in stock.
+func (m PurchaseOrderModel) UpdateStatus(ctx context.Context, id int64, status string) (*PurchaseOrder, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	if !validator.In(status, manualPurchaseOrderStatuses...) {
+		return nil, ErrInvalidTransition
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := lockPurchaseOrder(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setPurchaseOrderStatus(ctx, tx, order, status)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// setPurchaseOrderStatus moves a locked purchase order to a new status, stamping the time it was
+// sent or closed. It returns an ErrInvalidTransition error if the order can't move to that
+// status.
+func setPurchaseOrderStatus(ctx context.Context, tx *sql.Tx, order *PurchaseOrder, status string) error {
+	if !canTransitionPurchaseOrder(order.Status, status) {
+		return ErrInvalidTransition
+	}
+
+	query := `
+		UPDATE purchase_orders
+		SET status = $1,
+			sent_at = CASE WHEN $1 = 'sent' THEN NOW() ELSE sent_at END,
+			closed_at = CASE WHEN $1 IN ('received', 'cancelled') THEN NOW() ELSE closed_at END,
+			updated_at = NOW(), version = version + 1
+		WHERE id = $2
+		RETURNING sent_at, closed_at, updated_at, version
+		`
+
+	err := tx.QueryRowContext(ctx, query, status, order.ID).Scan(
+		&order.SentAt,
+		&order.ClosedAt,
+		&order.UpdatedAt,
+		&order.Version,
+	)
+	if err != nil {
+		return err
+	}
+
+	order.Status = status
+
+	return nil
+}
+
+// Receive books a delivery against a sent purchase order in a single transaction. Each receipt
+// line adds stock at the cost price of its order line: serialized firearms are received unit by
+// unit, everything else as a single stock movement. The order then becomes partially received,
+// or received once every line is complete. Orders that aren't open for receiving result in an
+// ErrPurchaseOrderNotOpen error. Errors caused by a specific receipt line, such as an unknown
+// order line (ErrRecordNotFound), more than is outstanding (ErrOverReceipt), a serialized gun
+// received without its units (ErrUnitRequired) or a serial number already on file
+// (ErrDuplicateSerial), are wrapped in an *ItemError. The received units are returned.
+func (m PurchaseOrderModel) Receive(ctx context.Context, id int64, receipt *Receipt, userID *int64) (*PurchaseOrder, []*GunUnit, error) {
+	if id < 1 {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := lockPurchaseOrder(ctx, tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !validator.In(order.Status, PurchaseOrderSent, PurchaseOrderPartiallyReceived) {
+		return nil, nil, ErrPurchaseOrderNotOpen
+	}
+
+	lines := make(map[int64]*PurchaseOrderLine, len(order.Lines))
+	gunIDs := make([]int64, len(order.Lines))
+
+	for i, line := range order.Lines {
+		lines[line.ID] = line
+		gunIDs[i] = line.GunID
+	}
+
+	serialized, err := serializedGuns(ctx, tx, gunIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	note := receipt.Note
+	if note == "" {
+		note = fmt.Sprintf("received on purchase order %d", order.ID)
+	}
+
+	units := []*GunUnit{}
+
+	for i, item := range receipt.Lines {
+		line, ok := lines[item.LineID]
+		switch {
+		case !ok:
+			return nil, nil, &ItemError{Index: i, Err: ErrRecordNotFound}
+		case line.QuantityReceived+item.Quantity > line.QuantityOrdered:
+			return nil, nil, &ItemError{Index: i, Err: ErrOverReceipt}
+		case len(item.Units) == 0 && serialized[line.GunID]:
+			return nil, nil, &ItemError{Index: i, Err: ErrUnitRequired}
+		}
+
+		for _, unit := range item.Units {
+			unitCost := line.UnitCost
+
+			unit.GunID = line.GunID
+			unit.PurchaseOrderID = &order.ID
+			unit.UnitCost = &unitCost
+
+			err = receiveUnit(ctx, tx, unit, nil, userID, note)
+			if err != nil {
+				switch {
+				case errors.Is(err, ErrDuplicateSerial):
+					return nil, nil, &ItemError{Index: i, Err: err}
+				default:
+					return nil, nil, err
+				}
+			}
+
+			units = append(units, unit)
+		}
+
+		if len(item.Units) == 0 {
+			unitCost := line.UnitCost
+
+			movement := &StockMovement{
+				GunID:           line.GunID,
+				Kind:            MovementReceive,
+				Quantity:        item.Quantity,
+				Reason:          note,
+				UserID:          userID,
+				PurchaseOrderID: &order.ID,
+				UnitCost:        &unitCost,
+			}
+
+			err = insertStockMovement(ctx, tx, movement)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		query := `
+			UPDATE purchase_order_lines
+			SET quantity_received = quantity_received + $1
+			WHERE id = $2
+			RETURNING quantity_received
+			`
+
+		err = tx.QueryRowContext(ctx, query, item.Quantity, line.ID).Scan(&line.QuantityReceived)
+		if err != nil {
+			switch {
+			case violatesConstraint(err, "purchase_order_lines_quantity_received_check"):
+				return nil, nil, &ItemError{Index: i, Err: ErrOverReceipt}
+			default:
+				return nil, nil, err
+			}
+		}
+	}
+
+	status := PurchaseOrderReceived
+	for _, line := range order.Lines {
+		if line.QuantityReceived < line.QuantityOrdered {
+			status = PurchaseOrderPartiallyReceived
+			break
+		}
+	}
+
+	if status != order.Status {
+		err = setPurchaseOrderStatus(ctx, tx, order, status)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, units, nil
+}
+
+// ValidatePurchaseOrder checks the supplier, note and lines of a purchase order. Each gun may
+// only be ordered on one line.
+func ValidatePurchaseOrder(v *validator.Validator, order *PurchaseOrder) {
+	v.Check(order.SupplierID > 0, "supplier_id", "must be provided")
+	v.Check(len(order.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+
+	v.Check(len(order.Lines) > 0, "lines", "must contain at least one line")
+	v.Check(len(order.Lines) <= 100, "lines", "must not contain more than 100 lines")
+
+	seen := make(map[int64]bool, len(order.Lines))
+
+	for i, line := range order.Lines {
+		key := fmt.Sprintf("lines[%d]", i)
+
+		v.Check(line.GunID > 0, key+".gun_id", "must be provided")
+		v.Check(!seen[line.GunID], key+".gun_id", "must not be ordered on more than one line")
+		v.Check(line.QuantityOrdered > 0, key+".quantity_ordered", "must be greater than zero")
+		v.Check(line.QuantityOrdered <= 10000, key+".quantity_ordered", "must not be more than 10000")
+		v.Check(!line.UnitCost.IsNegative(), key+".unit_cost", "must not be negative")
+		v.Check(line.UnitCost.InCurrency(DefaultCurrency), key+".unit_cost", "must be in "+DefaultCurrency)
+
+		seen[line.GunID] = true
+	}
+}
+
+// ValidatePurchaseOrderStatus checks a status requested through UpdateStatus.
+func ValidatePurchaseOrderStatus(v *validator.Validator, status string) {
+	v.Check(validator.In(status, manualPurchaseOrderStatuses...), "status",
+		"must be one of "+strings.Join(manualPurchaseOrderStatuses, ", "))
+}
+
+// ValidatePurchaseOrderFilters checks the filters of a purchase order listing.
+func ValidatePurchaseOrderFilters(v *validator.Validator, f PurchaseOrderFilters) {
+	if f.Status != "" {
+		v.Check(validator.In(f.Status, PurchaseOrderDraft, PurchaseOrderSent, PurchaseOrderPartiallyReceived,
+			PurchaseOrderReceived, PurchaseOrderCancelled),
+			"status", "must be draft, sent, partially_received, received or cancelled")
+	}
+}
+
+// ValidateReceipt checks the lines of a delivery. A line that lists units must list exactly one
+// unit per item received.
+func ValidateReceipt(v *validator.Validator, receipt *Receipt) {
+	v.Check(len(receipt.Note) <= 500, "note", "must not be more than 500 bytes long")
+
+	v.Check(len(receipt.Lines) > 0, "lines", "must contain at least one line")
+	v.Check(len(receipt.Lines) <= 100, "lines", "must not contain more than 100 lines")
+
+	for i, line := range receipt.Lines {
+		key := fmt.Sprintf("lines[%d]", i)
+
+		v.Check(line.LineID > 0, key+".line_id", "must be provided")
+		v.Check(line.Quantity > 0, key+".quantity", "must be greater than zero")
+
+		if len(line.Units) == 0 {
+			continue
+		}
+
+		v.Check(len(line.Units) == line.Quantity, key+".units", "must list one unit per item received")
+
+		for j, unit := range line.Units {
+			unitKey := fmt.Sprintf("%s.units[%d]", key, j)
+
+			v.Check(unit.Manufacturer != "", unitKey+".manufacturer", "must be provided")
+			v.Check(len(unit.Manufacturer) <= 200, unitKey+".manufacturer", "must not be more than 200 bytes long")
+			v.Check(unit.SerialNumber != "", unitKey+".serial_number", "must be provided")
+			v.Check(len(unit.SerialNumber) <= 100, unitKey+".serial_number", "must not be more than 100 bytes long")
+		}
+	}
+}
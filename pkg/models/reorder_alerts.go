@@ -0,0 +1,330 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+	"github.com/lib/pq"
+)
+
+// The statuses of a reorder alert. An alert stays open while the gun's available stock is below
+// its reorder point, and is resolved once the stock has been brought back up.
+const (
+	ReorderAlertOpen     = "open"
+	ReorderAlertResolved = "resolved"
+)
+
+type (
+	// ReorderAlert is raised when the available stock of a gun drops below its reorder point.
+	// QuantityAvailable, ReorderPoint and ReorderQuantity are as they were when the alert was
+	// raised. NotifiedAt is set once the alert has been sent through the notifier. GunName is
+	// only ever read.
+	ReorderAlert struct {
+		ID                int64      `json:"id"`
+		GunID             int64      `json:"gun_id"`
+		GunName           string     `json:"gun_name"`
+		Status            string     `json:"status"`
+		QuantityAvailable int        `json:"quantity_available"`
+		ReorderPoint      int        `json:"reorder_point"`
+		ReorderQuantity   int        `json:"reorder_quantity"`
+		CreatedAt         time.Time  `json:"created_at"`
+		NotifiedAt        *time.Time `json:"notified_at"`
+		ResolvedAt        *time.Time `json:"resolved_at"`
+	}
+
+	// ReorderAlertFilters narrows down a list of reorder alerts. Zero values don't filter.
+	ReorderAlertFilters struct {
+		Status string
+		GunID  int64
+	}
+
+	// ReorderAlertModel struct wraps a sql.DB connection pool and allows us to work with the
+	// reorder_alerts table.
+	ReorderAlertModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+const reorderAlertColumns = `id, gun_id, (SELECT name FROM guns WHERE guns.id = reorder_alerts.gun_id),
+	status, quantity_available, reorder_point, reorder_quantity, created_at, notified_at, resolved_at`
+
+func scanReorderAlert(row rowScanner, alert *ReorderAlert) error {
+	return row.Scan(
+		&alert.ID,
+		&alert.GunID,
+		&alert.GunName,
+		&alert.Status,
+		&alert.QuantityAvailable,
+		&alert.ReorderPoint,
+		&alert.ReorderQuantity,
+		&alert.CreatedAt,
+		&alert.NotifiedAt,
+		&alert.ResolvedAt,
+	)
+}
+
+// Check compares the available stock of every gun with its reorder point. It resolves the open
+// alerts of guns that are back at or above their reorder point, or that are no longer reordered,
+// and raises an alert for each gun below its reorder point that doesn't have an open one yet. It
+// returns the number of alerts raised and resolved.
+func (m ReorderAlertModel) Check(ctx context.Context) (int, int, error) {
+	resolveQuery := `
+		UPDATE reorder_alerts
+		SET status = 'resolved', resolved_at = NOW()
+		FROM guns
+		WHERE guns.id = reorder_alerts.gun_id AND reorder_alerts.status = 'open'
+		AND (guns.deleted_at IS NOT NULL OR guns.reorder_point IS NULL
+			OR guns.quantity_on_hand - guns.quantity_reserved >= guns.reorder_point)
+		`
+
+	raiseQuery := `
+		INSERT INTO reorder_alerts (gun_id, quantity_available, reorder_point, reorder_quantity)
+		SELECT id, quantity_on_hand - quantity_reserved, reorder_point, reorder_quantity
+		FROM guns
+		WHERE deleted_at IS NULL AND reorder_point IS NOT NULL
+		AND quantity_on_hand - quantity_reserved < reorder_point
+		ON CONFLICT (gun_id) WHERE status = 'open' DO NOTHING
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, resolveQuery)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	resolved, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	result, err = tx.ExecContext(ctx, raiseQuery)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	raised, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(raised), int(resolved), nil
+}
+
+// GetUnnotified returns the open alerts that haven't been sent through the notifier yet, oldest
+// first.
+func (m ReorderAlertModel) GetUnnotified(ctx context.Context) ([]*ReorderAlert, error) {
+	query := `
+		SELECT ` + reorderAlertColumns + `
+		FROM reorder_alerts
+		WHERE status = 'open' AND notified_at IS NULL
+		ORDER BY id
+		LIMIT 500
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	alerts := []*ReorderAlert{}
+
+	for rows.Next() {
+		var alert ReorderAlert
+
+		err := scanReorderAlert(rows, &alert)
+		if err != nil {
+			return nil, err
+		}
+
+		alerts = append(alerts, &alert)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+// MarkNotified records that the alerts with the provided ids have been sent.
+func (m ReorderAlertModel) MarkNotified(ctx context.Context, ids []int64) error {
+	query := `
+		UPDATE reorder_alerts
+		SET notified_at = NOW()
+		WHERE id = ANY($1) AND notified_at IS NULL
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(ids))
+	return err
+}
+
+// GetAll returns a page of reorder alerts, optionally limited to a status or a gun, along with
+// the total number of matching alerts.
+func (m ReorderAlertModel) GetAll(ctx context.Context, alertFilters ReorderAlertFilters, filters Filters) ([]*ReorderAlert, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM reorder_alerts
+		WHERE ($1 = '' OR status = $1)
+		AND ($2 = 0 OR gun_id = $2)
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4
+		`, reorderAlertColumns, filters.sortColumn(), filters.sortDirection())
+
+	args := []interface{}{alertFilters.Status, alertFilters.GunID, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	alerts := []*ReorderAlert{}
+
+	for rows.Next() {
+		var alert ReorderAlert
+
+		err := rows.Scan(
+			&totalRecords,
+			&alert.ID,
+			&alert.GunID,
+			&alert.GunName,
+			&alert.Status,
+			&alert.QuantityAvailable,
+			&alert.ReorderPoint,
+			&alert.ReorderQuantity,
+			&alert.CreatedAt,
+			&alert.NotifiedAt,
+			&alert.ResolvedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		alerts = append(alerts, &alert)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return alerts, totalRecords, nil
+}
+
+// SuggestPurchaseOrder builds a draft purchase order from a supplier for the guns with open
+// alerts. Each gun is ordered in its reorder quantity, less what is still outstanding on open
+// purchase orders, and guns that are already fully on order are left out. The unit cost is the
+// last one the gun was ordered at, preferably from the same supplier, or zero if it has never
+// been ordered. The order isn't saved; it can be reviewed and then created as is.
+func (m ReorderAlertModel) SuggestPurchaseOrder(ctx context.Context, supplierID int64) (*PurchaseOrder, error) {
+	query := `
+		SELECT guns.id, guns.reorder_quantity - COALESCE(outstanding.quantity, 0),
+			COALESCE(last_cost.unit_cost, 0)
+		FROM reorder_alerts
+		INNER JOIN guns ON guns.id = reorder_alerts.gun_id
+		LEFT JOIN LATERAL (
+			SELECT sum(purchase_order_lines.quantity_ordered - purchase_order_lines.quantity_received) AS quantity
+			FROM purchase_order_lines
+			INNER JOIN purchase_orders ON purchase_orders.id = purchase_order_lines.purchase_order_id
+			WHERE purchase_order_lines.gun_id = guns.id
+			AND purchase_orders.status IN ('draft', 'sent', 'partially_received')
+		) outstanding ON true
+		LEFT JOIN LATERAL (
+			SELECT purchase_order_lines.unit_cost
+			FROM purchase_order_lines
+			INNER JOIN purchase_orders ON purchase_orders.id = purchase_order_lines.purchase_order_id
+			WHERE purchase_order_lines.gun_id = guns.id AND purchase_orders.status <> 'cancelled'
+			ORDER BY purchase_orders.supplier_id = $1 DESC, purchase_orders.created_at DESC
+			LIMIT 1
+		) last_cost ON true
+		WHERE reorder_alerts.status = 'open' AND guns.deleted_at IS NULL
+		AND guns.reorder_quantity > COALESCE(outstanding.quantity, 0)
+		ORDER BY reorder_alerts.id
+		LIMIT 100
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	order := &PurchaseOrder{
+		SupplierID: supplierID,
+		Status:     PurchaseOrderDraft,
+		Note:       "Suggested from reorder alerts",
+		Lines:      []*PurchaseOrderLine{},
+		Total:      NewMoney(0),
+	}
+
+	for rows.Next() {
+		var line PurchaseOrderLine
+
+		err := rows.Scan(&line.GunID, &line.QuantityOrdered, &line.UnitCost)
+		if err != nil {
+			return nil, err
+		}
+
+		order.Lines = append(order.Lines, &line)
+		order.Total = order.Total.Add(line.UnitCost.Mul(int64(line.QuantityOrdered)))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// ValidateReorderAlertFilters checks the filters of a reorder alert listing.
+func ValidateReorderAlertFilters(v *validator.Validator, f ReorderAlertFilters) {
+	if f.Status != "" {
+		v.Check(validator.In(f.Status, ReorderAlertOpen, ReorderAlertResolved), "status", "must be open or resolved")
+	}
+
+	v.Check(f.GunID >= 0, "gun_id", "must be a valid gun id")
+}
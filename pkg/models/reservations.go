@@ -0,0 +1,619 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrReservationNotActive = errors.New("reservation is not active")
+)
+
+// The statuses of a reservation. Only active reservations hold stock; the others are final.
+const (
+	ReservationActive    = "active"
+	ReservationExpired   = "expired"
+	ReservationCancelled = "cancelled"
+	ReservationConverted = "converted"
+)
+
+// MaxReservationPeriod is the longest that stock can be held for a customer.
+const MaxReservationPeriod = 30 * 24 * time.Hour
+
+type (
+	// Reservation holds stock of a gun for a customer until ExpiresAt. A serialized firearm is
+	// held by UnitID, one unit per reservation, and the unit is reserved for as long as the
+	// reservation is active. Held stock counts towards guns.quantity_reserved and can't be sold
+	// to anybody else.
+	Reservation struct {
+		ID         int64     `json:"id"`
+		CustomerID int64     `json:"customer_id"`
+		GunID      int64     `json:"gun_id"`
+		UnitID     *int64    `json:"unit_id,omitempty"`
+		Quantity   int       `json:"quantity"`
+		Status     string    `json:"status"`
+		Note       string    `json:"note"`
+		ExpiresAt  time.Time `json:"expires_at"`
+		UserID     *int64    `json:"user_id"`
+		SaleID     *int64    `json:"sale_id,omitempty"`
+		CreatedAt  time.Time `json:"created_at"`
+		UpdatedAt  time.Time `json:"updated_at"`
+		Version    int       `json:"version"`
+	}
+
+	// ReservationFilters narrows down a listing of reservations. Zero values don't filter.
+	ReservationFilters struct {
+		Status     string
+		CustomerID int64
+		GunID      int64
+	}
+
+	// ReservationModel struct wraps a sql.DB connection pool and allows us to work with the
+	// reservations table.
+	ReservationModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+const reservationColumns = `id, customer_id, gun_id, unit_id, quantity, status, note, expires_at,
+	user_id, sale_id, created_at, updated_at, version`
+
+func scanReservation(row rowScanner, reservation *Reservation) error {
+	return row.Scan(
+		&reservation.ID,
+		&reservation.CustomerID,
+		&reservation.GunID,
+		&reservation.UnitID,
+		&reservation.Quantity,
+		&reservation.Status,
+		&reservation.Note,
+		&reservation.ExpiresAt,
+		&reservation.UserID,
+		&reservation.SaleID,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt,
+		&reservation.Version,
+	)
+}
+
+// Insert places a hold in a single transaction: the reservation is written, the held quantity
+// is added to the gun's reserved stock, and a held unit is moved to the reserved status. It
+// returns an ErrCustomerNotFound error for an unknown customer, ErrRecordNotFound for an
+// unknown gun, ErrUnitNotFound or ErrUnitUnavailable for a unit that can't be held,
+// ErrUnitRequired for a serialized gun held without naming its unit, and ErrInsufficientStock if
+// there isn't enough unreserved stock.
+func (m ReservationModel) Insert(ctx context.Context, reservation *Reservation) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Units are locked before guns, in the same order as sales do, so the two can't deadlock.
+	var unit *GunUnit
+
+	if reservation.UnitID != nil {
+		units, err := lockUnits(ctx, tx, []int64{*reservation.UnitID})
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		unit, ok = units[*reservation.UnitID]
+		switch {
+		case !ok:
+			return ErrUnitNotFound
+		case unit.Status != UnitInStock:
+			return ErrUnitUnavailable
+		}
+
+		reservation.GunID = unit.GunID
+		reservation.Quantity = 1
+	} else {
+		// Guns with serialized units in stock are held by unit, just like they are sold.
+		serialized, err := serializedGuns(ctx, tx, []int64{reservation.GunID})
+		if err != nil {
+			return err
+		}
+
+		if serialized[reservation.GunID] {
+			return ErrUnitRequired
+		}
+	}
+
+	query := `
+		INSERT INTO reservations (customer_id, gun_id, unit_id, quantity, note, expires_at, user_id)
+		SELECT $1, id, $3, $4, $5, $6, $7
+		FROM guns
+		WHERE id = $2 AND deleted_at IS NULL
+		RETURNING id, status, created_at, updated_at, version
+		`
+
+	args := []interface{}{
+		reservation.CustomerID,
+		reservation.GunID,
+		reservation.UnitID,
+		reservation.Quantity,
+		reservation.Note,
+		reservation.ExpiresAt,
+		reservation.UserID,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&reservation.ID,
+		&reservation.Status,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt,
+		&reservation.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		case violatesConstraint(err, "reservations_customer_id_fkey"):
+			return ErrCustomerNotFound
+		default:
+			return err
+		}
+	}
+
+	err = reserveStock(ctx, tx, reservation.GunID, reservation.Quantity)
+	if err != nil {
+		return err
+	}
+
+	if unit != nil {
+		event := &UnitEvent{
+			Status: UnitReserved,
+			Note:   fmt.Sprintf("reservation #%d", reservation.ID),
+			UserID: reservation.UserID,
+		}
+
+		err = setUnitStatus(ctx, tx, unit, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// reserveStock adds quantity, which may be negative to release stock, to the reserved stock of a
+// gun. It returns an ErrInsufficientStock error if that would reserve more than is on hand.
+func reserveStock(ctx context.Context, q queryer, gunID int64, quantity int) error {
+	query := `
+		UPDATE guns
+		SET quantity_reserved = quantity_reserved + $2
+		WHERE id = $1
+		`
+
+	_, err := q.ExecContext(ctx, query, gunID, quantity)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "guns_quantity_available_check"):
+			return ErrInsufficientStock
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get retrieves a specific reservation.
+func (m ReservationModel) Get(ctx context.Context, id int64) (*Reservation, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + reservationColumns + `
+		FROM reservations
+		WHERE id = $1
+		`
+
+	var reservation Reservation
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanReservation(m.DB.QueryRowContext(ctx, query, id), &reservation)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &reservation, nil
+}
+
+// GetAll returns a page of reservations matching the filters, along with the total number of
+// matching reservations.
+func (m ReservationModel) GetAll(ctx context.Context, reservationFilters ReservationFilters, filters Filters) ([]*Reservation, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM reservations
+		WHERE ($1 = '' OR status = $1)
+		AND ($2 = 0 OR customer_id = $2)
+		AND ($3 = 0 OR gun_id = $3)
+		ORDER BY %s %s, id ASC
+		LIMIT $4 OFFSET $5
+		`, reservationColumns, filters.sortColumn(), filters.sortDirection())
+
+	args := []interface{}{
+		reservationFilters.Status,
+		reservationFilters.CustomerID,
+		reservationFilters.GunID,
+		filters.limit(),
+		filters.offset(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	reservations := []*Reservation{}
+
+	for rows.Next() {
+		var reservation Reservation
+
+		err := rows.Scan(
+			&totalRecords,
+			&reservation.ID,
+			&reservation.CustomerID,
+			&reservation.GunID,
+			&reservation.UnitID,
+			&reservation.Quantity,
+			&reservation.Status,
+			&reservation.Note,
+			&reservation.ExpiresAt,
+			&reservation.UserID,
+			&reservation.SaleID,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+			&reservation.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		reservations = append(reservations, &reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return reservations, totalRecords, nil
+}
+
+// lockActiveReservation reads a reservation and locks its row until the end of the
+// transaction. It returns an ErrRecordNotFound error if there is no such reservation, and an
+// ErrReservationNotActive error if it is no longer active or has run past its expiry time but
+// hasn't been swept yet.
+func lockActiveReservation(ctx context.Context, tx *sql.Tx, id int64) (*Reservation, error) {
+	query := `
+		SELECT ` + reservationColumns + `
+		FROM reservations
+		WHERE id = $1
+		FOR UPDATE
+		`
+
+	var reservation Reservation
+
+	err := scanReservation(tx.QueryRowContext(ctx, query, id), &reservation)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if reservation.Status != ReservationActive || !reservation.ExpiresAt.After(time.Now()) {
+		return nil, ErrReservationNotActive
+	}
+
+	return &reservation, nil
+}
+
+// Extend moves the expiry time of an active reservation.
+func (m ReservationModel) Extend(ctx context.Context, id int64, expiresAt time.Time) (*Reservation, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reservation, err := lockActiveReservation(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE reservations
+		SET expires_at = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $1
+		RETURNING expires_at, updated_at, version
+		`
+
+	err = tx.QueryRowContext(ctx, query, id, expiresAt).Scan(
+		&reservation.ExpiresAt,
+		&reservation.UpdatedAt,
+		&reservation.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// Cancel releases an active reservation before its expiry time.
+func (m ReservationModel) Cancel(ctx context.Context, id int64, userID *int64) (*Reservation, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reservation, err := lockActiveReservation(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = releaseReservation(ctx, tx, reservation, ReservationCancelled, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// Convert turns an active reservation into a sale to its customer, in a single transaction. The
+// hold is released and the held stock, or the held unit, is sold straight away, so nobody else
+// can take it in between. Errors of the sale are returned as for SaleModel.Insert.
+func (m ReservationModel) Convert(ctx context.Context, id int64, userID *int64) (*Reservation, *Sale, error) {
+	if id < 1 {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	reservation, err := lockActiveReservation(ctx, tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = releaseReservation(ctx, tx, reservation, ReservationConverted, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	item := &SaleItem{Quantity: reservation.Quantity}
+	if reservation.UnitID != nil {
+		item.UnitID = reservation.UnitID
+	} else {
+		item.GunID = reservation.GunID
+	}
+
+	sale := &Sale{
+		UserID:     userID,
+		CustomerID: &reservation.CustomerID,
+		Items:      []*SaleItem{item},
+	}
+
+	err = insertSale(ctx, tx, sale)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := `
+		UPDATE reservations
+		SET sale_id = $2
+		WHERE id = $1
+		`
+
+	_, err = tx.ExecContext(ctx, query, reservation.ID, sale.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reservation.SaleID = &sale.ID
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reservation, sale, nil
+}
+
+// ExpireDue releases every active reservation whose expiry time is at or before now, and returns
+// how many were released. Rows locked by another worker are skipped, so that several instances
+// can run this concurrently.
+func (m ReservationModel) ExpireDue(ctx context.Context, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + reservationColumns + `
+		FROM reservations
+		WHERE status = 'active' AND expires_at <= $1
+		ORDER BY expires_at, id
+		LIMIT 500
+		FOR UPDATE SKIP LOCKED
+		`
+
+	rows, err := tx.QueryContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var due []*Reservation
+
+	for rows.Next() {
+		var reservation Reservation
+
+		err := scanReservation(rows, &reservation)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		due = append(due, &reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for _, reservation := range due {
+		err = releaseReservation(ctx, tx, reservation, ReservationExpired, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(due), nil
+}
+
+// releaseReservation ends a locked, active reservation with the provided status. The held
+// quantity goes back to the gun's unreserved stock, and a held unit goes back in stock.
+func releaseReservation(ctx context.Context, tx *sql.Tx, reservation *Reservation, status string, userID *int64) error {
+	if reservation.UnitID != nil {
+		units, err := lockUnits(ctx, tx, []int64{*reservation.UnitID})
+		if err != nil {
+			return err
+		}
+
+		event := &UnitEvent{
+			Status: UnitInStock,
+			Note:   fmt.Sprintf("reservation #%d %s", reservation.ID, status),
+			UserID: userID,
+		}
+
+		err = setUnitStatus(ctx, tx, units[*reservation.UnitID], event)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := reserveStock(ctx, tx, reservation.GunID, -reservation.Quantity)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE reservations
+		SET status = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $1
+		RETURNING status, updated_at, version
+		`
+
+	return tx.QueryRowContext(ctx, query, reservation.ID, status).Scan(
+		&reservation.Status,
+		&reservation.UpdatedAt,
+		&reservation.Version,
+	)
+}
+
+// ValidateReservation checks a new reservation.
+func ValidateReservation(v *validator.Validator, reservation *Reservation) {
+	v.Check(reservation.CustomerID > 0, "customer_id", "must be provided")
+
+	if reservation.UnitID != nil {
+		v.Check(*reservation.UnitID > 0, "unit_id", "must be a valid unit id")
+		v.Check(reservation.GunID == 0, "gun_id", "must not be provided together with unit_id")
+		v.Check(reservation.Quantity == 1, "quantity", "must be 1 for a serialized unit")
+	} else {
+		v.Check(reservation.GunID > 0, "gun_id", "must be provided")
+		v.Check(reservation.Quantity > 0, "quantity", "must be greater than zero")
+	}
+
+	v.Check(len(reservation.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+
+	ValidateReservationExpiry(v, reservation.ExpiresAt)
+}
+
+// ValidateReservationExpiry checks the expiry time of a new or extended reservation.
+func ValidateReservationExpiry(v *validator.Validator, expiresAt time.Time) {
+	v.Check(expiresAt.After(time.Now()), "expires_at", "must be in the future")
+	v.Check(expiresAt.Before(time.Now().Add(MaxReservationPeriod)), "expires_at", "must not be more than 30 days away")
+}
+
+// ValidateReservationFilters checks the filters of a reservation listing.
+func ValidateReservationFilters(v *validator.Validator, f ReservationFilters) {
+	if f.Status != "" {
+		v.Check(validator.In(f.Status, ReservationActive, ReservationExpired, ReservationCancelled, ReservationConverted),
+			"status", "must be active, expired, cancelled or converted")
+	}
+}
@@ -0,0 +1,508 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrReturnWindowExpired    = errors.New("return window expired")
+	ErrReturnQuantityExceeded = errors.New("return quantity exceeds quantity sold")
+	ErrReturnCustomerMismatch = errors.New("sold to a different customer")
+	ErrStoreCreditNoCustomer  = errors.New("store credit requires a customer")
+)
+
+// The condition a returned gun comes back in. Everything is restocked, but serialized units stay
+// in the returned status until they have been inspected and put back in stock by hand.
+const (
+	ConditionNew     = "new"
+	ConditionUsed    = "used"
+	ConditionDamaged = "damaged"
+)
+
+// How the value of a return is paid back to the customer.
+const (
+	RefundCash        = "refund"
+	RefundStoreCredit = "store_credit"
+)
+
+// DefaultReturnWindow is how long after a sale its guns can be returned, unless configured
+// otherwise.
+const DefaultReturnWindow = 30 * 24 * time.Hour
+
+type (
+	// Return represents goods brought back from a sale line. Value is what the customer paid
+	// for the returned quantity. On an exchange the value goes towards the exchange sale first,
+	// and only what is left is refunded, while AmountDue is what the customer still has to pay
+	// when the exchange costs more.
+	Return struct {
+		ID              int64       `json:"id"`
+		SaleID          int64       `json:"sale_id"`
+		SaleItemID      int64       `json:"sale_item_id"`
+		GunID           int64       `json:"gun_id"`
+		UnitID          *int64      `json:"unit_id,omitempty"`
+		CustomerID      *int64      `json:"customer_id"`
+		Quantity        int         `json:"quantity"`
+		Reason          string      `json:"reason"`
+		Condition       string      `json:"condition"`
+		RefundMethod    string      `json:"refund_method"`
+		Value           Money       `json:"value"`
+		RefundAmount    Money       `json:"refund_amount"`
+		AmountDue       Money       `json:"amount_due"`
+		ExchangeSaleID  *int64      `json:"exchange_sale_id,omitempty"`
+		StockMovementID int64       `json:"stock_movement_id"`
+		Note            string      `json:"note"`
+		UserID          *int64      `json:"user_id"`
+		CreatedAt       time.Time   `json:"created_at"`
+		ExchangeItems   []*SaleItem `json:"-"`
+	}
+
+	// ReturnFilters narrows down a list of returns. Zero values don't filter.
+	ReturnFilters struct {
+		SaleID     int64
+		CustomerID int64
+	}
+
+	// ReturnModel struct wraps a sql.DB connection pool and allows us to work with the returns
+	// table.
+	ReturnModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+const returnColumns = `id, sale_id, sale_item_id, gun_id, unit_id, customer_id, quantity, reason,
+	condition, refund_method, value, refund_amount, amount_due, exchange_sale_id,
+	stock_movement_id, note, user_id, created_at`
+
+func scanReturn(row rowScanner, ret *Return) error {
+	return row.Scan(
+		&ret.ID,
+		&ret.SaleID,
+		&ret.SaleItemID,
+		&ret.GunID,
+		&ret.UnitID,
+		&ret.CustomerID,
+		&ret.Quantity,
+		&ret.Reason,
+		&ret.Condition,
+		&ret.RefundMethod,
+		&ret.Value,
+		&ret.RefundAmount,
+		&ret.AmountDue,
+		&ret.ExchangeSaleID,
+		&ret.StockMovementID,
+		&ret.Note,
+		&ret.UserID,
+		&ret.CreatedAt,
+	)
+}
+
+// Insert records a return of a sale line in a single transaction: the returned quantity is put
+// back in stock through a return movement, a serialized unit moves to the returned status and
+// its acquisition from the customer is written to the bound book, the exchange sale if any is
+// recorded, and store credit is added to the customer's balance. The
+// gun, unit and value are taken from the sale line, and the customer from the sale when it
+// isn't provided.
+//
+// Sales that haven't been completed can't be returned and result in an ErrSaleNotCompleted
+// error. Returns more than window after the sale was completed result in an
+// ErrReturnWindowExpired error, returns for a different customer than the one the sale (or the
+// unit's latest sale) was made to in an ErrReturnCustomerMismatch error, more than is left to
+// return in an ErrReturnQuantityExceeded error, and store credit without a customer in an
+// ErrStoreCreditNoCustomer error. An unknown sale line results in an ErrRecordNotFound error, and
+// errors of the exchange sale are returned as for SaleModel.Insert.
+func (m ReturnModel) Insert(ctx context.Context, ret *Return, window time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the sale line, so that concurrent returns of the same line are counted one after the
+	// other.
+	query := `
+		SELECT sale_items.sale_id, sale_items.gun_id, sale_items.unit_id, sale_items.quantity,
+			sale_items.unit_price, sales.customer_id, sales.status, sales.completed_at
+		FROM sale_items
+		INNER JOIN sales ON sales.id = sale_items.sale_id
+		WHERE sale_items.id = $1
+		FOR UPDATE OF sale_items
+		`
+
+	var (
+		quantitySold   int
+		unitPrice      Money
+		saleCustomerID *int64
+		saleStatus     string
+		completedAt    *time.Time
+	)
+
+	err = tx.QueryRowContext(ctx, query, ret.SaleItemID).Scan(
+		&ret.SaleID,
+		&ret.GunID,
+		&ret.UnitID,
+		&quantitySold,
+		&unitPrice,
+		&saleCustomerID,
+		&saleStatus,
+		&completedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	// Guns that haven't been picked up yet are taken back by cancelling the sale instead.
+	if saleStatus != SaleCompleted {
+		return ErrSaleNotCompleted
+	}
+
+	if time.Since(*completedAt) > window {
+		return ErrReturnWindowExpired
+	}
+
+	switch {
+	case ret.CustomerID == nil:
+		ret.CustomerID = saleCustomerID
+	case saleCustomerID != nil && *saleCustomerID != *ret.CustomerID:
+		return ErrReturnCustomerMismatch
+	}
+
+	if ret.RefundMethod == RefundStoreCredit && ret.CustomerID == nil {
+		return ErrStoreCreditNoCustomer
+	}
+
+	query = `
+		SELECT COALESCE(sum(quantity), 0)
+		FROM returns
+		WHERE sale_item_id = $1
+		`
+
+	var quantityReturned int
+
+	err = tx.QueryRowContext(ctx, query, ret.SaleItemID).Scan(&quantityReturned)
+	if err != nil {
+		return err
+	}
+
+	if quantityReturned+ret.Quantity > quantitySold {
+		return ErrReturnQuantityExceeded
+	}
+
+	if ret.UnitID != nil {
+		err = returnUnit(ctx, tx, ret)
+		if err != nil {
+			return err
+		}
+	}
+
+	movement := &StockMovement{
+		GunID:    ret.GunID,
+		Kind:     MovementReturn,
+		Quantity: ret.Quantity,
+		Reason:   fmt.Sprintf("returned from sale %d: %s", ret.SaleID, ret.Reason),
+		UserID:   ret.UserID,
+		SaleID:   &ret.SaleID,
+	}
+
+	err = insertStockMovement(ctx, tx, movement)
+	if err != nil {
+		return err
+	}
+
+	ret.StockMovementID = movement.ID
+	ret.Value = unitPrice.Mul(int64(ret.Quantity))
+	ret.RefundAmount = ret.Value
+	ret.AmountDue = NewMoney(0)
+
+	// The returned stock is put back before the exchange is sold, so that goods can be swapped
+	// for another of the same gun even if they were the last one in stock.
+	if len(ret.ExchangeItems) > 0 {
+		sale := &Sale{
+			UserID:     ret.UserID,
+			CustomerID: ret.CustomerID,
+			Items:      ret.ExchangeItems,
+		}
+
+		err = insertSale(ctx, tx, sale)
+		if err != nil {
+			return err
+		}
+
+		ret.ExchangeSaleID = &sale.ID
+
+		switch difference := ret.Value.Sub(sale.Total); {
+		case difference.IsNegative():
+			ret.RefundAmount = NewMoney(0)
+			ret.AmountDue = sale.Total.Sub(ret.Value)
+		default:
+			ret.RefundAmount = difference
+		}
+	}
+
+	query = `
+		INSERT INTO returns (sale_id, sale_item_id, gun_id, unit_id, customer_id, quantity, reason,
+			condition, refund_method, value, refund_amount, amount_due, exchange_sale_id,
+			stock_movement_id, note, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id, created_at
+		`
+
+	args := []interface{}{
+		ret.SaleID,
+		ret.SaleItemID,
+		ret.GunID,
+		ret.UnitID,
+		ret.CustomerID,
+		ret.Quantity,
+		ret.Reason,
+		ret.Condition,
+		ret.RefundMethod,
+		ret.Value,
+		ret.RefundAmount,
+		ret.AmountDue,
+		ret.ExchangeSaleID,
+		ret.StockMovementID,
+		ret.Note,
+		ret.UserID,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&ret.ID, &ret.CreatedAt)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "returns_customer_id_fkey"):
+			return ErrCustomerNotFound
+		default:
+			return err
+		}
+	}
+
+	if ret.UnitID != nil {
+		entry := &BoundBookEntry{
+			UnitID:   *ret.UnitID,
+			Kind:     BoundBookAcquisition,
+			Source:   BoundBookReturn,
+			SaleID:   &ret.SaleID,
+			ReturnID: &ret.ID,
+			Note:     fmt.Sprintf("returned from sale #%d: %s", ret.SaleID, ret.Reason),
+			UserID:   ret.UserID,
+		}
+
+		if ret.CustomerID != nil {
+			err = setBoundBookParty(ctx, tx, entry, customerPartyQuery, *ret.CustomerID)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = insertBoundBookEntry(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ret.RefundMethod == RefundStoreCredit && !ret.RefundAmount.IsZero() {
+		query = `
+			UPDATE customers
+			SET store_credit = store_credit + $1, updated_at = NOW(), version = version + 1
+			WHERE id = $2
+			`
+
+		_, err = tx.ExecContext(ctx, query, ret.RefundAmount, *ret.CustomerID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// returnUnit moves the serialized unit of a return back to the returned status. The unit must
+// still be sold on the sale being returned; once it has been returned and sold again, only its
+// latest customer can bring it back.
+func returnUnit(ctx context.Context, tx *sql.Tx, ret *Return) error {
+	units, err := lockUnits(ctx, tx, []int64{*ret.UnitID})
+	if err != nil {
+		return err
+	}
+
+	unit, ok := units[*ret.UnitID]
+	if !ok {
+		return ErrUnitNotFound
+	}
+
+	query := `
+		SELECT sale_id
+		FROM gun_unit_events
+		WHERE unit_id = $1 AND status = 'sold'
+		ORDER BY id DESC
+		LIMIT 1
+		`
+
+	var lastSaleID *int64
+
+	err = tx.QueryRowContext(ctx, query, unit.ID).Scan(&lastSaleID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	switch {
+	case lastSaleID != nil && *lastSaleID != ret.SaleID:
+		return ErrReturnCustomerMismatch
+	case unit.Status != UnitSold:
+		return ErrUnitUnavailable
+	}
+
+	event := &UnitEvent{
+		Status: UnitReturned,
+		Note:   fmt.Sprintf("returned (%s): %s", ret.Condition, ret.Reason),
+		UserID: ret.UserID,
+		SaleID: &ret.SaleID,
+	}
+
+	return setUnitStatus(ctx, tx, unit, event)
+}
+
+// Get retrieves a specific return.
+func (m ReturnModel) Get(ctx context.Context, id int64) (*Return, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + returnColumns + `
+		FROM returns
+		WHERE id = $1
+		`
+
+	var ret Return
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanReturn(m.DB.QueryRowContext(ctx, query, id), &ret)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &ret, nil
+}
+
+// GetAll returns a page of returns, optionally limited to a sale or a customer, along with the
+// total number of matching returns.
+func (m ReturnModel) GetAll(ctx context.Context, returnFilters ReturnFilters, filters Filters) ([]*Return, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM returns
+		WHERE ($1 = 0 OR sale_id = $1)
+		AND ($2 = 0 OR customer_id = $2)
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4
+		`, returnColumns, filters.sortColumn(), filters.sortDirection())
+
+	args := []interface{}{returnFilters.SaleID, returnFilters.CustomerID, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	returns := []*Return{}
+
+	for rows.Next() {
+		var ret Return
+
+		err := rows.Scan(
+			&totalRecords,
+			&ret.ID,
+			&ret.SaleID,
+			&ret.SaleItemID,
+			&ret.GunID,
+			&ret.UnitID,
+			&ret.CustomerID,
+			&ret.Quantity,
+			&ret.Reason,
+			&ret.Condition,
+			&ret.RefundMethod,
+			&ret.Value,
+			&ret.RefundAmount,
+			&ret.AmountDue,
+			&ret.ExchangeSaleID,
+			&ret.StockMovementID,
+			&ret.Note,
+			&ret.UserID,
+			&ret.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		returns = append(returns, &ret)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return returns, totalRecords, nil
+}
+
+// ValidateReturn checks a new return and the items of its exchange, if any. The customer of the
+// exchange is only checked by Insert, since it is taken from the original sale when the return
+// doesn't name one.
+func ValidateReturn(v *validator.Validator, ret *Return) {
+	v.Check(ret.SaleItemID > 0, "sale_item_id", "must be provided")
+
+	if ret.CustomerID != nil {
+		v.Check(*ret.CustomerID > 0, "customer_id", "must be a valid customer id")
+	}
+
+	v.Check(ret.Quantity > 0, "quantity", "must be greater than zero")
+
+	v.Check(ret.Reason != "", "reason", "must be provided")
+	v.Check(len(ret.Reason) <= 500, "reason", "must not be more than 500 bytes long")
+	v.Check(len(ret.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+
+	v.Check(validator.In(ret.Condition, ConditionNew, ConditionUsed, ConditionDamaged),
+		"condition", "must be new, used or damaged")
+	v.Check(validator.In(ret.RefundMethod, RefundCash, RefundStoreCredit),
+		"refund_method", "must be refund or store_credit")
+
+	if len(ret.ExchangeItems) > 0 {
+		exchange := validator.New()
+		ValidateSaleItems(exchange, ret.ExchangeItems)
+
+		for key, message := range exchange.Errors {
+			v.AddError("exchange_"+key, message)
+		}
+	}
+}
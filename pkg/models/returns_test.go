@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+func TestValidateReturnExchange(t *testing.T) {
+	unitID := int64(7)
+
+	tests := []struct {
+		name       string
+		items      []*SaleItem
+		wantErrors []string
+	}{
+		{name: "no exchange"},
+		{name: "stock", items: []*SaleItem{{GunID: 1, Quantity: 2}}},
+		// The customer is taken from the original sale by Insert.
+		{name: "serialized unit without a customer", items: []*SaleItem{{UnitID: &unitID, Quantity: 1}}},
+		{name: "missing gun", items: []*SaleItem{{Quantity: 1}}, wantErrors: []string{"exchange_items[0].gun_id"}},
+		{name: "two of a unit", items: []*SaleItem{{UnitID: &unitID, Quantity: 2}},
+			wantErrors: []string{"exchange_items[0].quantity"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ret := &Return{
+				SaleItemID:    1,
+				Quantity:      1,
+				Reason:        "wrong size",
+				Condition:     ConditionNew,
+				RefundMethod:  RefundCash,
+				ExchangeItems: tt.items,
+			}
+
+			v := validator.New()
+			ValidateReturn(v, ret)
+
+			if len(v.Errors) != len(tt.wantErrors) {
+				t.Errorf("errors = %v, want %v", v.Errors, tt.wantErrors)
+			}
+			for _, key := range tt.wantErrors {
+				if _, ok := v.Errors[key]; !ok {
+					t.Errorf("no error for %q in %v", key, v.Errors)
+				}
+			}
+		})
+	}
+}
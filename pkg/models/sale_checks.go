@@ -0,0 +1,471 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// SaleEvent records a sale entering a status. The events of a sale are its full history, from
+// the register to pickup.
+type SaleEvent struct {
+	ID        int64     `json:"id"`
+	SaleID    int64     `json:"sale_id"`
+	Status    string    `json:"status"`
+	Note      string    `json:"note"`
+	UserID    *int64    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// canTransitionSale reports whether a sale may move from one status to another.
+func canTransitionSale(from, to string) bool {
+	return validator.In(to, saleTransitions[from]...)
+}
+
+// insertSaleEvent appends an event to the history of a sale.
+func insertSaleEvent(ctx context.Context, q queryer, event *SaleEvent) error {
+	query := `
+		INSERT INTO sale_events (sale_id, status, note, user_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+		`
+
+	args := []interface{}{event.SaleID, event.Status, event.Note, event.UserID}
+
+	return q.QueryRowContext(ctx, query, args...).Scan(&event.ID, &event.CreatedAt)
+}
+
+// loadHistory fetches the events of a sale.
+func (m SaleModel) loadHistory(ctx context.Context, sale *Sale) error {
+	query := `
+		SELECT id, sale_id, status, note, user_id, created_at
+		FROM sale_events
+		WHERE sale_id = $1
+		ORDER BY id
+		`
+
+	rows, err := m.DB.QueryContext(ctx, query, sale.ID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	sale.History = []*SaleEvent{}
+
+	for rows.Next() {
+		var event SaleEvent
+
+		err := rows.Scan(&event.ID, &event.SaleID, &event.Status, &event.Note, &event.UserID, &event.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		sale.History = append(sale.History, &event)
+	}
+
+	return rows.Err()
+}
+
+// lockSale reads a sale, locking its row until the end of the transaction.
+func lockSale(ctx context.Context, tx *sql.Tx, id int64) (*Sale, error) {
+	query := `
+		SELECT ` + saleColumns + `
+		FROM sales
+		WHERE id = $1
+		FOR UPDATE
+		`
+
+	var sale Sale
+
+	err := scanSale(tx.QueryRowContext(ctx, query, id), &sale)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &sale, nil
+}
+
+// setSaleStatus moves a locked sale to a new status, saving its check reference and pickup time
+// along with it, and records the event in its history. Completing a sale stamps completed_at. It
+// returns an ErrInvalidTransition error if the sale can't move to that status.
+func setSaleStatus(ctx context.Context, tx *sql.Tx, sale *Sale, event *SaleEvent) error {
+	if !canTransitionSale(sale.Status, event.Status) {
+		return ErrInvalidTransition
+	}
+
+	query := `
+		UPDATE sales
+		SET status = $1, check_reference = $2, pickup_after = $3, updated_at = NOW(),
+			completed_at = CASE WHEN $1 = 'completed' THEN NOW() ELSE completed_at END,
+			version = version + 1
+		WHERE id = $4
+		RETURNING updated_at, completed_at, version
+		`
+
+	args := []interface{}{event.Status, sale.CheckReference, sale.PickupAfter, sale.ID}
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&sale.UpdatedAt, &sale.CompletedAt, &sale.Version)
+	if err != nil {
+		return err
+	}
+
+	sale.Status = event.Status
+	event.SaleID = sale.ID
+
+	return insertSaleEvent(ctx, tx, event)
+}
+
+// restockSale puts the guns of a denied or cancelled sale back in stock: its units go back to
+// in_stock, and each line is returned to the stock ledger.
+func restockSale(ctx context.Context, tx *sql.Tx, sale *Sale, userID *int64) error {
+	query := `
+		SELECT gun_id, unit_id, quantity
+		FROM sale_items
+		WHERE sale_id = $1
+		ORDER BY id
+		`
+
+	rows, err := tx.QueryContext(ctx, query, sale.ID)
+	if err != nil {
+		return err
+	}
+
+	var (
+		items   []*SaleItem
+		unitIDs []int64
+	)
+
+	for rows.Next() {
+		var item SaleItem
+
+		err := rows.Scan(&item.GunID, &item.UnitID, &item.Quantity)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		items = append(items, &item)
+		if item.UnitID != nil {
+			unitIDs = append(unitIDs, *item.UnitID)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	units, err := lockUnits(ctx, tx, unitIDs)
+	if err != nil {
+		return err
+	}
+
+	reason := fmt.Sprintf("sale #%d %s", sale.ID, sale.Status)
+
+	for _, item := range items {
+		if item.UnitID != nil {
+			event := &UnitEvent{Status: UnitInStock, Note: reason, UserID: userID, SaleID: &sale.ID}
+
+			err = setUnitStatus(ctx, tx, units[*item.UnitID], event)
+			if err != nil {
+				return err
+			}
+		}
+
+		movement := &StockMovement{
+			GunID:    item.GunID,
+			Kind:     MovementReturn,
+			Quantity: item.Quantity,
+			Reason:   reason,
+			UserID:   userID,
+			SaleID:   &sale.ID,
+		}
+
+		err = insertStockMovement(ctx, tx, movement)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkWaitingPeriod returns an ErrWaitingPeriod error if moving an approved sale to status would
+// release its guns before its waiting period has elapsed at now. Cancelling is always allowed.
+func checkWaitingPeriod(sale *Sale, status string, now time.Time) error {
+	if status != SaleCancelled && sale.Status == SaleApproved && sale.PickupAfter != nil && now.Before(*sale.PickupAfter) {
+		return ErrWaitingPeriod
+	}
+	return nil
+}
+
+// UpdateStatus moves a sale to one of the statuses that can be set by hand: ready for pickup,
+// completed when the buyer picks up the guns, which writes their dispositions to the bound book,
+// or cancelled, which puts them back in stock. The status must be a valid transition from the
+// current one, otherwise an ErrInvalidTransition error is returned, and the guns can't be
+// released before the waiting period has elapsed at now, which results in an ErrWaitingPeriod
+// error.
+func (m SaleModel) UpdateStatus(ctx context.Context, id int64, status, note string, userID *int64, now time.Time) (*Sale, error) {
+	if !validator.In(status, manualSaleStatuses...) {
+		return nil, ErrInvalidTransition
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	sale, err := lockSale(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkWaitingPeriod(sale, status, now)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setSaleStatus(ctx, tx, sale, &SaleEvent{Status: status, Note: note, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case SaleCancelled:
+		err = restockSale(ctx, tx, sale, userID)
+	case SaleCompleted:
+		err = recordSaleDispositions(ctx, tx, sale, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Get(ctx, id)
+}
+
+// GetAwaitingCheck returns the sales whose background check is still to be run or, for delayed
+// checks, to be asked about again, oldest first.
+func (m SaleModel) GetAwaitingCheck(ctx context.Context) ([]*Sale, error) {
+	query := `
+		SELECT ` + saleColumns + `
+		FROM sales
+		WHERE status IN ('pending_check', 'delayed')
+		ORDER BY id
+		LIMIT 100
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	sales := []*Sale{}
+
+	for rows.Next() {
+		var sale Sale
+
+		err := scanSale(rows, &sale)
+		if err != nil {
+			return nil, err
+		}
+
+		sales = append(sales, &sale)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sales, nil
+}
+
+// applyCheckOutcome applies the outcome of a background check to a sale that is waiting for it:
+// the sale takes the provider's reference, and an approved sale can be picked up once
+// waitingPeriod has passed since the sale. It reports whether the sale moves to the outcome's
+// status, which it doesn't when a delayed check is still delayed, and returns an
+// ErrInvalidTransition error if the sale isn't waiting for its check.
+func applyCheckOutcome(sale *Sale, outcome, reference string, waitingPeriod time.Duration) (bool, error) {
+	if !validator.In(outcome, SaleApproved, SaleDelayed, SaleDenied) {
+		return false, ErrInvalidTransition
+	}
+	if !validator.In(sale.Status, SalePendingCheck, SaleDelayed) {
+		return false, ErrInvalidTransition
+	}
+
+	sale.CheckReference = reference
+
+	if outcome == sale.Status {
+		return false, nil
+	}
+
+	if outcome == SaleApproved {
+		pickupAfter := sale.CreatedAt.Add(waitingPeriod)
+		sale.PickupAfter = &pickupAfter
+	}
+
+	return true, nil
+}
+
+// RecordCheck records the outcome of the background check of a sale: approved, delayed or
+// denied, with the provider's reference and the reason it gave. An approved sale can be picked up
+// once waitingPeriod has passed since the sale, and a denied sale puts its guns back in stock. A
+// sale that is no longer waiting for its check, because it was cancelled in the meantime,
+// results in an ErrInvalidTransition error.
+func (m SaleModel) RecordCheck(ctx context.Context, id int64, outcome, reference, reason string, waitingPeriod time.Duration) (*Sale, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	sale, err := lockSale(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	transition, err := applyCheckOutcome(sale, outcome, reference, waitingPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	note := strings.TrimSpace("background check " + reference + ": " + outcome + " " + reason)
+
+	switch {
+	case !transition:
+		// Still delayed: only keep the reference, which changes if the check was submitted again.
+		query := `
+			UPDATE sales
+			SET check_reference = $1, updated_at = NOW(), version = version + 1
+			WHERE id = $2
+			RETURNING updated_at, version
+			`
+
+		err = tx.QueryRowContext(ctx, query, reference, sale.ID).Scan(&sale.UpdatedAt, &sale.Version)
+	default:
+		err = setSaleStatus(ctx, tx, sale, &SaleEvent{Status: outcome, Note: note})
+		if err == nil && outcome == SaleDenied {
+			err = restockSale(ctx, tx, sale, nil)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return sale, nil
+}
+
+// ReleaseDue makes every approved sale whose waiting period has elapsed at now ready for pickup,
+// and returns how many were released. Rows locked by another worker are skipped, so that several
+// instances can run this concurrently.
+func (m SaleModel) ReleaseDue(ctx context.Context, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + saleColumns + `
+		FROM sales
+		WHERE status = 'approved' AND pickup_after <= $1
+		ORDER BY pickup_after, id
+		LIMIT 500
+		FOR UPDATE SKIP LOCKED
+		`
+
+	rows, err := tx.QueryContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var due []*Sale
+
+	for rows.Next() {
+		var sale Sale
+
+		err := scanSale(rows, &sale)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		due = append(due, &sale)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for _, sale := range due {
+		err = setSaleStatus(ctx, tx, sale, &SaleEvent{Status: SaleReadyForPickup, Note: "waiting period elapsed"})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(due), nil
+}
+
+// ValidateSaleStatus checks a status requested through UpdateStatus.
+func ValidateSaleStatus(v *validator.Validator, status, note string) {
+	v.Check(validator.In(status, manualSaleStatuses...), "status",
+		"must be one of "+strings.Join(manualSaleStatuses, ", "))
+	v.Check(len(note) <= 500, "note", "must not be more than 500 bytes long")
+}
+
+// ValidateSaleStatusFilter checks the status filter of a sale listing.
+func ValidateSaleStatusFilter(v *validator.Validator, status string) {
+	if status != "" {
+		v.Check(validator.In(status, SalePendingCheck, SaleApproved, SaleDelayed, SaleDenied, SaleReadyForPickup,
+			SaleCompleted, SaleCancelled),
+			"status", "must be pending_check, approved, delayed, denied, ready_for_pickup, completed or cancelled")
+	}
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCanTransitionSale(t *testing.T) {
+	allowed := map[string][]string{
+		SalePendingCheck:   {SaleApproved, SaleDelayed, SaleDenied, SaleCancelled},
+		SaleDelayed:        {SaleApproved, SaleDenied, SaleCancelled},
+		SaleApproved:       {SaleReadyForPickup, SaleCancelled},
+		SaleReadyForPickup: {SaleCompleted, SaleCancelled},
+		SaleDenied:         {},
+		SaleCompleted:      {},
+		SaleCancelled:      {},
+	}
+
+	statuses := []string{SalePendingCheck, SaleApproved, SaleDelayed, SaleDenied, SaleReadyForPickup,
+		SaleCompleted, SaleCancelled}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := false
+			for _, status := range allowed[from] {
+				want = want || status == to
+			}
+
+			if got := canTransitionSale(from, to); got != want {
+				t.Errorf("canTransitionSale(%q, %q) = %t, want %t", from, to, got, want)
+			}
+		}
+	}
+
+	if len(saleTransitions) != len(statuses) {
+		t.Errorf("saleTransitions has %d statuses, want %d", len(saleTransitions), len(statuses))
+	}
+	if canTransitionSale("unknown", SaleApproved) {
+		t.Error("canTransitionSale allowed a transition from an unknown status")
+	}
+}
+
+func TestManualSaleStatusesSkipTheCheck(t *testing.T) {
+	for _, status := range manualSaleStatuses {
+		if canTransitionSale(SalePendingCheck, status) && status != SaleCancelled {
+			t.Errorf("%q can be set by hand on a sale waiting for its check", status)
+		}
+		if canTransitionSale(SaleDelayed, status) && status != SaleCancelled {
+			t.Errorf("%q can be set by hand on a delayed sale", status)
+		}
+	}
+}
+
+func TestCheckWaitingPeriod(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		status      string
+		pickupAfter *time.Time
+		to          string
+		wantErr     error
+	}{
+		{name: "before the waiting period", status: SaleApproved, pickupAfter: &later, to: SaleReadyForPickup, wantErr: ErrWaitingPeriod},
+		{name: "completed before the waiting period", status: SaleApproved, pickupAfter: &later, to: SaleCompleted, wantErr: ErrWaitingPeriod},
+		{name: "cancelled before the waiting period", status: SaleApproved, pickupAfter: &later, to: SaleCancelled},
+		{name: "after the waiting period", status: SaleApproved, pickupAfter: &earlier, to: SaleReadyForPickup},
+		{name: "at the end of the waiting period", status: SaleApproved, pickupAfter: &now, to: SaleReadyForPickup},
+		{name: "no waiting period", status: SaleApproved, to: SaleReadyForPickup},
+		{name: "ready for pickup", status: SaleReadyForPickup, pickupAfter: &later, to: SaleCompleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sale := &Sale{Status: tt.status, PickupAfter: tt.pickupAfter}
+
+			err := checkWaitingPeriod(sale, tt.to, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkWaitingPeriod() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyCheckOutcome(t *testing.T) {
+	createdAt := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	waitingPeriod := 3 * 24 * time.Hour
+
+	tests := []struct {
+		name           string
+		status         string
+		outcome        string
+		wantTransition bool
+		wantPickup     bool
+		wantErr        error
+	}{
+		{name: "approved", status: SalePendingCheck, outcome: SaleApproved, wantTransition: true, wantPickup: true},
+		{name: "delayed", status: SalePendingCheck, outcome: SaleDelayed, wantTransition: true},
+		{name: "denied", status: SalePendingCheck, outcome: SaleDenied, wantTransition: true},
+		{name: "delayed then approved", status: SaleDelayed, outcome: SaleApproved, wantTransition: true, wantPickup: true},
+		{name: "delayed then denied", status: SaleDelayed, outcome: SaleDenied, wantTransition: true},
+		{name: "still delayed", status: SaleDelayed, outcome: SaleDelayed},
+		{name: "cancelled meanwhile", status: SaleCancelled, outcome: SaleApproved, wantErr: ErrInvalidTransition},
+		{name: "already approved", status: SaleApproved, outcome: SaleDenied, wantErr: ErrInvalidTransition},
+		{name: "not a check outcome", status: SalePendingCheck, outcome: SaleCancelled, wantErr: ErrInvalidTransition},
+		{name: "unknown outcome", status: SalePendingCheck, outcome: "maybe", wantErr: ErrInvalidTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sale := &Sale{Status: tt.status, CheckReference: "OLD", CreatedAt: createdAt}
+
+			transition, err := applyCheckOutcome(sale, tt.outcome, "REF-1", waitingPeriod)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("applyCheckOutcome() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if sale.CheckReference != "OLD" || sale.PickupAfter != nil {
+					t.Error("applyCheckOutcome() changed a sale it rejected")
+				}
+				return
+			}
+
+			if transition != tt.wantTransition {
+				t.Errorf("applyCheckOutcome() transition = %t, want %t", transition, tt.wantTransition)
+			}
+			if transition && !canTransitionSale(tt.status, tt.outcome) {
+				t.Errorf("applyCheckOutcome() moves a sale from %q to %q", tt.status, tt.outcome)
+			}
+			if sale.CheckReference != "REF-1" {
+				t.Errorf("CheckReference = %q, want REF-1", sale.CheckReference)
+			}
+
+			switch {
+			case !tt.wantPickup && sale.PickupAfter != nil:
+				t.Errorf("PickupAfter = %v, want nil", *sale.PickupAfter)
+			case tt.wantPickup && (sale.PickupAfter == nil || !sale.PickupAfter.Equal(createdAt.Add(waitingPeriod))):
+				t.Errorf("PickupAfter = %v, want %v", sale.PickupAfter, createdAt.Add(waitingPeriod))
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrCustomerRequired = errors.New("customer required for a background check")
+	ErrWaitingPeriod    = errors.New("waiting period has not elapsed")
+	ErrSaleNotCompleted = errors.New("sale is not completed")
+)
+
+// The statuses of a sale. Sales of serialized firearms start out pending a background check,
+// which approves, delays or denies them; approved sales become ready for pickup once the waiting
+// period has elapsed, and are completed when the buyer picks up the guns. Every other sale is
+// completed at the register. Denied and cancelled sales put their guns back in stock.
+const (
+	SalePendingCheck   = "pending_check"
+	SaleApproved       = "approved"
+	SaleDelayed        = "delayed"
+	SaleDenied         = "denied"
+	SaleReadyForPickup = "ready_for_pickup"
+	SaleCompleted      = "completed"
+	SaleCancelled      = "cancelled"
+)
+
+// saleTransitions lists the statuses that a sale may move to from each status.
+var saleTransitions = map[string][]string{
+	SalePendingCheck:   {SaleApproved, SaleDelayed, SaleDenied, SaleCancelled},
+	SaleDelayed:        {SaleApproved, SaleDenied, SaleCancelled},
+	SaleApproved:       {SaleReadyForPickup, SaleCancelled},
+	SaleReadyForPickup: {SaleCompleted, SaleCancelled},
+	SaleDenied:         {},
+	SaleCompleted:      {},
+	SaleCancelled:      {},
+}
+
+// manualSaleStatuses are the statuses that can be set directly through UpdateStatus. The other
+// statuses are set by the outcome of the background check, see RecordCheck.
+var manualSaleStatuses = []string{SaleReadyForPickup, SaleCompleted, SaleCancelled}
+
+type (
+	// Sale represents a sale. Total is the sum of the line items, priced at the time of the
+	// sale. CheckReference identifies the background check with the provider, and PickupAfter is
+	// when the waiting period of an approved sale ends. History is only loaded for a single sale.
+	Sale struct {
+		ID             int64        `json:"id"`
+		UserID         *int64       `json:"user_id"`
+		CustomerID     *int64       `json:"customer_id"`
+		Status         string       `json:"status"`
+		CheckReference string       `json:"check_reference,omitempty"`
+		PickupAfter    *time.Time   `json:"pickup_after,omitempty"`
+		Total          Money        `json:"total"`
+		Items          []*SaleItem  `json:"items"`
+		CreatedAt      time.Time    `json:"created_at"`
+		UpdatedAt      time.Time    `json:"updated_at"`
+		CompletedAt    *time.Time   `json:"completed_at"`
+		Version        int          `json:"version"`
+		History        []*SaleEvent `json:"history,omitempty"`
+	}
+
+	// SaleItem is a single line of a sale. ListPrice is copied from the catalog when the sale
+	// is recorded, so later price changes don't affect past sales, and UnitPrice is what the
+	// customer paid after promotions. Serialized firearms are sold by UnitID, one unit per line,
+	// and the GunID is then taken from the unit.
+	SaleItem struct {
+		ID        int64  `json:"id"`
+		SaleID    int64  `json:"-"`
+		GunID     int64  `json:"gun_id"`
+		UnitID    *int64 `json:"unit_id,omitempty"`
+		Quantity  int    `json:"quantity"`
+		ListPrice Money  `json:"list_price"`
+		UnitPrice Money  `json:"unit_price"`
+	}
+
+	// SaleModel struct wraps a sql.DB connection pool and allows us to work with the sales and
+	// sale_items tables.
+	SaleModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+const saleColumns = `id, user_id, customer_id, status, check_reference, pickup_after, total,
+	created_at, updated_at, completed_at, version`
+
+func scanSale(row rowScanner, sale *Sale) error {
+	return row.Scan(
+		&sale.ID,
+		&sale.UserID,
+		&sale.CustomerID,
+		&sale.Status,
+		&sale.CheckReference,
+		&sale.PickupAfter,
+		&sale.Total,
+		&sale.CreatedAt,
+		&sale.UpdatedAt,
+		&sale.CompletedAt,
+		&sale.Version,
+	)
+}
+
+// Insert records a sale in a single transaction: the sale, its line items and the stock
+// movements that take the sold guns out of stock are either all written, or none of them are.
+// Sales of serialized units are left pending a background check, and need a customer to check,
+// otherwise an ErrCustomerRequired error is returned; other sales are completed straight away.
+// Errors caused by a specific line item, such as an unknown gun (ErrRecordNotFound), not enough
+// stock (ErrInsufficientStock), a unit that can't be sold (ErrUnitNotFound, ErrUnitUnavailable),
+// or a serialized gun sold without naming its units (ErrUnitRequired), are wrapped in an
+// *ItemError. An unknown customer results in an ErrCustomerNotFound error.
+func (m SaleModel) Insert(ctx context.Context, sale *Sale) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertSale(ctx, tx, sale)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertSale records a sale as part of the transaction tx.
+func insertSale(ctx context.Context, tx *sql.Tx, sale *Sale) error {
+	var unitIDs []int64
+	for _, item := range sale.Items {
+		if item.UnitID != nil {
+			unitIDs = append(unitIDs, *item.UnitID)
+		}
+	}
+
+	// Lock the units being sold first, and fill in the gun of each unit line.
+	units, err := lockUnits(ctx, tx, unitIDs)
+	if err != nil {
+		return err
+	}
+
+	for i, item := range sale.Items {
+		if item.UnitID == nil {
+			continue
+		}
+
+		unit, ok := units[*item.UnitID]
+		switch {
+		case !ok:
+			return &ItemError{Index: i, Err: ErrUnitNotFound}
+		case unit.Status != UnitInStock:
+			return &ItemError{Index: i, Err: ErrUnitUnavailable}
+		}
+
+		item.GunID = unit.GunID
+	}
+
+	gunIDs := make([]int64, len(sale.Items))
+	for i, item := range sale.Items {
+		gunIDs[i] = item.GunID
+	}
+
+	// Guns that have serialized units in stock have to be sold by unit, so that we always know
+	// which physical firearm left the shop.
+	serialized, err := serializedGuns(ctx, tx, gunIDs)
+	if err != nil {
+		return err
+	}
+
+	for i, item := range sale.Items {
+		if item.UnitID == nil && serialized[item.GunID] {
+			return &ItemError{Index: i, Err: ErrUnitRequired}
+		}
+	}
+
+	// Lock the guns being sold, in a consistent order to avoid deadlocks between concurrent
+	// sales, and price them with the promotions running now.
+	guns, err := pricedGuns(ctx, tx, gunIDs, true)
+	if err != nil {
+		return err
+	}
+
+	promotions, err := activePromotions(ctx, tx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	items := make([]QuoteItem, len(sale.Items))
+	for i, item := range sale.Items {
+		items[i] = QuoteItem{GunID: item.GunID, Quantity: item.Quantity}
+	}
+
+	quote, err := priceBasket(items, guns, promotions, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for i, item := range sale.Items {
+		item.ListPrice = quote.Lines[i].ListPrice
+		item.UnitPrice = quote.Lines[i].UnitPrice
+	}
+
+	sale.Total = quote.Total
+
+	// Firearms that are tracked by unit can't leave the shop before a background check.
+	sale.Status = SaleCompleted
+	if len(unitIDs) > 0 {
+		if sale.CustomerID == nil {
+			return ErrCustomerRequired
+		}
+		sale.Status = SalePendingCheck
+	}
+
+	query := `
+		INSERT INTO sales (user_id, customer_id, status, total, completed_at)
+		VALUES ($1, $2, $3, $4, CASE WHEN $3 = 'completed' THEN NOW() END)
+		RETURNING id, created_at, updated_at, completed_at, version
+		`
+
+	args := []interface{}{sale.UserID, sale.CustomerID, sale.Status, sale.Total}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&sale.ID,
+		&sale.CreatedAt,
+		&sale.UpdatedAt,
+		&sale.CompletedAt,
+		&sale.Version,
+	)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "sales_customer_id_fkey"):
+			return ErrCustomerNotFound
+		default:
+			return err
+		}
+	}
+
+	event := &SaleEvent{SaleID: sale.ID, Status: sale.Status, UserID: sale.UserID}
+
+	err = insertSaleEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	for i, item := range sale.Items {
+		item.SaleID = sale.ID
+
+		query := `
+			INSERT INTO sale_items (sale_id, gun_id, unit_id, quantity, list_price, unit_price)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+			`
+
+		args := []interface{}{item.SaleID, item.GunID, item.UnitID, item.Quantity, item.ListPrice, item.UnitPrice}
+
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+
+		if item.UnitID != nil {
+			event := &UnitEvent{
+				Status: UnitSold,
+				Note:   fmt.Sprintf("sale #%d", sale.ID),
+				UserID: sale.UserID,
+				SaleID: &sale.ID,
+			}
+
+			err = setUnitStatus(ctx, tx, units[*item.UnitID], event)
+			if err != nil {
+				return &ItemError{Index: i, Err: err}
+			}
+		}
+
+		movement := &StockMovement{
+			GunID:    item.GunID,
+			Kind:     MovementSell,
+			Quantity: -item.Quantity,
+			Reason:   fmt.Sprintf("sale #%d", sale.ID),
+			UserID:   sale.UserID,
+			SaleID:   &sale.ID,
+		}
+
+		err = insertStockMovement(ctx, tx, movement)
+		if err != nil {
+			return &ItemError{Index: i, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// serializedGuns returns the set of the provided guns that have units in stock.
+func serializedGuns(ctx context.Context, tx *sql.Tx, gunIDs []int64) (map[int64]bool, error) {
+	query := `
+		SELECT DISTINCT gun_id
+		FROM gun_units
+		WHERE gun_id = ANY($1) AND status IN ('in_stock', 'reserved', 'returned')
+		`
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(gunIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	serialized := make(map[int64]bool)
+
+	for rows.Next() {
+		var gunID int64
+
+		err := rows.Scan(&gunID)
+		if err != nil {
+			return nil, err
+		}
+
+		serialized[gunID] = true
+	}
+
+	return serialized, rows.Err()
+}
+
+// Get retrieves a specific sale, including its line items.
+func (m SaleModel) Get(ctx context.Context, id int64) (*Sale, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + saleColumns + `
+		FROM sales
+		WHERE id = $1
+		`
+
+	var sale Sale
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanSale(m.DB.QueryRowContext(ctx, query, id), &sale)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = m.loadItems(ctx, []*Sale{&sale})
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.loadHistory(ctx, &sale)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sale, nil
+}
+
+// GetAll returns a page of sales, including their line items, optionally limited to a status,
+// along with the total number of matching sales.
+func (m SaleModel) GetAll(ctx context.Context, status string, filters Filters) ([]*Sale, int, error) {
+	return m.getAll(ctx, nil, status, filters)
+}
+
+// GetAllForCustomer returns a page of the sales made to a specific customer, including their
+// line items, along with the total number of sales made to that customer.
+func (m SaleModel) GetAllForCustomer(ctx context.Context, customerID int64, filters Filters) ([]*Sale, int, error) {
+	return m.getAll(ctx, &customerID, "", filters)
+}
+
+// getAll returns a page of sales, optionally limited to a specific customer and status.
+func (m SaleModel) getAll(ctx context.Context, customerID *int64, status string, filters Filters) ([]*Sale, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM sales
+		WHERE ($1::bigint IS NULL OR customer_id = $1)
+		AND ($2 = '' OR status = $2)
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4
+		`, saleColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, customerID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	sales := []*Sale{}
+
+	for rows.Next() {
+		var sale Sale
+
+		err := rows.Scan(
+			&totalRecords,
+			&sale.ID,
+			&sale.UserID,
+			&sale.CustomerID,
+			&sale.Status,
+			&sale.CheckReference,
+			&sale.PickupAfter,
+			&sale.Total,
+			&sale.CreatedAt,
+			&sale.UpdatedAt,
+			&sale.CompletedAt,
+			&sale.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		sales = append(sales, &sale)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	err = m.loadItems(ctx, sales)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sales, totalRecords, nil
+}
+
+// loadItems fetches the line items of the provided sales with a single query.
+func (m SaleModel) loadItems(ctx context.Context, sales []*Sale) error {
+	if len(sales) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Sale, len(sales))
+	ids := make([]int64, len(sales))
+
+	for i, sale := range sales {
+		sale.Items = []*SaleItem{}
+		byID[sale.ID] = sale
+		ids[i] = sale.ID
+	}
+
+	query := `
+		SELECT id, sale_id, gun_id, unit_id, quantity, list_price, unit_price
+		FROM sale_items
+		WHERE sale_id = ANY($1)
+		ORDER BY id
+		`
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	for rows.Next() {
+		var item SaleItem
+
+		err := rows.Scan(&item.ID, &item.SaleID, &item.GunID, &item.UnitID, &item.Quantity, &item.ListPrice, &item.UnitPrice)
+		if err != nil {
+			return err
+		}
+
+		sale := byID[item.SaleID]
+		sale.Items = append(sale.Items, &item)
+	}
+
+	return rows.Err()
+}
+
+// ValidateSale checks the line items of a new sale. Serialized units can only be sold to a
+// customer, who goes through the background check.
+func ValidateSale(v *validator.Validator, sale *Sale) {
+	if sale.CustomerID != nil {
+		v.Check(*sale.CustomerID > 0, "customer_id", "must be a valid customer id")
+	}
+
+	for _, item := range sale.Items {
+		if item.UnitID != nil {
+			v.Check(sale.CustomerID != nil, "customer_id", "must be provided to sell serialized guns")
+			break
+		}
+	}
+
+	ValidateSaleItems(v, sale.Items)
+}
+
+// ValidateSaleItems checks the line items of a new sale on their own, for sales whose customer
+// is only known once they are inserted, such as the exchange of a return.
+func ValidateSaleItems(v *validator.Validator, items []*SaleItem) {
+	v.Check(len(items) > 0, "items", "must contain at least one item")
+	v.Check(len(items) <= 100, "items", "must not contain more than 100 items")
+
+	for i, item := range items {
+		key := fmt.Sprintf("items[%d]", i)
+
+		if item.UnitID != nil {
+			v.Check(*item.UnitID > 0, key+".unit_id", "must be a valid unit id")
+			v.Check(item.GunID == 0, key+".gun_id", "must not be provided together with unit_id")
+			v.Check(item.Quantity == 1, key+".quantity", "must be 1 for a serialized unit")
+			continue
+		}
+
+		v.Check(item.GunID > 0, key+".gun_id", "must be provided")
+		v.Check(item.Quantity > 0, key+".quantity", "must be greater than zero")
+		v.Check(item.Quantity <= 10000, key+".quantity", "must not be more than 10000")
+	}
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// The value types a spec can have in a SpecSchema.
+const (
+	SpecString  = "string"
+	SpecNumber  = "number"
+	SpecInteger = "integer"
+	SpecBoolean = "boolean"
+)
+
+// The comparison operators of a SpecFilter.
+const (
+	SpecEq  = "eq"
+	SpecGt  = "gt"
+	SpecGte = "gte"
+	SpecLt  = "lt"
+	SpecLte = "lte"
+)
+
+// SpecKeyRX matches valid spec keys, such as caliber or barrel_length_mm.
+var SpecKeyRX = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// specOperators maps the comparison operators of a SpecFilter to their SQL operators.
+var specOperators = map[string]string{
+	SpecEq:  "=",
+	SpecGt:  ">",
+	SpecGte: ">=",
+	SpecLt:  "<",
+	SpecLte: "<=",
+}
+
+// Specs holds the technical specifications of a gun, such as its caliber or capacity, keyed by
+// spec name. It is stored as a jsonb document, and the allowed keys and value types depend on
+// the category of the gun, see SpecSchema.
+type Specs map[string]interface{}
+
+// Scan implements the sql.Scanner interface for jsonb columns.
+func (s *Specs) Scan(src interface{}) error {
+	return scanJSONB(src, s)
+}
+
+// Value implements the driver.Valuer interface for jsonb columns. A nil Specs is stored as an
+// empty object.
+func (s Specs) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(s)
+}
+
+// SpecSchema maps the spec keys allowed in a category to their value type, one of SpecString,
+// SpecNumber, SpecInteger or SpecBoolean.
+type SpecSchema map[string]string
+
+// Scan implements the sql.Scanner interface for jsonb columns.
+func (s *SpecSchema) Scan(src interface{}) error {
+	return scanJSONB(src, s)
+}
+
+// Value implements the driver.Valuer interface for jsonb columns. A nil SpecSchema is stored as
+// an empty object.
+func (s SpecSchema) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("{}"), nil
+	}
+
+	return json.Marshal(s)
+}
+
+// scanJSONB decodes a jsonb column into dst.
+func scanJSONB(src interface{}, dst interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, dst)
+	case string:
+		return json.Unmarshal([]byte(src), dst)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dst)
+	}
+}
+
+// SpecFilter filters guns on the value of a spec. Value is compared as text for SpecEq and as a
+// number for the range operators.
+type SpecFilter struct {
+	Key      string
+	Operator string
+	Value    string
+}
+
+// ParseSpecFilter parses a query string parameter such as caliber=9mm or capacity_gte=15 into a
+// SpecFilter.
+func ParseSpecFilter(param, value string) SpecFilter {
+	for _, operator := range []string{SpecGte, SpecLte, SpecGt, SpecLt} {
+		if key, ok := strings.CutSuffix(param, "_"+operator); ok {
+			return SpecFilter{Key: key, Operator: operator, Value: value}
+		}
+	}
+
+	return SpecFilter{Key: param, Operator: SpecEq, Value: value}
+}
+
+// condition returns the SQL condition for the filter, using $key and $value as the
+// placeholders of its key and value. Range comparisons only consider guns whose spec is a
+// number, so that a text value under the same key never makes the cast fail.
+func (f SpecFilter) condition(key, value int) string {
+	if f.Operator == SpecEq {
+		return fmt.Sprintf("guns.specs ->> $%d = $%d", key, value)
+	}
+
+	return fmt.Sprintf(
+		"CASE WHEN jsonb_typeof(guns.specs -> $%d) = 'number' THEN (guns.specs ->> $%d)::numeric END %s $%d::numeric",
+		key, key, specOperators[f.Operator], value)
+}
+
+// ValidateSpecSchema checks the keys and value types of a category's spec schema.
+func ValidateSpecSchema(v *validator.Validator, schema SpecSchema) {
+	v.Check(len(schema) <= 50, "spec_schema", "must not have more than 50 keys")
+
+	for key, kind := range schema {
+		v.Check(validator.Matches(key, SpecKeyRX), "spec_schema."+key, "must be lowercase letters, digits and underscores")
+		v.Check(len(key) <= 50, "spec_schema."+key, "must not be more than 50 bytes long")
+		v.Check(validator.In(kind, SpecString, SpecNumber, SpecInteger, SpecBoolean), "spec_schema."+key,
+			"must be string, number, integer or boolean")
+	}
+}
+
+// ValidateSpecs checks the specs of a gun against the spec schema of its category. Guns without
+// a category have an empty schema, and so can't have any specs.
+func ValidateSpecs(v *validator.Validator, specs Specs, schema SpecSchema) {
+	// Check the keys in a stable order, so that the same input always produces the same errors.
+	keys := make([]string, 0, len(specs))
+	for key := range specs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := "specs." + key
+
+		kind, ok := schema[key]
+		if !ok {
+			v.AddError(field, "is not a spec of the gun's category")
+			continue
+		}
+
+		switch value := specs[key].(type) {
+		case string:
+			v.Check(kind == SpecString, field, "must be of type "+kind)
+			v.Check(len(value) <= 200, field, "must not be more than 200 bytes long")
+		case float64:
+			v.Check(kind == SpecNumber || kind == SpecInteger, field, "must be of type "+kind)
+			if kind == SpecInteger {
+				v.Check(value == math.Trunc(value), field, "must be an integer")
+			}
+		case bool:
+			v.Check(kind == SpecBoolean, field, "must be of type "+kind)
+		default:
+			v.AddError(field, "must be of type "+kind)
+		}
+	}
+}
+
+// ValidateSpecFilter checks a spec filter read from the query string.
+func ValidateSpecFilter(v *validator.Validator, param string, filter SpecFilter, specKeys map[string]bool) {
+	v.Check(validator.Matches(filter.Key, SpecKeyRX) && specKeys[filter.Key], param,
+		"is not a valid filter or a spec key of any category")
+
+	if filter.Operator != SpecEq {
+		_, err := strconv.ParseFloat(filter.Value, 64)
+		v.Check(err == nil, param, "must be a number")
+	}
+}
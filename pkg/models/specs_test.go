@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+func TestValidateSpecFilter(t *testing.T) {
+	specKeys := map[string]bool{"caliber": true, "capacity": true}
+
+	tests := []struct {
+		param string
+		value string
+		valid bool
+	}{
+		{param: "caliber", value: "9mm", valid: true},
+		{param: "capacity_gte", value: "15", valid: true},
+		{param: "capacity_gte", value: "many", valid: false},
+		{param: "pag", value: "2", valid: false},
+		{param: "barrel_lt", value: "5", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.param+"="+tt.value, func(t *testing.T) {
+			v := validator.New()
+			ValidateSpecFilter(v, tt.param, ParseSpecFilter(tt.param, tt.value), specKeys)
+
+			if v.Valid() != tt.valid {
+				t.Errorf("valid = %t, want %t (errors %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// The kinds of stock movement recorded in the ledger. Receipts and returns add stock, sales
+// remove it, and adjustments can go either way.
+const (
+	MovementReceive = "receive"
+	MovementSell    = "sell"
+	MovementAdjust  = "adjust"
+	MovementReturn  = "return"
+)
+
+type (
+	// StockMovement represents a single entry in the append-only stock_movements ledger.
+	// Quantity is the signed change in stock on hand that the movement causes. Receipts against a
+	// purchase order also record the order and the cost price of each item.
+	StockMovement struct {
+		ID              int64     `json:"id"`
+		GunID           int64     `json:"gun_id"`
+		Kind            string    `json:"kind"`
+		Quantity        int       `json:"quantity"`
+		Reason          string    `json:"reason"`
+		UserID          *int64    `json:"user_id"`
+		SaleID          *int64    `json:"sale_id,omitempty"`
+		PurchaseOrderID *int64    `json:"purchase_order_id,omitempty"`
+		UnitCost        *Money    `json:"unit_cost,omitempty"`
+		CreatedAt       time.Time `json:"created_at"`
+	}
+
+	// StockLevel holds the current stock of a gun. QuantityAvailable is the stock on hand that
+	// isn't held by reservations. When it drops below ReorderPoint a reorder alert is raised for
+	// ReorderQuantity more; both are nil for guns that aren't reordered.
+	StockLevel struct {
+		GunID             int64 `json:"gun_id"`
+		QuantityOnHand    int   `json:"quantity_on_hand"`
+		QuantityReserved  int   `json:"quantity_reserved"`
+		QuantityAvailable int   `json:"quantity_available"`
+		ReorderPoint      *int  `json:"reorder_point"`
+		ReorderQuantity   *int  `json:"reorder_quantity"`
+	}
+
+	// StockModel struct wraps a sql.DB connection pool and allows us to work with the
+	// stock_movements ledger.
+	StockModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+// Insert appends a movement to the ledger. The quantity on hand of the gun is updated by a
+// trigger in the same statement, so if the movement would take it below zero, or below the stock
+// held by reservations, we get an ErrInsufficientStock error, and if the gun doesn't exist an ErrRecordNotFound error.
+func (m StockModel) Insert(ctx context.Context, movement *StockMovement) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return insertStockMovement(ctx, m.DB, movement)
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx, so that helpers which write to several
+// tables can be used on their own or as part of a larger transaction.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertStockMovement appends a movement to the ledger using q.
+func insertStockMovement(ctx context.Context, q queryer, movement *StockMovement) error {
+	query := `
+		INSERT INTO stock_movements (gun_id, kind, quantity, reason, user_id, sale_id,
+			purchase_order_id, unit_cost)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+		`
+
+	args := []interface{}{
+		movement.GunID,
+		movement.Kind,
+		movement.Quantity,
+		movement.Reason,
+		movement.UserID,
+		movement.SaleID,
+		movement.PurchaseOrderID,
+		movement.UnitCost,
+	}
+
+	err := q.QueryRowContext(ctx, query, args...).Scan(&movement.ID, &movement.CreatedAt)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "guns_quantity_on_hand_check"),
+			violatesConstraint(err, "guns_quantity_available_check"):
+			return ErrInsufficientStock
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+const stockLevelColumns = `id, quantity_on_hand, quantity_reserved, quantity_on_hand - quantity_reserved,
+	reorder_point, reorder_quantity`
+
+func scanStockLevel(row rowScanner, level *StockLevel) error {
+	return row.Scan(
+		&level.GunID,
+		&level.QuantityOnHand,
+		&level.QuantityReserved,
+		&level.QuantityAvailable,
+		&level.ReorderPoint,
+		&level.ReorderQuantity,
+	)
+}
+
+// GetLevel returns the current stock of a specific gun.
+func (m StockModel) GetLevel(ctx context.Context, gunID int64) (*StockLevel, error) {
+	query := `
+		SELECT ` + stockLevelColumns + `
+		FROM guns
+		WHERE id = $1
+		`
+
+	var level StockLevel
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanStockLevel(m.DB.QueryRowContext(ctx, query, gunID), &level)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &level, nil
+}
+
+// SetReorderLevel sets the reorder point and quantity of a gun, or clears them when both are
+// nil, and returns its stock. Guns in the trash can't be changed.
+func (m StockModel) SetReorderLevel(ctx context.Context, gunID int64, point, quantity *int) (*StockLevel, error) {
+	query := `
+		UPDATE guns
+		SET reorder_point = $2, reorder_quantity = $3
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING ` + stockLevelColumns
+
+	var level StockLevel
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanStockLevel(m.DB.QueryRowContext(ctx, query, gunID, point, quantity), &level)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &level, nil
+}
+
+// GetAllForGun returns a page of the ledger entries of a specific gun, along with the total
+// number of entries.
+func (m StockModel) GetAllForGun(ctx context.Context, gunID int64, filters Filters) ([]*StockMovement, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, gun_id, kind, quantity, reason, user_id, sale_id,
+			purchase_order_id, unit_cost, created_at
+		FROM stock_movements
+		WHERE gun_id = $1
+		ORDER BY %s %s
+		LIMIT $2 OFFSET $3
+		`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, gunID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	movements := []*StockMovement{}
+
+	for rows.Next() {
+		var movement StockMovement
+
+		err := rows.Scan(
+			&totalRecords,
+			&movement.ID,
+			&movement.GunID,
+			&movement.Kind,
+			&movement.Quantity,
+			&movement.Reason,
+			&movement.UserID,
+			&movement.SaleID,
+			&movement.PurchaseOrderID,
+			&movement.UnitCost,
+			&movement.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		movements = append(movements, &movement)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return movements, totalRecords, nil
+}
+
+// ValidateStockMovement checks the kind and reason of a movement, and that the sign of the
+// quantity agrees with its kind.
+func ValidateStockMovement(v *validator.Validator, movement *StockMovement) {
+	v.Check(validator.In(movement.Kind, MovementReceive, MovementSell, MovementAdjust, MovementReturn),
+		"kind", "must be one of receive, sell, adjust or return")
+
+	switch movement.Kind {
+	case MovementReceive, MovementReturn:
+		v.Check(movement.Quantity > 0, "quantity", "must be greater than zero")
+	case MovementSell:
+		v.Check(movement.Quantity < 0, "quantity", "must remove stock")
+	default:
+		v.Check(movement.Quantity != 0, "quantity", "must not be zero")
+	}
+
+	v.Check(movement.Reason != "", "reason", "must be provided")
+	v.Check(len(movement.Reason) <= 500, "reason", "must not be more than 500 bytes long")
+}
+
+// ValidateReorderLevel checks a reorder point and quantity, which are either both set or both
+// nil.
+func ValidateReorderLevel(v *validator.Validator, point, quantity *int) {
+	v.Check((point == nil) == (quantity == nil), "reorder_quantity", "must be given with reorder_point")
+
+	if point != nil {
+		v.Check(*point >= 0, "reorder_point", "must not be negative")
+		v.Check(*point <= 100000, "reorder_point", "must not be more than 100000")
+	}
+
+	if quantity != nil {
+		v.Check(*quantity > 0, "reorder_quantity", "must be greater than zero")
+		v.Check(*quantity <= 10000, "reorder_quantity", "must not be more than 10000")
+	}
+}
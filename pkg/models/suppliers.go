@@ -0,0 +1,257 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+var (
+	ErrSupplierNotFound = errors.New("supplier not found")
+)
+
+type (
+	// Supplier represents a distributor or manufacturer we buy stock from. The licence number is
+	// the supplier's dealer licence, which is recorded with every firearm we acquire from them.
+	Supplier struct {
+		ID            int64     `json:"id"`
+		Name          string    `json:"name"`
+		Email         string    `json:"email"`
+		Phone         string    `json:"phone"`
+		Address       string    `json:"address"`
+		LicenceNumber string    `json:"licence_number"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+		Version       int       `json:"version"`
+	}
+
+	// SupplierModel struct wraps a sql.DB connection pool and allows us to work with the
+	// Supplier struct type and the suppliers table in our database.
+	SupplierModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+// Insert inserts a new record in the suppliers table. Supplier names are unique, so we return
+// an ErrDuplicateName error if the name is already taken.
+func (m SupplierModel) Insert(ctx context.Context, supplier *Supplier) error {
+	query := `
+		INSERT INTO suppliers (name, email, phone, address, licence_number)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at, version
+		`
+
+	args := []interface{}{supplier.Name, supplier.Email, supplier.Phone, supplier.Address, supplier.LicenceNumber}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&supplier.ID,
+		&supplier.CreatedAt,
+		&supplier.UpdatedAt,
+		&supplier.Version,
+	)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "suppliers_name_key"):
+			return ErrDuplicateName
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get retrieves a specific supplier by id.
+func (m SupplierModel) Get(ctx context.Context, id int64) (*Supplier, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, name, email, phone, address, licence_number, created_at, updated_at, version
+		FROM suppliers
+		WHERE id = $1
+		`
+
+	var supplier Supplier
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&supplier.ID,
+		&supplier.Name,
+		&supplier.Email,
+		&supplier.Phone,
+		&supplier.Address,
+		&supplier.LicenceNumber,
+		&supplier.CreatedAt,
+		&supplier.UpdatedAt,
+		&supplier.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &supplier, nil
+}
+
+// GetAll returns a page of suppliers along with the total number of suppliers.
+func (m SupplierModel) GetAll(ctx context.Context, filters Filters) ([]*Supplier, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, name, email, phone, address, licence_number, created_at,
+			updated_at, version
+		FROM suppliers
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2
+		`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	suppliers := []*Supplier{}
+
+	for rows.Next() {
+		var supplier Supplier
+
+		err := rows.Scan(
+			&totalRecords,
+			&supplier.ID,
+			&supplier.Name,
+			&supplier.Email,
+			&supplier.Phone,
+			&supplier.Address,
+			&supplier.LicenceNumber,
+			&supplier.CreatedAt,
+			&supplier.UpdatedAt,
+			&supplier.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		suppliers = append(suppliers, &supplier)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return suppliers, totalRecords, nil
+}
+
+// Update updates a specific supplier, checking against the version field to prevent lost
+// updates.
+func (m SupplierModel) Update(ctx context.Context, supplier *Supplier) error {
+	query := `
+		UPDATE suppliers
+		SET name = $1, email = $2, phone = $3, address = $4, licence_number = $5,
+			updated_at = NOW(), version = version + 1
+		WHERE id = $6 AND version = $7
+		RETURNING updated_at, version
+		`
+
+	args := []interface{}{
+		supplier.Name,
+		supplier.Email,
+		supplier.Phone,
+		supplier.Address,
+		supplier.LicenceNumber,
+		supplier.ID,
+		supplier.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&supplier.UpdatedAt, &supplier.Version)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "suppliers_name_key"):
+			return ErrDuplicateName
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a specific supplier. Suppliers with purchase orders can't be deleted, in which
+// case we return an ErrRecordInUse error.
+func (m SupplierModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM suppliers
+		WHERE id = $1
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err):
+			return ErrRecordInUse
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ValidateSupplier checks the fields of a supplier. Only the name is required.
+func ValidateSupplier(v *validator.Validator, supplier *Supplier) {
+	v.Check(supplier.Name != "", "name", "must be provided")
+	v.Check(len(supplier.Name) <= 200, "name", "must not be more than 200 bytes long")
+
+	if supplier.Email != "" {
+		ValidateEmail(v, supplier.Email)
+	}
+
+	v.Check(len(supplier.Phone) <= 50, "phone", "must not be more than 50 bytes long")
+	v.Check(len(supplier.Address) <= 1000, "address", "must not be more than 1000 bytes long")
+	v.Check(len(supplier.LicenceNumber) <= 50, "licence_number", "must not be more than 50 bytes long")
+}
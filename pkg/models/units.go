@@ -0,0 +1,620 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateSerial   = errors.New("duplicate serial number")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrUnitNotFound      = errors.New("unit not found")
+	ErrUnitUnavailable   = errors.New("unit not available")
+	ErrUnitRequired      = errors.New("serialized unit required")
+	ErrUnitHeld          = errors.New("unit held by an active reservation")
+)
+
+// The statuses in the lifecycle of a physical firearm. Units that are in stock, reserved or
+// returned count towards the stock on hand of their gun; sold and transferred units don't.
+const (
+	UnitInStock     = "in_stock"
+	UnitReserved    = "reserved"
+	UnitSold        = "sold"
+	UnitReturned    = "returned"
+	UnitTransferred = "transferred"
+)
+
+// unitTransitions lists the statuses that a unit may move to from each status. A sold unit goes
+// straight back in stock when its sale is denied or cancelled before pickup.
+var unitTransitions = map[string][]string{
+	UnitInStock:     {UnitReserved, UnitSold, UnitTransferred},
+	UnitReserved:    {UnitInStock, UnitSold},
+	UnitSold:        {UnitReturned, UnitInStock},
+	UnitReturned:    {UnitInStock, UnitTransferred},
+	UnitTransferred: {},
+}
+
+// manualUnitStatuses are the statuses that can be set directly through UpdateStatus. Units only
+// become reserved through a reservation, sold through a sale, and returned through a return.
+var manualUnitStatuses = []string{UnitInStock, UnitTransferred}
+
+type (
+	// GunUnit represents a single physical firearm of a catalog gun, identified by its
+	// manufacturer and serial number. Units received against a purchase order keep the order
+	// and what we paid for them.
+	GunUnit struct {
+		ID              int64        `json:"id"`
+		GunID           int64        `json:"gun_id"`
+		Manufacturer    string       `json:"manufacturer"`
+		SerialNumber    string       `json:"serial_number"`
+		Status          string       `json:"status"`
+		PurchaseOrderID *int64       `json:"purchase_order_id,omitempty"`
+		UnitCost        *Money       `json:"unit_cost,omitempty"`
+		CreatedAt       time.Time    `json:"created_at"`
+		UpdatedAt       time.Time    `json:"updated_at"`
+		Version         int          `json:"version"`
+		History         []*UnitEvent `json:"history,omitempty"`
+	}
+
+	// UnitEvent records a unit entering a status. The events of a unit are its full history.
+	UnitEvent struct {
+		ID        int64     `json:"id"`
+		UnitID    int64     `json:"unit_id"`
+		Status    string    `json:"status"`
+		Note      string    `json:"note"`
+		UserID    *int64    `json:"user_id"`
+		SaleID    *int64    `json:"sale_id,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// UnitModel struct wraps a sql.DB connection pool and allows us to work with the gun_units
+	// and gun_unit_events tables.
+	UnitModel struct {
+		DB       *sql.DB
+		InfoLog  *log.Logger
+		ErrorLog *log.Logger
+	}
+)
+
+// NormalizeSerial trims a serial number and converts it to upper case, so that the same serial
+// typed in different ways matches.
+func NormalizeSerial(serial string) string {
+	return strings.ToUpper(strings.TrimSpace(serial))
+}
+
+// canTransitionUnit reports whether a unit may move from one status to another.
+func canTransitionUnit(from, to string) bool {
+	return validator.In(to, unitTransitions[from]...)
+}
+
+const unitColumns = `gun_units.id, gun_units.gun_id, gun_units.manufacturer,
+	gun_units.serial_number, gun_units.status, gun_units.purchase_order_id, gun_units.unit_cost,
+	gun_units.created_at, gun_units.updated_at, gun_units.version`
+
+func scanUnit(row rowScanner, unit *GunUnit) error {
+	return row.Scan(
+		&unit.ID,
+		&unit.GunID,
+		&unit.Manufacturer,
+		&unit.SerialNumber,
+		&unit.Status,
+		&unit.PurchaseOrderID,
+		&unit.UnitCost,
+		&unit.CreatedAt,
+		&unit.UpdatedAt,
+		&unit.Version,
+	)
+}
+
+// Receive records the receipt of a new unit from transferor: the unit is created in stock, its
+// first history event is written, and the stock of its gun goes up by one, all in a single
+// transaction.
+func (m UnitModel) Receive(ctx context.Context, unit *GunUnit, transferor *BoundBookParty, userID *int64, note string) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = receiveUnit(ctx, tx, unit, transferor, userID, note)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// receiveUnit records the receipt of a new unit as part of the transaction tx. The purchase order
+// and cost of the unit, if set, are carried over to its stock movement, and the acquisition is
+// written to the bound book, from the supplier of the purchase order if there is one and from
+// transferor otherwise. It returns an ErrPartyRequired error if a unit without a purchase order
+// has no complete transferor, an ErrDuplicateSerial error if the manufacturer already has a unit
+// with the same serial number, and an ErrRecordNotFound error if the gun doesn't exist.
+func receiveUnit(ctx context.Context, tx *sql.Tx, unit *GunUnit, transferor *BoundBookParty, userID *int64, note string) error {
+	if unit.PurchaseOrderID == nil && !transferor.complete() {
+		return ErrPartyRequired
+	}
+
+	query := `
+		INSERT INTO gun_units (gun_id, manufacturer, serial_number, purchase_order_id, unit_cost)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at, updated_at, version
+		`
+
+	args := []interface{}{unit.GunID, unit.Manufacturer, unit.SerialNumber, unit.PurchaseOrderID, unit.UnitCost}
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(
+		&unit.ID,
+		&unit.Status,
+		&unit.CreatedAt,
+		&unit.UpdatedAt,
+		&unit.Version,
+	)
+	if err != nil {
+		switch {
+		case violatesConstraint(err, "gun_units_manufacturer_serial_number_key"):
+			return ErrDuplicateSerial
+		case isForeignKeyViolation(err):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if note == "" {
+		note = "received"
+	}
+
+	event := &UnitEvent{UnitID: unit.ID, Status: unit.Status, Note: note, UserID: userID}
+
+	err = insertUnitEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	unit.History = []*UnitEvent{event}
+
+	movement := &StockMovement{
+		GunID:           unit.GunID,
+		Kind:            MovementReceive,
+		Quantity:        1,
+		Reason:          fmt.Sprintf("unit %s %s received", unit.Manufacturer, unit.SerialNumber),
+		UserID:          userID,
+		PurchaseOrderID: unit.PurchaseOrderID,
+		UnitCost:        unit.UnitCost,
+	}
+
+	err = insertStockMovement(ctx, tx, movement)
+	if err != nil {
+		return err
+	}
+
+	entry := &BoundBookEntry{
+		UnitID:          unit.ID,
+		Kind:            BoundBookAcquisition,
+		Source:          BoundBookReceipt,
+		PurchaseOrderID: unit.PurchaseOrderID,
+		Note:            note,
+		UserID:          userID,
+	}
+
+	if unit.PurchaseOrderID != nil {
+		err = setBoundBookParty(ctx, tx, entry, supplierPartyQuery, *unit.PurchaseOrderID)
+		if err != nil {
+			return err
+		}
+	} else {
+		transferor.setParty(entry)
+	}
+
+	return insertBoundBookEntry(ctx, tx, entry)
+}
+
+// insertUnitEvent appends an event to the history of a unit.
+func insertUnitEvent(ctx context.Context, q queryer, event *UnitEvent) error {
+	query := `
+		INSERT INTO gun_unit_events (unit_id, status, note, user_id, sale_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+		`
+
+	args := []interface{}{event.UnitID, event.Status, event.Note, event.UserID, event.SaleID}
+
+	return q.QueryRowContext(ctx, query, args...).Scan(&event.ID, &event.CreatedAt)
+}
+
+// lockUnits reads the units with the provided ids, locking their rows until the end of the
+// transaction. Units are locked in id order so that concurrent transactions can't deadlock.
+func lockUnits(ctx context.Context, tx *sql.Tx, ids []int64) (map[int64]*GunUnit, error) {
+	query := `
+		SELECT ` + unitColumns + `
+		FROM gun_units
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+		`
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := make(map[int64]*GunUnit, len(ids))
+
+	for rows.Next() {
+		var unit GunUnit
+
+		err := scanUnit(rows, &unit)
+		if err != nil {
+			return nil, err
+		}
+
+		units[unit.ID] = &unit
+	}
+
+	return units, rows.Err()
+}
+
+// setUnitStatus moves a locked unit to a new status and records the event in its history. It
+// returns an ErrInvalidTransition error if the unit can't move to that status.
+func setUnitStatus(ctx context.Context, q queryer, unit *GunUnit, event *UnitEvent) error {
+	if !canTransitionUnit(unit.Status, event.Status) {
+		return ErrInvalidTransition
+	}
+
+	query := `
+		UPDATE gun_units
+		SET status = $1, updated_at = NOW(), version = version + 1
+		WHERE id = $2
+		RETURNING updated_at, version
+		`
+
+	err := q.QueryRowContext(ctx, query, event.Status, unit.ID).Scan(&unit.UpdatedAt, &unit.Version)
+	if err != nil {
+		return err
+	}
+
+	unit.Status = event.Status
+	event.UnitID = unit.ID
+
+	return insertUnitEvent(ctx, q, event)
+}
+
+// UpdateStatus moves a unit to one of the statuses that can be set by hand: putting it back in
+// stock, or transferring it out of the shop. Transferring a unit takes it out of stock, and
+// writes a disposition to transferee to the bound book; a transfer without a complete
+// transferee results in an ErrPartyRequired error. The status must be a valid transition from
+// the current one, otherwise an ErrInvalidTransition error is returned. Units held by an active
+// reservation can't be changed by hand, which results in an ErrUnitHeld error; the reservation
+// has to be cancelled or converted into a sale instead.
+func (m UnitModel) UpdateStatus(ctx context.Context, id int64, status, note string, transferee *BoundBookParty, userID *int64) (*GunUnit, error) {
+	if !validator.In(status, manualUnitStatuses...) {
+		return nil, ErrInvalidTransition
+	}
+
+	if status == UnitTransferred && !transferee.complete() {
+		return nil, ErrPartyRequired
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	units, err := lockUnits(ctx, tx, []int64{id})
+	if err != nil {
+		return nil, err
+	}
+
+	unit, ok := units[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	// Sold units only come back through a return, or when their sale is denied or cancelled.
+	if unit.Status == UnitSold {
+		return nil, ErrInvalidTransition
+	}
+
+	// Reservations lock their unit before holding it, so with the unit locked this can't change
+	// until the transaction ends.
+	held, err := unitHeld(ctx, tx, unit.ID)
+	if err != nil {
+		return nil, err
+	}
+	if held {
+		return nil, ErrUnitHeld
+	}
+
+	err = setUnitStatus(ctx, tx, unit, &UnitEvent{Status: status, Note: note, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	if status == UnitTransferred {
+		movement := &StockMovement{
+			GunID:    unit.GunID,
+			Kind:     MovementAdjust,
+			Quantity: -1,
+			Reason:   fmt.Sprintf("unit %s %s transferred", unit.Manufacturer, unit.SerialNumber),
+			UserID:   userID,
+		}
+
+		err = insertStockMovement(ctx, tx, movement)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &BoundBookEntry{
+			UnitID: unit.ID,
+			Kind:   BoundBookDisposition,
+			Source: BoundBookTransfer,
+			Note:   note,
+			UserID: userID,
+		}
+
+		transferee.setParty(entry)
+
+		err = insertBoundBookEntry(ctx, tx, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return unit, nil
+}
+
+// unitHeld reports whether a unit is held by an active reservation.
+func unitHeld(ctx context.Context, q queryer, unitID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM reservations
+			WHERE unit_id = $1 AND status = 'active'
+		)
+		`
+
+	var held bool
+
+	err := q.QueryRowContext(ctx, query, unitID).Scan(&held)
+
+	return held, err
+}
+
+// Get retrieves a specific unit, including its full history.
+func (m UnitModel) Get(ctx context.Context, id int64) (*GunUnit, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + unitColumns + `
+		FROM gun_units
+		WHERE id = $1
+		`
+
+	var unit GunUnit
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := scanUnit(m.DB.QueryRowContext(ctx, query, id), &unit)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = m.loadHistory(ctx, []*GunUnit{&unit})
+	if err != nil {
+		return nil, err
+	}
+
+	return &unit, nil
+}
+
+// GetBySerial returns the units with the provided serial number, including their full
+// history. Serial numbers are only unique per manufacturer, so if manufacturer is empty this
+// may return units of several manufacturers.
+func (m UnitModel) GetBySerial(ctx context.Context, serial, manufacturer string) ([]*GunUnit, error) {
+	query := `
+		SELECT ` + unitColumns + `
+		FROM gun_units
+		WHERE serial_number = $1 AND ($2 = '' OR manufacturer ILIKE $2)
+		ORDER BY id
+		`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, NormalizeSerial(serial), escapeLike(manufacturer))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	units := []*GunUnit{}
+
+	for rows.Next() {
+		var unit GunUnit
+
+		err := scanUnit(rows, &unit)
+		if err != nil {
+			return nil, err
+		}
+
+		units = append(units, &unit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.loadHistory(ctx, units)
+	if err != nil {
+		return nil, err
+	}
+
+	return units, nil
+}
+
+// GetAllForGun returns a page of the units of a specific gun, optionally limited to a status,
+// along with the total number of matching units.
+func (m UnitModel) GetAllForGun(ctx context.Context, gunID int64, status string, filters Filters) ([]*GunUnit, int, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM gun_units
+		WHERE gun_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY %s %s, id ASC
+		LIMIT $3 OFFSET $4
+		`, unitColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, gunID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	units := []*GunUnit{}
+
+	for rows.Next() {
+		var unit GunUnit
+
+		err := rows.Scan(
+			&totalRecords,
+			&unit.ID,
+			&unit.GunID,
+			&unit.Manufacturer,
+			&unit.SerialNumber,
+			&unit.Status,
+			&unit.PurchaseOrderID,
+			&unit.UnitCost,
+			&unit.CreatedAt,
+			&unit.UpdatedAt,
+			&unit.Version,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		units = append(units, &unit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return units, totalRecords, nil
+}
+
+// loadHistory fetches the events of the provided units with a single query.
+func (m UnitModel) loadHistory(ctx context.Context, units []*GunUnit) error {
+	if len(units) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*GunUnit, len(units))
+	ids := make([]int64, len(units))
+
+	for i, unit := range units {
+		unit.History = []*UnitEvent{}
+		byID[unit.ID] = unit
+		ids[i] = unit.ID
+	}
+
+	query := `
+		SELECT id, unit_id, status, note, user_id, sale_id, created_at
+		FROM gun_unit_events
+		WHERE unit_id = ANY($1)
+		ORDER BY id
+		`
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	for rows.Next() {
+		var event UnitEvent
+
+		err := rows.Scan(
+			&event.ID,
+			&event.UnitID,
+			&event.Status,
+			&event.Note,
+			&event.UserID,
+			&event.SaleID,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		unit := byID[event.UnitID]
+		unit.History = append(unit.History, &event)
+	}
+
+	return rows.Err()
+}
+
+// ValidateGunUnit checks the identifying fields of a new unit.
+func ValidateGunUnit(v *validator.Validator, unit *GunUnit) {
+	v.Check(unit.Manufacturer != "", "manufacturer", "must be provided")
+	v.Check(len(unit.Manufacturer) <= 200, "manufacturer", "must not be more than 200 bytes long")
+
+	v.Check(unit.SerialNumber != "", "serial_number", "must be provided")
+	v.Check(len(unit.SerialNumber) <= 100, "serial_number", "must not be more than 100 bytes long")
+}
+
+// ValidateUnitStatus checks a status requested through UpdateStatus. Transfers need the name,
+// address and licence number of the transferee.
+func ValidateUnitStatus(v *validator.Validator, status, note string, transferee *BoundBookParty) {
+	v.Check(validator.In(status, manualUnitStatuses...), "status",
+		"must be one of "+strings.Join(manualUnitStatuses, ", "))
+	v.Check(len(note) <= 500, "note", "must not be more than 500 bytes long")
+
+	if status == UnitTransferred {
+		ValidateBoundBookParty(v, "transferee", transferee)
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+func TestValidateUnitStatus(t *testing.T) {
+	party := &BoundBookParty{Name: "Range Supply LLC", Address: "1 Main St", Licence: "1-23-456"}
+
+	tests := []struct {
+		name       string
+		status     string
+		transferee *BoundBookParty
+		wantErrors []string
+	}{
+		{name: "back in stock", status: UnitInStock, transferee: &BoundBookParty{}},
+		{name: "reserved by hand", status: UnitReserved, transferee: &BoundBookParty{}, wantErrors: []string{"status"}},
+		{name: "transfer", status: UnitTransferred, transferee: party},
+		{name: "transfer without transferee", status: UnitTransferred, transferee: &BoundBookParty{},
+			wantErrors: []string{"transferee_name", "transferee_address", "transferee_licence"}},
+		{name: "transfer without licence", status: UnitTransferred,
+			transferee: &BoundBookParty{Name: party.Name, Address: party.Address},
+			wantErrors: []string{"transferee_licence"}},
+		{name: "sold by hand", status: UnitSold, transferee: &BoundBookParty{}, wantErrors: []string{"status"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateUnitStatus(v, tt.status, "", tt.transferee)
+
+			if len(v.Errors) != len(tt.wantErrors) {
+				t.Errorf("errors = %v, want %v", v.Errors, tt.wantErrors)
+			}
+			for _, key := range tt.wantErrors {
+				if _, ok := v.Errors[key]; !ok {
+					t.Errorf("no error for %q in %v", key, v.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestBoundBookPartyComplete(t *testing.T) {
+	tests := []struct {
+		party *BoundBookParty
+		want  bool
+	}{
+		{party: nil, want: false},
+		{party: &BoundBookParty{}, want: false},
+		{party: &BoundBookParty{Name: "A", Address: "B"}, want: false},
+		{party: &BoundBookParty{Name: "A", Licence: "C"}, want: false},
+		{party: &BoundBookParty{Name: "A", Address: "B", Licence: "C"}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.party.complete(); got != tt.want {
+			t.Errorf("%+v.complete() = %t, want %t", tt.party, got, tt.want)
+		}
+	}
+}
@@ -1,284 +1,284 @@
-package models
-
-import (
-	"context"
-	"crypto/sha256"
-	"database/sql"
-	"errors"
-	"log"
-	"time"
-
-	"github.com/E4kere/Project/pkg/validator"
-	"golang.org/x/crypto/bcrypt"
-)
-
-var (
-	ErrDuplicateEmail = errors.New("duplicate email")
-)
-
-var AnonymousUser = &User{}
-
-// User type whose fields describe a user. Note, that we use the json:"-" struct tag to prevent
-// the Password and Version fields from appearing in any output when we encode it to JSON.
-// Also, notice that the Password field uses the custom password type defined below.
-type User struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  password  `json:"-"`
-	Activated bool      `json:"activated"`
-	Version   int       `json:"-"`
-}
-
-func (u *User) IsAnonymous() bool {
-	return u == AnonymousUser
-}
-
-// UserModel struct wraps a sql.DB connection pool and allows us to work with the User struct type
-// and the users table in our database.
-type UserModel struct {
-	DB       *sql.DB
-	InfoLog  *log.Logger
-	ErrorLog *log.Logger
-}
-
-// password tyep is a struct containing the plaintext and hashed version of a password for a User.
-// The plaintext field is a *pointer* to a string, so that we're able to distinguish between a
-// plaintext password not being present in the struct at all, versus a plaintext password which
-// is the empty string "".
-type password struct {
-	plaintext *string
-	hash      []byte
-}
-
-// Set calculates the bcrypt hash of a plaintext password, and stores both the has and the
-// plaintext versions in the password struct.
-func (p *password) Set(plaintextPassword string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
-	if err != nil {
-		return err
-	}
-
-	p.plaintext = &plaintextPassword
-	p.hash = hash
-	return nil
-}
-
-// Matches checks whether the provided plaintext password matches the hashed password stored in
-// the password struct, returning true if it matches and false otherwise.
-func (p *password) Matches(plaintextPassword string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
-	if err != nil {
-		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			return false, nil
-		default:
-			return false, err
-		}
-	}
-
-	return true, nil
-}
-
-// Insert inserts a new record in the users table in our database for the user. Note, that the id,
-// created_at, and version fields are all automatically generated by our database, so we use use
-// the RETURNING clause to read them into the User struct after the insert. Also, we check
-// if our table already contains the same email address and if so return ErrDuplicateEmail error.
-func (m UserModel) Insert(user *User) error {
-	query := `
-		INSERT INTO users (name, email, password_hash, activated)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, version
-		`
-
-	args := []interface{}{user.Name, user.Email, user.Password.hash, user.Activated}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	// If the table already contains a record with this email address, then when we try to
-	// perform the insert there will be a violation of the UNIQUE "users_email_key" constraint
-	// that we set up in the previous chapter. We check for this error specifically, and return
-	// ErrDuplicateEmail error instead.
-	pqErr := `pq: duplicate key value violates unique constraint "users_email_key"`
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
-	if err != nil {
-		switch {
-		case err.Error() == pqErr:
-			return ErrDuplicateEmail
-		default:
-			return err
-		}
-	}
-
-	return nil
-}
-
-// GetByEmail retrieves the User details from the database based on the user's email address.
-// Because we have a UNIQUE constraint on the email column, this query will only return one record,
-// or none at all, upon which we return a ErrRecordNotFound error).
-func (m UserModel) GetByEmail(email string) (*User, error) {
-	query := `
-		SELECT id, created_at, name, email, password_hash, activated, version
-		FROM users
-		WHERE email = $1
-		`
-
-	var user User
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	err := m.DB.QueryRowContext(ctx, query, email).Scan(
-		&user.ID,
-		&user.CreatedAt,
-		&user.Name,
-		&user.Email,
-		&user.Password.hash,
-		&user.Activated,
-		&user.Version,
-	)
-
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrRecordNotFound
-		default:
-			return nil, err
-		}
-	}
-
-	return &user, nil
-}
-
-// Update updates the details for a specific user in the users table. Note, we check against the
-// version field to help prevent any race conditions during the request cycle. Also, we check
-// for a violation of the "user_email_key" constraint.
-func (m UserModel) Update(user *User) error {
-	query := `
-		UPDATE users
-		SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
-		WHERE id = $5 AND version = $6
-		RETURNING version
-		`
-
-	args := []interface{}{
-		user.Name,
-		user.Email,
-		user.Password.hash,
-		user.Activated,
-		user.ID,
-		user.Version,
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
-	if err != nil {
-		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
-			return ErrDuplicateEmail
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrEditConflict
-		default:
-			return err
-		}
-	}
-
-	return nil
-}
-
-// GetForToken retrieves a user record from the users table for an associated token and token scope.
-func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
-	// Calculate the SHA-256 hash for the plaintext token provided by the client.
-	// Note, that this will return a byte *array* with length 32, not a slice.
-	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
-
-	query := `
-		SELECT 
-			users.id, users.created_at, users.name, users.email, 
-			users.password_hash, users.activated, users.version
-		FROM       users
-        INNER JOIN tokens
-			ON users.id = tokens.user_id
-        WHERE tokens.hash = $1  --<-- Note: this is potentially vulnerable to a timing attack, 
-            -- but if successful the attacker would only be able to retrieve a *hashed* token 
-            -- which would still require a brute-force attack to find the 26 character string
-            -- that has the same SHA-256 hash that was found from our database. 
-			AND tokens.scope = $2
-			AND tokens.expiry > $3
-		`
-
-	// Create a slice containing the query args. Note, that we use the [:] operator to get a slice
-	// containing the token hash, since the pq driver does not support passing in an array.
-	// Also, we pass the current time as the value to check against the token expiry.
-	args := []interface{}{tokenHash[:], tokenScope, time.Now()}
-
-	var user User
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	// Execute the query, scanning the return values into a User struct. If no matching record
-	// is found we return an ErrRecordNotFound error.
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
-		&user.ID,
-		&user.CreatedAt,
-		&user.Name,
-		&user.Email,
-		&user.Password.hash,
-		&user.Activated,
-		&user.Version,
-	)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrRecordNotFound
-		default:
-			return nil, err
-		}
-	}
-
-	// Return the matching user.
-	return &user, nil
-}
-
-// ValidateEmail checks that the Email field is not an empty string and that it matches the regex
-// for email addresses, validator.EmailRX.
-func ValidateEmail(v *validator.Validator, email string) {
-	v.Check(email != "", "email", "must be provided")
-	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be valid email address")
-}
-
-// ValidatePasswordPlaintext validtes that the password is not an empty string and is between 8 and
-// 72 bytes long.
-func ValidatePasswordPlaintext(v *validator.Validator, password string) {
-	v.Check(password != "", "password", "must be provided")
-	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
-	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
-}
-
-func ValidateUser(v *validator.Validator, user *User) {
-	// validate user.Name
-	v.Check(user.Name != "", "name", "must be provided")
-	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
-
-	// Validate email
-	ValidateEmail(v, user.Email)
-
-	// If the plaintext password is not nil, call the standalone ValidatePasswordPlaintext helper.
-	if user.Password.plaintext != nil {
-		ValidatePasswordPlaintext(v, *user.Password.plaintext)
-	}
-
-	// If the password has is ever nil, this will be due to a logic error in our codebase
-	// (probably because we forgot to set a password for the user). It's a useful sanity check to
-	// include here, but it's not a problem with the data provided by the client. So, rather
-	// than adding an error to the validation map we raise a panic instead.
-	if user.Password.hash == nil {
-		// TODO: fix this panic
-		panic("missing password hash for user")
-	}
-}
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/E4kere/Project/pkg/validator"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrDuplicateEmail = errors.New("duplicate email")
+)
+
+var AnonymousUser = &User{}
+
+// User type whose fields describe a user. Note, that we use the json:"-" struct tag to prevent
+// the Password and Version fields from appearing in any output when we encode it to JSON.
+// Also, notice that the Password field uses the custom password type defined below.
+type User struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Password  password  `json:"-"`
+	Activated bool      `json:"activated"`
+	Version   int       `json:"-"`
+}
+
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
+// UserModel struct wraps a sql.DB connection pool and allows us to work with the User struct type
+// and the users table in our database.
+type UserModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// password tyep is a struct containing the plaintext and hashed version of a password for a User.
+// The plaintext field is a *pointer* to a string, so that we're able to distinguish between a
+// plaintext password not being present in the struct at all, versus a plaintext password which
+// is the empty string "".
+type password struct {
+	plaintext *string
+	hash      []byte
+}
+
+// Set calculates the bcrypt hash of a plaintext password, and stores both the has and the
+// plaintext versions in the password struct.
+func (p *password) Set(plaintextPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	p.plaintext = &plaintextPassword
+	p.hash = hash
+	return nil
+}
+
+// Matches checks whether the provided plaintext password matches the hashed password stored in
+// the password struct, returning true if it matches and false otherwise.
+func (p *password) Matches(plaintextPassword string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Insert inserts a new record in the users table in our database for the user. Note, that the id,
+// created_at, and version fields are all automatically generated by our database, so we use use
+// the RETURNING clause to read them into the User struct after the insert. Also, we check
+// if our table already contains the same email address and if so return ErrDuplicateEmail error.
+func (m UserModel) Insert(user *User) error {
+	query := `
+		INSERT INTO users (name, email, password_hash, activated)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version
+		`
+
+	args := []interface{}{user.Name, user.Email, user.Password.hash, user.Activated}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// If the table already contains a record with this email address, then when we try to
+	// perform the insert there will be a violation of the UNIQUE "users_email_key" constraint
+	// that we set up in the previous chapter. We check for this error specifically, and return
+	// ErrDuplicateEmail error instead.
+	pqErr := `pq: duplicate key value violates unique constraint "users_email_key"`
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	if err != nil {
+		switch {
+		case err.Error() == pqErr:
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetByEmail retrieves the User details from the database based on the user's email address.
+// Because we have a UNIQUE constraint on the email column, this query will only return one record,
+// or none at all, upon which we return a ErrRecordNotFound error).
+func (m UserModel) GetByEmail(email string) (*User, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, version
+		FROM users
+		WHERE email = $1
+		`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// Update updates the details for a specific user in the users table. Note, we check against the
+// version field to help prevent any race conditions during the request cycle. Also, we check
+// for a violation of the "user_email_key" constraint.
+func (m UserModel) Update(user *User) error {
+	query := `
+		UPDATE users
+		SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING version
+		`
+
+	args := []interface{}{
+		user.Name,
+		user.Email,
+		user.Password.hash,
+		user.Activated,
+		user.ID,
+		user.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetForToken retrieves a user record from the users table for an associated token and token scope.
+func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	// Calculate the SHA-256 hash for the plaintext token provided by the client.
+	// Note, that this will return a byte *array* with length 32, not a slice.
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT 
+			users.id, users.created_at, users.name, users.email, 
+			users.password_hash, users.activated, users.version
+		FROM       users
+        INNER JOIN tokens
+			ON users.id = tokens.user_id
+        WHERE tokens.hash = $1  --<-- Note: this is potentially vulnerable to a timing attack, 
+            -- but if successful the attacker would only be able to retrieve a *hashed* token 
+            -- which would still require a brute-force attack to find the 26 character string
+            -- that has the same SHA-256 hash that was found from our database. 
+			AND tokens.scope = $2
+			AND tokens.expiry > $3
+		`
+
+	// Create a slice containing the query args. Note, that we use the [:] operator to get a slice
+	// containing the token hash, since the pq driver does not support passing in an array.
+	// Also, we pass the current time as the value to check against the token expiry.
+	args := []interface{}{tokenHash[:], tokenScope, time.Now()}
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Execute the query, scanning the return values into a User struct. If no matching record
+	// is found we return an ErrRecordNotFound error.
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	// Return the matching user.
+	return &user, nil
+}
+
+// ValidateEmail checks that the Email field is not an empty string and that it matches the regex
+// for email addresses, validator.EmailRX.
+func ValidateEmail(v *validator.Validator, email string) {
+	v.Check(email != "", "email", "must be provided")
+	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be valid email address")
+}
+
+// ValidatePasswordPlaintext validtes that the password is not an empty string and is between 8 and
+// 72 bytes long.
+func ValidatePasswordPlaintext(v *validator.Validator, password string) {
+	v.Check(password != "", "password", "must be provided")
+	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
+	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+}
+
+func ValidateUser(v *validator.Validator, user *User) {
+	// validate user.Name
+	v.Check(user.Name != "", "name", "must be provided")
+	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	// Validate email
+	ValidateEmail(v, user.Email)
+
+	// If the plaintext password is not nil, call the standalone ValidatePasswordPlaintext helper.
+	if user.Password.plaintext != nil {
+		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+	}
+
+	// If the password has is ever nil, this will be due to a logic error in our codebase
+	// (probably because we forgot to set a password for the user). It's a useful sanity check to
+	// include here, but it's not a problem with the data provided by the client. So, rather
+	// than adding an error to the validation map we raise a panic instead.
+	if user.Password.hash == nil {
+		// TODO: fix this panic
+		panic("missing password hash for user")
+	}
+}
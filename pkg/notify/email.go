@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email is a Notifier that sends messages as plain text email through an SMTP server.
+type Email struct {
+	host     string
+	port     int
+	username string
+	password string
+	sender   string
+	to       []string
+}
+
+// NewEmail returns an Email notifier sending from sender to the recipients in to; blank
+// recipients are ignored. The username and password are only used if the server supports
+// authentication, and can be left empty.
+func NewEmail(host string, port int, username, password, sender string, to []string) (*Email, error) {
+	var recipients []string
+	for _, addr := range to {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			recipients = append(recipients, addr)
+		}
+	}
+
+	if host == "" {
+		return nil, fmt.Errorf("notify: no SMTP host")
+	}
+	if sender == "" {
+		return nil, fmt.Errorf("notify: no sender address")
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("notify: no recipients")
+	}
+
+	return &Email{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		sender:   sender,
+		to:       recipients,
+	}, nil
+}
+
+// Notify sends the message to every recipient. The connection is upgraded to TLS when the server
+// supports it, and the whole exchange is bounded by the deadline of ctx, or a minute.
+func (n *Email) Notify(ctx context.Context, msg Message) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.host, fmt.Sprint(n.port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: n.host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if ok, _ := c.Extension("AUTH"); ok && n.username != "" {
+		err = c.Auth(smtp.PlainAuth("", n.username, n.password, n.host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(n.sender)
+	if err != nil {
+		return err
+	}
+
+	for _, to := range n.to {
+		err = c.Rcpt(to)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(n.message(msg))
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// message formats msg as an email with CRLF line endings.
+func (n *Email) message(msg Message) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", n.sender)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return []byte(b.String())
+}
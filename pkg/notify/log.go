@@ -0,0 +1,24 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/E4kere/Project/pkg/jsonlog"
+)
+
+// Log is a Notifier that only writes messages to the application log. It is used when no other
+// channel is configured.
+type Log struct {
+	logger *jsonlog.Logger
+}
+
+// NewLog returns a Log notifier writing to logger.
+func NewLog(logger *jsonlog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+// Notify logs the subject and properties of the message at the info level.
+func (n *Log) Notify(ctx context.Context, msg Message) error {
+	n.logger.PrintInfo(msg.Subject, msg.Properties)
+	return nil
+}
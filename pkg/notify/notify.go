@@ -0,0 +1,19 @@
+// Package notify sends notifications, such as reorder alerts, to the people running the shop
+// behind an interface so that the channel can be swapped without touching the workers.
+package notify
+
+import "context"
+
+// Message is a notification. Properties hold the details of the message as key-value pairs for
+// channels that can use them, such as structured logs.
+type Message struct {
+	Subject    string
+	Body       string
+	Properties map[string]string
+}
+
+// Notifier delivers messages. A failed delivery returns an error so that the caller can try
+// again later.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,184 @@
+// Package reports runs the sales and inventory reports that managers ask for every week:
+// revenue over time, top sellers, slow movers and stock valuation. Each report is a list of rows
+// that is encoded as JSON, or written as CSV with WriteCSV.
+//
+// Dates are calendar days in UTC, and date ranges include both the first and the last day.
+package reports
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+	"github.com/E4kere/Project/pkg/validator"
+)
+
+// The ways a report can group its rows. Not every report supports every grouping.
+const (
+	GroupDay          = "day"
+	GroupWeek         = "week"
+	GroupMonth        = "month"
+	GroupGun          = "gun"
+	GroupCategory     = "category"
+	GroupManufacturer = "manufacturer"
+)
+
+// periodFormats are the to_char formats of the time periods, e.g. "2024-03-05", "2024-W10" (ISO
+// weeks) and "2024-03".
+var periodFormats = map[string]string{
+	GroupDay:   "YYYY-MM-DD",
+	GroupWeek:  `IYYY-"W"IW`,
+	GroupMonth: "YYYY-MM",
+}
+
+// MaxRangeDays is the longest date range a report can cover.
+const MaxRangeDays = 3 * 366
+
+// DefaultLimit is the number of rows of the ranked reports when no limit is given.
+const DefaultLimit = 20
+
+// Params are the parameters shared by the reports. A zero CategoryID or ManufacturerID doesn't
+// filter, and Limit only applies to the ranked reports.
+type Params struct {
+	From           models.Date `json:"from"`
+	To             models.Date `json:"to"`
+	GroupBy        string      `json:"group_by,omitempty"`
+	CategoryID     int64       `json:"category_id,omitempty"`
+	ManufacturerID int64       `json:"manufacturer_id,omitempty"`
+	Limit          int         `json:"limit,omitempty"`
+}
+
+// start returns the first instant of the date range.
+func (p Params) start() time.Time {
+	return p.From.Time
+}
+
+// end returns the first instant after the date range.
+func (p Params) end() time.Time {
+	return p.To.AddDate(0, 0, 1)
+}
+
+// limit returns the number of rows of a ranked report.
+func (p Params) limit() int {
+	if p.Limit == 0 {
+		return DefaultLimit
+	}
+	return p.Limit
+}
+
+// Reports runs reports against the database. The queries only read, and can be pointed at a
+// replica.
+type Reports struct {
+	DB       *sql.DB
+	ErrorLog *log.Logger
+}
+
+// New returns a Reports running on db.
+func New(db *sql.DB, errorLog *log.Logger) *Reports {
+	return &Reports{DB: db, ErrorLog: errorLog}
+}
+
+// Table is a report that can be written as CSV: a header row followed by one record per row.
+type Table interface {
+	Header() []string
+	Records() [][]string
+}
+
+// WriteCSV writes a report as CSV.
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write(t.Header())
+	if err != nil {
+		return err
+	}
+
+	err = cw.WriteAll(t.Records())
+	if err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+// groupColumn returns the SQL expression that names the group of a row, where ts is the
+// timestamp that places the row in a time period.
+func groupColumn(groupBy, ts string) string {
+	if format, ok := periodFormats[groupBy]; ok {
+		return fmt.Sprintf("to_char(%s AT TIME ZONE 'UTC', '%s')", ts, format)
+	}
+
+	switch groupBy {
+	case GroupCategory:
+		return "COALESCE(categories.name, '')"
+	case GroupManufacturer:
+		return "COALESCE(manufacturers.name, '')"
+	default:
+		return "guns.name"
+	}
+}
+
+// gunIDColumn returns the SQL expression for the gun id of a row, which is only set when rows are
+// grouped by gun.
+func gunIDColumn(groupBy string) string {
+	if groupBy == GroupGun {
+		return "guns.id"
+	}
+	return "NULL::bigint"
+}
+
+// joinGuns returns the joins from the gun_id column of a table to the gun, its category and its
+// manufacturer.
+func joinGuns(gunID string) string {
+	return fmt.Sprintf(`
+		INNER JOIN guns ON guns.id = %s
+		LEFT JOIN categories ON categories.id = guns.category_id
+		LEFT JOIN manufacturers ON manufacturers.id = guns.manufacturer_id`, gunID)
+}
+
+// gunFilter returns the condition for the category and manufacturer filters, passed as the
+// numbered query parameters category and manufacturer.
+func gunFilter(category, manufacturer int) string {
+	return fmt.Sprintf("($%[1]d = 0 OR guns.category_id = $%[1]d) AND ($%[2]d = 0 OR guns.manufacturer_id = $%[2]d)",
+		category, manufacturer)
+}
+
+// ValidateParams checks the parameters of a report. groups are the groupings the report
+// supports; a report without any must not be given one.
+func ValidateParams(v *validator.Validator, p Params, groups ...string) {
+	v.Check(!p.To.Before(p.From.Time), "to", "must not be before from")
+	v.Check(p.end().Sub(p.start()) <= MaxRangeDays*24*time.Hour, "to",
+		fmt.Sprintf("must not be more than %d days after from", MaxRangeDays))
+
+	if len(groups) > 0 {
+		v.Check(validator.In(p.GroupBy, groups...), "group_by", "must be one of "+strings.Join(groups, ", "))
+	} else {
+		v.Check(p.GroupBy == "", "group_by", "is not supported by this report")
+	}
+
+	v.Check(p.CategoryID >= 0, "category_id", "must be a valid category id")
+	v.Check(p.ManufacturerID >= 0, "manufacturer_id", "must be a valid manufacturer id")
+	v.Check(p.Limit >= 0, "limit", "must not be negative")
+	v.Check(p.Limit <= 500, "limit", "must not be more than 500")
+}
+
+// formatTime formats an optional timestamp for CSV.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatID formats an optional id for CSV.
+func formatID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return fmt.Sprint(*id)
+}
@@ -0,0 +1,141 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+)
+
+// RevenueGroups are the groupings supported by the revenue report.
+var RevenueGroups = []string{GroupDay, GroupWeek, GroupMonth, GroupCategory, GroupManufacturer}
+
+type (
+	// RevenueRow is the revenue of a group: a time period, category or manufacturer. Revenue is
+	// what customers paid for the guns sold in the range, and Returns the value of the goods
+	// returned in the range, whenever they were sold.
+	RevenueRow struct {
+		Group      string       `json:"group"`
+		Sales      int          `json:"sales"`
+		Units      int          `json:"units"`
+		Revenue    models.Money `json:"revenue"`
+		Returns    models.Money `json:"returns"`
+		NetRevenue models.Money `json:"net_revenue"`
+	}
+
+	// RevenueReport is the revenue of each group with sales or returns in the range, in group
+	// order, and the total over all groups. The total counts each sale once, even when its lines
+	// fall into several groups.
+	RevenueReport struct {
+		Params Params        `json:"params"`
+		Rows   []*RevenueRow `json:"rows"`
+		Total  RevenueRow    `json:"total"`
+	}
+)
+
+// Revenue reports the revenue between two dates, grouped by day, week, month, category or
+// manufacturer. Denied and cancelled sales are left out.
+func (rp *Reports) Revenue(ctx context.Context, p Params) (*RevenueReport, error) {
+	query := fmt.Sprintf(`
+		WITH lines AS (
+			SELECT %s AS grp, sales.id AS sale_id, sale_items.quantity AS units,
+				sale_items.quantity * sale_items.unit_price AS revenue, 0 AS returned
+			FROM sale_items
+			INNER JOIN sales ON sales.id = sale_items.sale_id %s
+			WHERE sales.created_at >= $1 AND sales.created_at < $2 AND %s
+			AND sales.status NOT IN ('denied', 'cancelled')
+			UNION ALL
+			SELECT %s, NULL, 0, 0, returns.value
+			FROM returns %s
+			WHERE returns.created_at >= $1 AND returns.created_at < $2 AND %s
+		)
+		SELECT grp, count(DISTINCT sale_id), COALESCE(sum(units), 0), COALESCE(sum(revenue), 0),
+			COALESCE(sum(returned), 0)
+		FROM lines
+		GROUP BY ROLLUP (grp)
+		ORDER BY grp NULLS LAST
+		`,
+		groupColumn(p.GroupBy, "sales.created_at"), joinGuns("sale_items.gun_id"), gunFilter(3, 4),
+		groupColumn(p.GroupBy, "returns.created_at"), joinGuns("returns.gun_id"), gunFilter(3, 4),
+	)
+
+	args := []interface{}{p.start(), p.end(), p.CategoryID, p.ManufacturerID}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := rp.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			rp.ErrorLog.Println(err)
+		}
+	}()
+
+	report := &RevenueReport{
+		Params: p,
+		Rows:   []*RevenueRow{},
+		Total:  RevenueRow{Group: "total", Revenue: models.NewMoney(0), Returns: models.NewMoney(0)},
+	}
+
+	for rows.Next() {
+		var (
+			row   RevenueRow
+			group *string
+		)
+
+		err := rows.Scan(&group, &row.Sales, &row.Units, &row.Revenue, &row.Returns)
+		if err != nil {
+			return nil, err
+		}
+
+		row.NetRevenue = row.Revenue.Sub(row.Returns)
+
+		// The rollup row, with no group, is the total over all groups.
+		if group == nil {
+			row.Group = report.Total.Group
+			report.Total = row
+			continue
+		}
+
+		row.Group = *group
+		report.Rows = append(report.Rows, &row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Header implements Table.
+func (r *RevenueReport) Header() []string {
+	return []string{r.Params.GroupBy, "sales", "units", "revenue", "returns", "net_revenue"}
+}
+
+// Records implements Table. The total is the last record.
+func (r *RevenueReport) Records() [][]string {
+	records := make([][]string, 0, len(r.Rows)+1)
+
+	for _, row := range r.Rows {
+		records = append(records, row.record())
+	}
+
+	return append(records, r.Total.record())
+}
+
+func (row *RevenueRow) record() []string {
+	return []string{
+		row.Group,
+		strconv.Itoa(row.Sales),
+		strconv.Itoa(row.Units),
+		row.Revenue.String(),
+		row.Returns.String(),
+		row.NetRevenue.String(),
+	}
+}
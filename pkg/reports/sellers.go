@@ -0,0 +1,213 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+)
+
+// TopSellerGroups are the groupings supported by the top sellers report.
+var TopSellerGroups = []string{GroupGun, GroupCategory, GroupManufacturer}
+
+type (
+	// TopSeller is the quantity sold of a gun, category or manufacturer, and the revenue it
+	// brought in. GunID is only set when grouping by gun.
+	TopSeller struct {
+		Rank    int          `json:"rank"`
+		GunID   *int64       `json:"gun_id,omitempty"`
+		Group   string       `json:"group"`
+		Units   int          `json:"units"`
+		Revenue models.Money `json:"revenue"`
+	}
+
+	// TopSellersReport ranks the best sellers of the range by units sold, then revenue.
+	TopSellersReport struct {
+		Params Params       `json:"params"`
+		Rows   []*TopSeller `json:"rows"`
+	}
+
+	// SlowMover is a gun in stock and how much of it sold in the range. LastSoldAt is the last
+	// time it sold at all, and is nil if it never did.
+	SlowMover struct {
+		GunID          int64      `json:"gun_id"`
+		Name           string     `json:"name"`
+		Category       string     `json:"category"`
+		Manufacturer   string     `json:"manufacturer"`
+		QuantityOnHand int        `json:"quantity_on_hand"`
+		Units          int        `json:"units"`
+		LastSoldAt     *time.Time `json:"last_sold_at"`
+	}
+
+	// SlowMoversReport lists the guns in stock that sold the least in the range, with the most
+	// stock first among equals.
+	SlowMoversReport struct {
+		Params Params       `json:"params"`
+		Rows   []*SlowMover `json:"rows"`
+	}
+)
+
+// TopSellers reports the guns, categories or manufacturers that sold the most units between two
+// dates. Returns aren't taken off, and denied and cancelled sales are left out.
+func (rp *Reports) TopSellers(ctx context.Context, p Params) (*TopSellersReport, error) {
+	query := fmt.Sprintf(`
+		SELECT %s, %s, sum(sale_items.quantity), sum(sale_items.quantity * sale_items.unit_price)
+		FROM sale_items
+		INNER JOIN sales ON sales.id = sale_items.sale_id %s
+		WHERE sales.created_at >= $1 AND sales.created_at < $2 AND %s
+		AND sales.status NOT IN ('denied', 'cancelled')
+		GROUP BY 1, 2
+		ORDER BY 3 DESC, 4 DESC, 2 ASC
+		LIMIT $5
+		`, gunIDColumn(p.GroupBy), groupColumn(p.GroupBy, ""), joinGuns("sale_items.gun_id"), gunFilter(3, 4))
+
+	args := []interface{}{p.start(), p.end(), p.CategoryID, p.ManufacturerID, p.limit()}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := rp.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			rp.ErrorLog.Println(err)
+		}
+	}()
+
+	report := &TopSellersReport{Params: p, Rows: []*TopSeller{}}
+
+	for rows.Next() {
+		row := TopSeller{Rank: len(report.Rows) + 1}
+
+		err := rows.Scan(&row.GunID, &row.Group, &row.Units, &row.Revenue)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Rows = append(report.Rows, &row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Header implements Table.
+func (r *TopSellersReport) Header() []string {
+	return []string{"rank", "gun_id", r.Params.GroupBy, "units", "revenue"}
+}
+
+// Records implements Table.
+func (r *TopSellersReport) Records() [][]string {
+	records := make([][]string, 0, len(r.Rows))
+
+	for _, row := range r.Rows {
+		records = append(records, []string{
+			strconv.Itoa(row.Rank),
+			formatID(row.GunID),
+			row.Group,
+			strconv.Itoa(row.Units),
+			row.Revenue.String(),
+		})
+	}
+
+	return records
+}
+
+// SlowMovers reports the guns in stock that sold the fewest units between two dates, including
+// the ones that didn't sell at all. Guns in the trash, and denied and cancelled sales, are left
+// out.
+func (rp *Reports) SlowMovers(ctx context.Context, p Params) (*SlowMoversReport, error) {
+	query := `
+		WITH sold AS (
+			SELECT sale_items.gun_id,
+				sum(sale_items.quantity) FILTER (WHERE sales.created_at >= $1 AND sales.created_at < $2) AS units,
+				max(sales.created_at) AS last_sold_at
+			FROM sale_items
+			INNER JOIN sales ON sales.id = sale_items.sale_id
+			WHERE sales.status NOT IN ('denied', 'cancelled')
+			GROUP BY sale_items.gun_id
+		)
+		SELECT guns.id, guns.name, COALESCE(categories.name, ''), COALESCE(manufacturers.name, ''),
+			guns.quantity_on_hand, COALESCE(sold.units, 0), sold.last_sold_at
+		FROM guns
+		LEFT JOIN categories ON categories.id = guns.category_id
+		LEFT JOIN manufacturers ON manufacturers.id = guns.manufacturer_id
+		LEFT JOIN sold ON sold.gun_id = guns.id
+		WHERE guns.deleted_at IS NULL AND guns.quantity_on_hand > 0 AND ` + gunFilter(3, 4) + `
+		ORDER BY 6 ASC, guns.quantity_on_hand DESC, sold.last_sold_at ASC NULLS FIRST, guns.id ASC
+		LIMIT $5
+		`
+
+	args := []interface{}{p.start(), p.end(), p.CategoryID, p.ManufacturerID, p.limit()}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := rp.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			rp.ErrorLog.Println(err)
+		}
+	}()
+
+	report := &SlowMoversReport{Params: p, Rows: []*SlowMover{}}
+
+	for rows.Next() {
+		var row SlowMover
+
+		err := rows.Scan(
+			&row.GunID,
+			&row.Name,
+			&row.Category,
+			&row.Manufacturer,
+			&row.QuantityOnHand,
+			&row.Units,
+			&row.LastSoldAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Rows = append(report.Rows, &row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Header implements Table.
+func (r *SlowMoversReport) Header() []string {
+	return []string{"gun_id", "name", "category", "manufacturer", "quantity_on_hand", "units", "last_sold_at"}
+}
+
+// Records implements Table.
+func (r *SlowMoversReport) Records() [][]string {
+	records := make([][]string, 0, len(r.Rows))
+
+	for _, row := range r.Rows {
+		records = append(records, []string{
+			strconv.FormatInt(row.GunID, 10),
+			row.Name,
+			row.Category,
+			row.Manufacturer,
+			strconv.Itoa(row.QuantityOnHand),
+			strconv.Itoa(row.Units),
+			formatTime(row.LastSoldAt),
+		})
+	}
+
+	return records
+}
@@ -0,0 +1,141 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/E4kere/Project/pkg/models"
+)
+
+// ValuationGroups are the groupings supported by the stock valuation report.
+var ValuationGroups = []string{GroupGun, GroupCategory, GroupManufacturer}
+
+type (
+	// ValuationRow is the value of the stock on hand of a gun, category or manufacturer. Cost is
+	// valued at the average cost price of the gun's purchase order receipts, and Retail at the
+	// current list price. Stock of guns that were never received against a purchase order has
+	// no known cost, and is counted in UncostedQuantity instead. GunID is only set when grouping
+	// by gun.
+	ValuationRow struct {
+		GunID            *int64       `json:"gun_id,omitempty"`
+		Group            string       `json:"group"`
+		QuantityOnHand   int          `json:"quantity_on_hand"`
+		Cost             models.Money `json:"cost"`
+		Retail           models.Money `json:"retail"`
+		UncostedQuantity int          `json:"uncosted_quantity"`
+	}
+
+	// ValuationReport is the value of the stock on hand at a point in time, in group order, and
+	// the total over all groups.
+	ValuationReport struct {
+		Params Params          `json:"params"`
+		At     time.Time       `json:"at"`
+		Rows   []*ValuationRow `json:"rows"`
+		Total  ValuationRow    `json:"total"`
+	}
+)
+
+// Valuation reports the value of the stock on hand now, at cost and at retail, grouped by gun,
+// category or manufacturer. It is a snapshot, so the date range of p doesn't apply. Guns in the
+// trash are still counted, since their stock is still on the shelf.
+func (rp *Reports) Valuation(ctx context.Context, p Params) (*ValuationReport, error) {
+	query := fmt.Sprintf(`
+		WITH costs AS (
+			SELECT gun_id, sum(quantity * unit_cost) / sum(quantity) AS unit_cost
+			FROM stock_movements
+			WHERE kind = 'receive' AND unit_cost IS NOT NULL AND quantity > 0
+			GROUP BY gun_id
+		)
+		SELECT %s, %s, sum(guns.quantity_on_hand),
+			COALESCE(sum(guns.quantity_on_hand * costs.unit_cost), 0),
+			sum(guns.quantity_on_hand * guns.price),
+			COALESCE(sum(guns.quantity_on_hand) FILTER (WHERE costs.unit_cost IS NULL), 0),
+			NOW()
+		FROM guns
+		LEFT JOIN costs ON costs.gun_id = guns.id
+		LEFT JOIN categories ON categories.id = guns.category_id
+		LEFT JOIN manufacturers ON manufacturers.id = guns.manufacturer_id
+		WHERE guns.quantity_on_hand > 0 AND %s
+		GROUP BY 1, 2
+		ORDER BY 2, 1
+		`, gunIDColumn(p.GroupBy), groupColumn(p.GroupBy, ""), gunFilter(1, 2))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := rp.DB.QueryContext(ctx, query, p.CategoryID, p.ManufacturerID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			rp.ErrorLog.Println(err)
+		}
+	}()
+
+	report := &ValuationReport{
+		Params: p,
+		At:     time.Now(),
+		Rows:   []*ValuationRow{},
+		Total:  ValuationRow{Group: "total", Cost: models.NewMoney(0), Retail: models.NewMoney(0)},
+	}
+
+	for rows.Next() {
+		var row ValuationRow
+
+		err := rows.Scan(
+			&row.GunID,
+			&row.Group,
+			&row.QuantityOnHand,
+			&row.Cost,
+			&row.Retail,
+			&row.UncostedQuantity,
+			&report.At,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Rows = append(report.Rows, &row)
+
+		report.Total.QuantityOnHand += row.QuantityOnHand
+		report.Total.Cost = report.Total.Cost.Add(row.Cost)
+		report.Total.Retail = report.Total.Retail.Add(row.Retail)
+		report.Total.UncostedQuantity += row.UncostedQuantity
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Header implements Table.
+func (r *ValuationReport) Header() []string {
+	return []string{"gun_id", r.Params.GroupBy, "quantity_on_hand", "cost", "retail", "uncosted_quantity"}
+}
+
+// Records implements Table. The total is the last record.
+func (r *ValuationReport) Records() [][]string {
+	records := make([][]string, 0, len(r.Rows)+1)
+
+	for _, row := range r.Rows {
+		records = append(records, row.record())
+	}
+
+	return append(records, r.Total.record())
+}
+
+func (row *ValuationRow) record() []string {
+	return []string{
+		formatID(row.GunID),
+		row.Group,
+		strconv.Itoa(row.QuantityOnHand),
+		row.Cost.String(),
+		row.Retail.String(),
+		strconv.Itoa(row.UncostedQuantity),
+	}
+}
@@ -0,0 +1,24 @@
+// Package storage stores uploaded files, such as gun images, behind an interface so that the
+// backend can be swapped without touching the handlers.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("storage: file not found")
+
+// Storage stores files under slash separated keys such as "guns/1/3f9a.jpg".
+type Storage interface {
+	// Put stores the contents of r under key, replacing any existing file.
+	Put(ctx context.Context, key string, r io.Reader) error
+
+	// Open returns the contents of the file stored under key. It returns ErrNotFound if there
+	// is no such file. The caller must close the returned reader.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the file stored under key. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+}